@@ -0,0 +1,246 @@
+/*
+Package admin provides an [http.Handler] for inspecting and adjusting rate limiter buckets at runtime, so that a blocked customer can be unblocked without restarting the process.
+
+The handler has no authentication. Mount it on an internal port:
+
+	handler, err := admin.New(
+		admin.WithLimiter("ip", ipRateLimiter),
+		admin.WithLimiter("session", sessionRateLimiter),
+	)
+	go http.ListenAndServe("127.0.0.1:9090", http.StripPrefix("/ratelimits", handler))
+
+Routes:
+
+	GET    /limiters                          lists rate limiters
+	GET    /limiters/{limiter}/tags           lists tags with their remaining tokens
+	GET    /limiters/{limiter}/tags/{tag}     returns the remaining tokens of a tag
+	DELETE /limiters/{limiter}/tags/{tag}     restores all tokens of a tag
+	POST   /limiters/{limiter}/tags/{tag}     grants {"tokens": 5} or revokes {"tokens": -5}
+
+Listing, resetting, and adjusting require a [rate.Inspector]. Other rate limiters respond with [http.StatusNotImplemented].
+*/
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dkotik/oakratelimiter"
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+var _ http.Handler = (*Handler)(nil) // enforce interface compliance
+
+// Limiter describes a rate limiter in responses.
+type Limiter struct {
+	Name        string `json:"name"`
+	Rate        string `json:"rate"`
+	Inspectable bool   `json:"inspectable"`
+}
+
+// Bucket describes the tokens of a tag in responses. Touched is absent, if the driver does not report it.
+type Bucket struct {
+	Tag       string     `json:"tag"`
+	Remaining float64    `json:"remaining"`
+	Touched   *time.Time `json:"touched,omitempty"`
+}
+
+// Adjustment is the request body for granting or revoking tokens.
+type Adjustment struct {
+	Tokens float64 `json:"tokens"`
+}
+
+// Handler serves the administrative API. Errors are written as "application/problem+json".
+type Handler struct {
+	names    []string
+	limiters map[string]rate.Limiter
+	mux      *http.ServeMux
+	errors   oakratelimiter.ErrorRenderer
+}
+
+// New creates a [Handler] for the rate limiters given by [WithLimiter] options.
+func New(withOptions ...Option) (_ *Handler, err error) {
+	o := &options{}
+	for _, option := range withOptions {
+		if err = option(o); err != nil {
+			return nil, fmt.Errorf("cannot initialize rate limiter administration handler: %w", err)
+		}
+	}
+	if len(o.Names) == 0 {
+		return nil, errors.New("cannot initialize rate limiter administration handler: at least one rate limiter is required")
+	}
+
+	h := &Handler{
+		names:    o.Names,
+		limiters: o.Limiters,
+		mux:      http.NewServeMux(),
+		errors:   oakratelimiter.NewProblemJSONErrorRenderer(""),
+	}
+	h.mux.HandleFunc("GET /limiters", h.serve(h.listLimiters))
+	h.mux.HandleFunc("GET /limiters/{limiter}/tags", h.serve(h.listBuckets))
+	h.mux.HandleFunc("GET /limiters/{limiter}/tags/{tag}", h.serve(h.lookup))
+	h.mux.HandleFunc("DELETE /limiters/{limiter}/tags/{tag}", h.serve(h.reset))
+	h.mux.HandleFunc("POST /limiters/{limiter}/tags/{tag}", h.serve(h.adjust))
+	return h, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// serve writes the result as JSON or renders the error.
+func (h *Handler) serve(f func(*http.Request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := f(r)
+		if err != nil {
+			h.errors.RenderError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	}
+}
+
+func (h *Handler) listLimiters(r *http.Request) (any, error) {
+	limiters := make([]Limiter, 0, len(h.names))
+	for _, name := range h.names {
+		l := h.limiters[name]
+		_, inspectable := l.(rate.Inspector)
+		limiters = append(limiters, Limiter{
+			Name:        name,
+			Rate:        l.Rate().String(),
+			Inspectable: inspectable,
+		})
+	}
+	return limiters, nil
+}
+
+func (h *Handler) listBuckets(r *http.Request) (any, error) {
+	_, inspector, err := h.inspector(r)
+	if err != nil {
+		return nil, err
+	}
+	found, err := inspector.Buckets(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	buckets := make([]Bucket, 0, len(found))
+	for _, bucket := range found {
+		buckets = append(buckets, newBucket(bucket.Tag, bucket.Remaining, bucket.Touched))
+	}
+	return buckets, nil
+}
+
+func (h *Handler) lookup(r *http.Request) (any, error) {
+	l, err := h.limiter(r)
+	if err != nil {
+		return nil, err
+	}
+	tag := r.PathValue("tag")
+	remaining, err := l.Remaining(r.Context(), tag)
+	if err != nil {
+		return nil, err
+	}
+	return newBucket(tag, remaining, time.Time{}), nil
+}
+
+func (h *Handler) reset(r *http.Request) (any, error) {
+	l, inspector, err := h.inspector(r)
+	if err != nil {
+		return nil, err
+	}
+	tag := r.PathValue("tag")
+	if err = inspector.Reset(r.Context(), tag); err != nil {
+		return nil, err
+	}
+	remaining, err := l.Remaining(r.Context(), tag)
+	if err != nil {
+		return nil, err
+	}
+	return newBucket(tag, remaining, time.Time{}), nil
+}
+
+func (h *Handler) adjust(r *http.Request) (any, error) {
+	_, inspector, err := h.inspector(r)
+	if err != nil {
+		return nil, err
+	}
+	adjustment := Adjustment{}
+	decoder := json.NewDecoder(io.LimitReader(r.Body, 1<<10))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&adjustment); err != nil {
+		return nil, &statusError{
+			status: http.StatusBadRequest,
+			err:    fmt.Errorf("cannot decode adjustment: %w", err),
+		}
+	}
+	if adjustment.Tokens == 0 {
+		return nil, &statusError{
+			status: http.StatusBadRequest,
+			err:    errors.New("adjustment must grant or revoke tokens"),
+		}
+	}
+	tag := r.PathValue("tag")
+	remaining, err := inspector.Adjust(r.Context(), tag, adjustment.Tokens)
+	if err != nil {
+		return nil, err
+	}
+	return newBucket(tag, remaining, time.Time{}), nil
+}
+
+func (h *Handler) limiter(r *http.Request) (rate.Limiter, error) {
+	name := r.PathValue("limiter")
+	l, ok := h.limiters[name]
+	if !ok {
+		return nil, &statusError{
+			status: http.StatusNotFound,
+			err:    fmt.Errorf("rate limiter %q does not exist", name),
+		}
+	}
+	return l, nil
+}
+
+func (h *Handler) inspector(r *http.Request) (rate.Limiter, rate.Inspector, error) {
+	l, err := h.limiter(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	inspector, ok := l.(rate.Inspector)
+	if !ok {
+		return nil, nil, &statusError{
+			status: http.StatusNotImplemented,
+			err:    fmt.Errorf("rate limiter %q cannot be inspected", r.PathValue("limiter")),
+		}
+	}
+	return l, inspector, nil
+}
+
+func newBucket(tag string, remaining float64, touched time.Time) Bucket {
+	b := Bucket{Tag: tag, Remaining: remaining}
+	if !touched.IsZero() {
+		b.Touched = &touched
+	}
+	return b
+}
+
+// statusError satisfies [oakratelimiter.Error] to choose the response status code.
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+func (e *statusError) HyperTextStatusCode() int {
+	return e.status
+}
@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/driver/mutexrlm"
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+// opaqueLimiter hides the [rate.Inspector] methods of the embedded limiter.
+type opaqueLimiter struct {
+	rate.Limiter
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	limiter, err := mutexrlm.New(mutexrlm.WithNewRate(4, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, ok, err := limiter.Take(ctx, "customer", 1); !ok || err != nil {
+			t.Fatal("cannot take tokens:", err)
+		}
+	}
+	h, err := New(
+		WithLimiter("ip", limiter),
+		WithLimiter("opaque", &opaqueLimiter{limiter}),
+	)
+	if err != nil {
+		t.Fatal("cannot create handler:", err)
+	}
+
+	request := func(method, target, body string, status int, result any) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		if w.Code != status {
+			t.Fatalf("%s %s responded with status %d instead of %d: %s", method, target, w.Code, status, w.Body.String())
+		}
+		if result != nil {
+			if err := json.NewDecoder(w.Body).Decode(result); err != nil {
+				t.Fatal("cannot decode response:", err)
+			}
+		}
+	}
+
+	var limiters []Limiter
+	request(http.MethodGet, "/limiters", "", http.StatusOK, &limiters)
+	if len(limiters) != 2 || !limiters[0].Inspectable || limiters[1].Inspectable {
+		t.Fatal("unexpected limiters:", limiters)
+	}
+
+	var buckets []Bucket
+	request(http.MethodGet, "/limiters/ip/tags", "", http.StatusOK, &buckets)
+	if len(buckets) != 1 || buckets[0].Tag != "customer" || buckets[0].Remaining > 0.1 || buckets[0].Touched == nil {
+		t.Fatal("unexpected buckets:", buckets)
+	}
+
+	var bucket Bucket
+	request(http.MethodPost, "/limiters/ip/tags/customer", `{"tokens": 2}`, http.StatusOK, &bucket)
+	if bucket.Remaining < 2 || bucket.Remaining > 2.1 {
+		t.Fatal("tokens were not granted:", bucket.Remaining)
+	}
+	request(http.MethodPost, "/limiters/ip/tags/customer", `{"tokens": -10}`, http.StatusOK, &bucket)
+	if bucket.Remaining != 0 {
+		t.Fatal("tokens were not revoked:", bucket.Remaining)
+	}
+	request(http.MethodDelete, "/limiters/ip/tags/customer", "", http.StatusOK, &bucket)
+	if bucket.Remaining != 4 {
+		t.Fatal("bucket was not reset:", bucket.Remaining)
+	}
+	request(http.MethodGet, "/limiters/opaque/tags/customer", "", http.StatusOK, &bucket)
+	if bucket.Remaining != 4 {
+		t.Fatal("unexpected remaining tokens:", bucket.Remaining)
+	}
+
+	request(http.MethodGet, "/limiters/missing/tags", "", http.StatusNotFound, nil)
+	request(http.MethodGet, "/limiters/opaque/tags", "", http.StatusNotImplemented, nil)
+	request(http.MethodPost, "/limiters/ip/tags/customer", `{"tokens": 0}`, http.StatusBadRequest, nil)
+	request(http.MethodPost, "/limiters/ip/tags/customer", `{"coins": 1}`, http.StatusBadRequest, nil)
+}
@@ -0,0 +1,37 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+type options struct {
+	Names    []string
+	Limiters map[string]rate.Limiter
+}
+
+// Option configures the administrative [Handler].
+type Option func(*options) error
+
+// WithLimiter exposes a named [rate.Limiter] for inspection and adjustment.
+func WithLimiter(name string, l rate.Limiter) Option {
+	return func(o *options) error {
+		if name == "" {
+			return errors.New("cannot use an empty rate limiter name")
+		}
+		if l == nil {
+			return errors.New("cannot use a <nil> rate limiter")
+		}
+		if o.Limiters == nil {
+			o.Limiters = make(map[string]rate.Limiter)
+		}
+		if _, ok := o.Limiters[name]; ok {
+			return fmt.Errorf("rate limiter %q is already set", name)
+		}
+		o.Names = append(o.Names, name)
+		o.Limiters[name] = l
+		return nil
+	}
+}
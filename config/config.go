@@ -0,0 +1,280 @@
+/*
+Package config builds rate limiting middleware from a declarative document, so that limits can be tuned without changing Go code.
+
+JSON documents are supported out of the box. Register a [Decoder] to read other formats:
+
+	config.RegisterFormat(".yaml", yaml.Unmarshal)
+	config.RegisterFormat(".toml", toml.Unmarshal)
+
+A document lists request limiters in the order they are consulted:
+
+	{
+	  "headerWriter": "rateLimit",
+	  "limiters": [
+	    {
+	      "tagger": {"type": "ip"},
+	      "rate": "100/m",
+	      "bypass": ["127.0.0.1"]
+	    },
+	    {
+	      "name": "session",
+	      "tagger": {"type": "cookie", "name": "session"},
+	      "driver": {"type": "sqlite", "settings": {"file": "/var/lib/app/limits.sqlite3"}},
+	      "rate": "1000 per hour",
+	      "burst": 50
+	    }
+	  ]
+	}
+
+Validation errors are [Error]s that point at the offending path, like "limiters[1].rate".
+*/
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dkotik/oakratelimiter"
+	"github.com/dkotik/oakratelimiter/rate"
+	"github.com/dkotik/oakratelimiter/request"
+	"github.com/dkotik/oakratelimiter/request/tagbycookie"
+	"github.com/dkotik/oakratelimiter/request/tagbyheader"
+	"github.com/dkotik/oakratelimiter/request/tagbyip"
+)
+
+// Config describes rate limiting middleware.
+type Config struct {
+	// HeaderWriter is one of "obfuscating", "silent", "truthful", or "rateLimit". Defaults to "obfuscating".
+	HeaderWriter string    `json:"headerWriter,omitempty" yaml:"headerWriter,omitempty" toml:"headerWriter,omitempty"`
+	Limiters     []Limiter `json:"limiters" yaml:"limiters" toml:"limiters"`
+}
+
+// Limiter describes one named request limiter.
+type Limiter struct {
+	// Name identifies the limiter in errors, logs, and [oakratelimiter.TooManyRequestsError]. Defaults to the name used by the matching [oakratelimiter.Option], like "internetProtocolAddress" or "cookie:session".
+	Name   string `json:"name,omitempty" yaml:"name,omitempty" toml:"name,omitempty"`
+	Tagger Tagger `json:"tagger" yaml:"tagger" toml:"tagger"`
+	Driver Driver `json:"driver,omitempty" yaml:"driver,omitempty" toml:"driver,omitempty"`
+	// Rate is parsed by [rate.Parse], like "10/s" or "1000 per hour".
+	Rate string `json:"rate" yaml:"rate" toml:"rate"`
+	// Burst is the most tokens a tag can accumulate. Defaults to the number of tokens in the rate.
+	Burst float64 `json:"burst,omitempty" yaml:"burst,omitempty" toml:"burst,omitempty"`
+	// Bypass lists request tags that are never limited, like trusted IP addresses.
+	Bypass []string `json:"bypass,omitempty" yaml:"bypass,omitempty" toml:"bypass,omitempty"`
+}
+
+// Tagger determines how requests are grouped.
+type Tagger struct {
+	// Type is one of "global", "ip", "cookie", or "header". Global limiters share one bucket across all requests.
+	Type string `json:"type" yaml:"type" toml:"type"`
+	// Name is the HTTP cookie or header name.
+	Name string `json:"name,omitempty" yaml:"name,omitempty" toml:"name,omitempty"`
+}
+
+// Driver selects the [rate.Limiter] implementation registered by [RegisterDriver].
+type Driver struct {
+	// Type defaults to "mutex".
+	Type     string            `json:"type,omitempty" yaml:"type,omitempty" toml:"type,omitempty"`
+	Settings map[string]string `json:"settings,omitempty" yaml:"settings,omitempty" toml:"settings,omitempty"`
+}
+
+// Error points at the part of the [Config] that caused it.
+type Error struct {
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Decoder reads a document into a value, like [json.Unmarshal].
+type Decoder func(data []byte, v any) error
+
+var (
+	formatsMu sync.RWMutex
+	formats   = map[string]Decoder{
+		".json": decodeJSON,
+	}
+)
+
+// RegisterFormat makes [LoadFile] read files with the given extension using a [Decoder]. Panics, if the extension is already registered.
+func RegisterFormat(extension string, d Decoder) {
+	if d == nil {
+		panic("cannot use a <nil> decoder")
+	}
+	extension = strings.ToLower(extension)
+	if !strings.HasPrefix(extension, ".") {
+		panic(fmt.Sprintf("file extension %q must start with a dot", extension))
+	}
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	if _, ok := formats[extension]; ok {
+		panic(fmt.Sprintf("format %q is already registered", extension))
+	}
+	formats[extension] = d
+}
+
+// decodeJSON rejects unknown fields, so that misspelled settings are not silently ignored.
+func decodeJSON(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// Load reads a JSON [Config].
+func Load(r io.Reader) (*Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read configuration: %w", err)
+	}
+	c := &Config{}
+	if err = decodeJSON(data, c); err != nil {
+		return nil, fmt.Errorf("cannot decode configuration: %w", err)
+	}
+	return c, nil
+}
+
+// LoadFile reads a [Config] using the [Decoder] registered for the file extension.
+func LoadFile(path string) (*Config, error) {
+	formatsMu.RLock()
+	decode, ok := formats[strings.ToLower(filepath.Ext(path))]
+	formatsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("cannot load configuration file %q: unknown format %q", path, filepath.Ext(path))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load configuration file: %w", err)
+	}
+	c := &Config{}
+	if err = decode(data, c); err != nil {
+		return nil, fmt.Errorf("cannot decode configuration file %q: %w", path, err)
+	}
+	return c, nil
+}
+
+// Options translates the [Config] into [oakratelimiter.Option]s. Each request limiter is created right away, so that configuration mistakes are reported as [Error]s.
+func (c *Config) Options() (options []oakratelimiter.Option, err error) {
+	if len(c.Limiters) == 0 {
+		return nil, &Error{Path: "limiters", Err: errors.New("at least one request limiter is required")}
+	}
+	switch c.HeaderWriter {
+	case "", "obfuscating": // default
+	case "silent":
+		options = append(options, oakratelimiter.WithHeaderWriter(&oakratelimiter.SilentHeaderWriter{}))
+	case "truthful":
+		options = append(options, oakratelimiter.WithHeaderWriter(oakratelimiter.NewTruthfulHeaderWriter()))
+	case "rateLimit":
+		options = append(options, oakratelimiter.WithHeaderWriter(oakratelimiter.NewRateLimitHeaderWriter()))
+	default:
+		return nil, &Error{Path: "headerWriter", Err: fmt.Errorf("unknown header writer %q", c.HeaderWriter)}
+	}
+
+	names := make(map[string]int, len(c.Limiters))
+	for i, l := range c.Limiters {
+		path := fmt.Sprintf("limiters[%d]", i)
+		name, requestLimiter, err := l.build(path)
+		if err != nil {
+			return nil, err
+		}
+		if j, ok := names[name]; ok {
+			return nil, &Error{Path: path + ".name", Err: fmt.Errorf("rate limiter %q is already set by limiters[%d]", name, j)}
+		}
+		names[name] = i
+		options = append(options, oakratelimiter.WithRequestLimiter(name, requestLimiter))
+	}
+	return options, nil
+}
+
+// Middleware creates [oakratelimiter.Middleware] from the [Config].
+func (c *Config) Middleware() (oakratelimiter.Middleware, error) {
+	options, err := c.Options()
+	if err != nil {
+		return nil, err
+	}
+	return oakratelimiter.NewMiddleware(options...)
+}
+
+// name returns the configured name or the one the matching [oakratelimiter.Option] would use.
+func (l *Limiter) name() string {
+	if l.Name != "" {
+		return l.Name
+	}
+	switch l.Tagger.Type {
+	case "ip":
+		return "internetProtocolAddress"
+	case "cookie", "header":
+		return l.Tagger.Type + ":" + l.Tagger.Name
+	default:
+		return l.Tagger.Type
+	}
+}
+
+func (l *Limiter) build(path string) (_ string, _ request.Limiter, err error) {
+	switch l.Tagger.Type {
+	case "global", "ip":
+		if l.Tagger.Name != "" {
+			return "", nil, &Error{Path: path + ".tagger.name", Err: fmt.Errorf("name does not apply to %q tagger", l.Tagger.Type)}
+		}
+	case "cookie", "header": // name is validated by the tagger
+	case "":
+		return "", nil, &Error{Path: path + ".tagger.type", Err: errors.New("tagger type is required")}
+	default:
+		return "", nil, &Error{Path: path + ".tagger.type", Err: fmt.Errorf("unknown tagger type %q", l.Tagger.Type)}
+	}
+	r, err := rate.Parse(l.Rate)
+	if err != nil {
+		return "", nil, &Error{Path: path + ".rate", Err: err}
+	}
+	driver := l.Driver.Type
+	if driver == "" {
+		driver = "mutex"
+	}
+	factory, ok := lookupDriver(driver)
+	if !ok {
+		return "", nil, &Error{Path: path + ".driver.type", Err: fmt.Errorf("unknown driver %q", driver)}
+	}
+	limiter, err := factory(r, l.Burst, l.Driver.Settings)
+	if err != nil {
+		return "", nil, &Error{Path: path + ".driver", Err: err}
+	}
+	if len(l.Bypass) > 0 {
+		if limiter, err = rate.NewListBypassLimiter(limiter, l.Bypass...); err != nil {
+			return "", nil, &Error{Path: path + ".bypass", Err: err}
+		}
+	}
+
+	var requestLimiter request.Limiter
+	switch l.Tagger.Type {
+	case "global":
+		requestLimiter, err = request.NewStaticLimiter("global", limiter)
+	case "ip":
+		requestLimiter, err = tagbyip.New(tagbyip.WithRateLimiter(limiter))
+	case "cookie":
+		requestLimiter, err = tagbycookie.New(
+			tagbycookie.WithName(l.Tagger.Name),
+			tagbycookie.WithRateLimiter(limiter),
+		)
+	case "header":
+		requestLimiter, err = tagbyheader.New(
+			tagbyheader.WithName(l.Tagger.Name),
+			tagbyheader.WithRateLimiter(limiter),
+		)
+	}
+	if err != nil {
+		return "", nil, &Error{Path: path + ".tagger", Err: err}
+	}
+	return l.name(), requestLimiter, nil
+}
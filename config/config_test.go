@@ -0,0 +1,152 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dkotik/oakratelimiter"
+)
+
+const document = `{
+  "headerWriter": "silent",
+  "limiters": [
+    {"tagger": {"type": "ip"}, "rate": "2/m", "bypass": ["10.0.0.1"]},
+    {"name": "session", "tagger": {"type": "cookie", "name": "session"}, "rate": "100 per hour", "burst": 50}
+  ]
+}`
+
+func TestConfig(t *testing.T) {
+	c, err := Load(strings.NewReader(document))
+	if err != nil {
+		t.Fatal("cannot load configuration:", err)
+	}
+	middleware, err := c.Middleware()
+	if err != nil {
+		t.Fatal("cannot create middleware:", err)
+	}
+	h := middleware(oakratelimiter.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}))
+
+	serve := func(address string) error {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = address + ":1234"
+		r.AddCookie(&http.Cookie{Name: "session", Value: "alice"})
+		return h.ServeHyperText(httptest.NewRecorder(), r)
+	}
+	for i := 0; i < 2; i++ {
+		if err = serve("192.168.0.1"); err != nil {
+			t.Fatal("request was rejected:", err)
+		}
+	}
+	var tooMany *oakratelimiter.TooManyRequestsError
+	if err = serve("192.168.0.1"); !errors.As(err, &tooMany) {
+		t.Fatal("request was not rejected:", err)
+	}
+	if rejectedBy := tooMany.RejectedBy(); len(rejectedBy) != 1 || rejectedBy[0] != "internetProtocolAddress" {
+		t.Fatal("request was rejected by unexpected limiters:", rejectedBy)
+	}
+	for i := 0; i < 5; i++ {
+		if err = serve("10.0.0.1"); err != nil {
+			t.Fatal("bypassed address was rejected:", err)
+		}
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	cases := map[string]string{
+		"limiters":                `{"limiters": []}`,
+		"headerWriter":            `{"headerWriter": "loud", "limiters": [{"tagger": {"type": "ip"}, "rate": "1/s"}]}`,
+		"limiters[0].tagger.type": `{"limiters": [{"tagger": {"type": "moon"}, "rate": "1/s"}]}`,
+		"limiters[0].tagger.name": `{"limiters": [{"tagger": {"type": "ip", "name": "x"}, "rate": "1/s"}]}`,
+		"limiters[1].rate":        `{"limiters": [{"tagger": {"type": "ip"}, "rate": "1/s"}, {"tagger": {"type": "global"}, "rate": "fast"}]}`,
+		"limiters[0].driver.type": `{"limiters": [{"tagger": {"type": "ip"}, "rate": "1/s", "driver": {"type": "floppy"}}]}`,
+		"limiters[0].driver":      `{"limiters": [{"tagger": {"type": "ip"}, "rate": "1/s", "driver": {"settings": {"color": "red"}}}]}`,
+		"limiters[0].tagger":      `{"limiters": [{"tagger": {"type": "header"}, "rate": "1/s"}]}`,
+		"limiters[1].name":        `{"limiters": [{"tagger": {"type": "ip"}, "rate": "1/s"}, {"name": "internetProtocolAddress", "tagger": {"type": "global"}, "rate": "1/s"}]}`,
+	}
+	for path, document := range cases {
+		c, err := Load(strings.NewReader(document))
+		if err != nil {
+			t.Fatal("cannot load configuration:", err)
+		}
+		_, err = c.Options()
+		var configError *Error
+		if !errors.As(err, &configError) {
+			t.Fatalf("configuration %s was accepted or returned an unexpected error: %v", document, err)
+		}
+		if configError.Path != path {
+			t.Errorf("error %q points at %q instead of %q", err, configError.Path, path)
+		}
+	}
+
+	if _, err := Load(strings.NewReader(`{"limiters": [], "typo": true}`)); err == nil {
+		t.Fatal("unknown field was accepted")
+	}
+}
+
+func TestApplyEnvironment(t *testing.T) {
+	c, err := Load(strings.NewReader(document))
+	if err != nil {
+		t.Fatal("cannot load configuration:", err)
+	}
+	t.Setenv("OAK_INTERNET_PROTOCOL_ADDRESS_RATE", "5/s")
+	t.Setenv("OAK_INTERNET_PROTOCOL_ADDRESS_BYPASS", "127.0.0.1, ::1")
+	t.Setenv("OAK_INTERNET_PROTOCOL_ADDRESS_DRIVER_CLEANUP_INTERVAL", "5m")
+	t.Setenv("OAK_SESSION_BURST", "10")
+	if err = c.ApplyEnvironment("OAK"); err != nil {
+		t.Fatal("cannot apply environment:", err)
+	}
+
+	ip, session := c.Limiters[0], c.Limiters[1]
+	if ip.Rate != "5/s" {
+		t.Error("rate was not overridden:", ip.Rate)
+	}
+	if len(ip.Bypass) != 2 || ip.Bypass[1] != "::1" {
+		t.Error("bypass list was not overridden:", ip.Bypass)
+	}
+	if ip.Driver.Settings["cleanupInterval"] != "5m" {
+		t.Error("driver setting was not overridden:", ip.Driver.Settings)
+	}
+	if session.Burst != 10 {
+		t.Error("burst was not overridden:", session.Burst)
+	}
+	if _, err = c.Middleware(); err != nil {
+		t.Fatal("cannot create middleware:", err)
+	}
+
+	t.Setenv("OAK_SESSION_BURST", "many")
+	var configError *Error
+	if err = c.ApplyEnvironment("OAK"); !errors.As(err, &configError) || configError.Path != "limiters[1].burst" {
+		t.Fatal("invalid burst was not reported:", err)
+	}
+}
+
+// registerConf adds the ".conf" format once, because [RegisterFormat] rejects duplicates when tests run repeatedly.
+var registerConf sync.Once
+
+func TestLoadFile(t *testing.T) {
+	registerConf.Do(func() { RegisterFormat(".conf", json.Unmarshal) })
+	p := filepath.Join(t.TempDir(), "limits.conf")
+	if err := os.WriteFile(p, []byte(document), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadFile(p)
+	if err != nil {
+		t.Fatal("cannot load configuration file:", err)
+	}
+	if len(c.Limiters) != 2 {
+		t.Fatal("unexpected number of limiters:", len(c.Limiters))
+	}
+	if _, err = LoadFile(filepath.Join(t.TempDir(), "limits.ini")); err == nil {
+		t.Fatal("unknown format was accepted")
+	}
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/driver/mutexrlm"
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+// DriverFactory creates a [rate.Limiter] from [Driver] settings. Zero burst means the driver default. Unknown settings should be reported as errors.
+type DriverFactory func(r *rate.Rate, burst float64, settings map[string]string) (rate.Limiter, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]DriverFactory{
+		"mutex": newMutexDriver,
+	}
+)
+
+// RegisterDriver makes a [DriverFactory] available to [Config] under the given name. Only the "mutex" driver is built in. Drivers that live in separate modules must be registered by the application, like `RegisterDriver("sqlite", sqliterlm.NewFromSettings)`. Panics, if the name is already registered.
+func RegisterDriver(name string, f DriverFactory) {
+	if name == "" {
+		panic("cannot use an empty driver name")
+	}
+	if f == nil {
+		panic("cannot use a <nil> driver factory")
+	}
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if _, ok := drivers[name]; ok {
+		panic(fmt.Sprintf("driver %q is already registered", name))
+	}
+	drivers[name] = f
+}
+
+func lookupDriver(name string) (f DriverFactory, ok bool) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	f, ok = drivers[name]
+	return f, ok
+}
+
+// newMutexDriver creates a [mutexrlm.RateLimiter]. Settings: "cleanupInterval" and "initialAllocationSize".
+func newMutexDriver(r *rate.Rate, burst float64, settings map[string]string) (rate.Limiter, error) {
+	withOptions := []mutexrlm.Option{mutexrlm.WithRate(r)}
+	if burst != 0 {
+		withOptions = append(withOptions, mutexrlm.WithBurst(burst))
+	}
+	for key, value := range settings {
+		switch key {
+		case "cleanupInterval":
+			interval, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid setting %q: %w", key, err)
+			}
+			withOptions = append(withOptions, mutexrlm.WithCleanupInterval(interval))
+		case "initialAllocationSize":
+			size, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid setting %q: %w", key, err)
+			}
+			withOptions = append(withOptions, mutexrlm.WithInitialAllocationSize(size))
+		default:
+			return nil, fmt.Errorf("unknown setting %q", key)
+		}
+	}
+	return mutexrlm.New(withOptions...)
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ApplyEnvironment overrides [Limiter] fields with environment variables named after the prefix and the limiter name in upper snake case. For a prefix "OAK" and a limiter named "cookie:session":
+//
+//	OAK_COOKIE_SESSION_RATE=100/m
+//	OAK_COOKIE_SESSION_BURST=20
+//	OAK_COOKIE_SESSION_BYPASS=alice,bob
+//	OAK_COOKIE_SESSION_DRIVER=sqlite
+//	OAK_COOKIE_SESSION_DRIVER_CLEANUP_INTERVAL=5m
+//
+// The last variable sets the "cleanupInterval" driver setting. Variables are applied before validation, so their mistakes are reported as [Error]s by [Config.Options].
+func (c *Config) ApplyEnvironment(prefix string) error {
+	if prefix == "" {
+		return errors.New("cannot use an empty environment variable prefix")
+	}
+	environment := os.Environ()
+	for i := range c.Limiters {
+		l := &c.Limiters[i]
+		path := fmt.Sprintf("limiters[%d]", i)
+		variable := strings.TrimSuffix(prefix, "_") + "_" + upperSnakeCase(l.name()) + "_"
+
+		if value, ok := os.LookupEnv(variable + "RATE"); ok {
+			l.Rate = value
+		}
+		if value, ok := os.LookupEnv(variable + "BURST"); ok {
+			burst, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return &Error{Path: path + ".burst", Err: fmt.Errorf("invalid environment variable %q: %w", variable+"BURST", err)}
+			}
+			l.Burst = burst
+		}
+		if value, ok := os.LookupEnv(variable + "BYPASS"); ok {
+			l.Bypass = nil
+			for _, tag := range strings.Split(value, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					l.Bypass = append(l.Bypass, tag)
+				}
+			}
+		}
+		if value, ok := os.LookupEnv(variable + "DRIVER"); ok {
+			l.Driver.Type = value
+		}
+		for _, entry := range environment {
+			key, value, _ := strings.Cut(entry, "=")
+			setting, ok := strings.CutPrefix(key, variable+"DRIVER_")
+			if !ok || setting == "" {
+				continue
+			}
+			if l.Driver.Settings == nil {
+				l.Driver.Settings = make(map[string]string)
+			}
+			l.Driver.Settings[lowerCamelCase(setting)] = value
+		}
+	}
+	return nil
+}
+
+// upperSnakeCase converts "internetProtocolAddress" into "INTERNET_PROTOCOL_ADDRESS" and "cookie:session" into "COOKIE_SESSION".
+func upperSnakeCase(s string) string {
+	b := &strings.Builder{}
+	separated := true
+	for i, r := range s {
+		switch {
+		case unicode.IsUpper(r) && i > 0 && !separated:
+			b.WriteByte('_')
+			b.WriteRune(r)
+			separated = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToUpper(r))
+			separated = false
+		case !separated:
+			b.WriteByte('_')
+			separated = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+// lowerCamelCase converts "CLEANUP_INTERVAL" into "cleanupInterval".
+func lowerCamelCase(s string) string {
+	words := strings.Split(strings.ToLower(s), "_")
+	for i := 1; i < len(words); i++ {
+		if words[i] != "" {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+	return strings.Join(words, "")
+}
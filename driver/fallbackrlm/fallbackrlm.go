@@ -0,0 +1,211 @@
+/*
+Package fallbackrlm provides a [rate.Limiter] that delegates to a remote driver, like postgresrlm or sqliterlm, and switches to local in-memory limiting when the remote store fails or becomes too slow. It probes the remote store in the background and switches back once it recovers.
+*/
+package fallbackrlm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/driver/mutexrlm"
+	"github.com/dkotik/oakratelimiter/rate"
+	"github.com/dkotik/oakratelimiter/request"
+)
+
+var _ rate.Limiter = (*RateLimiter)(nil) // enforce interface compliance
+
+// Mode tells which [rate.Limiter] is in use.
+type Mode uint32
+
+const (
+	// PrimaryMode delegates to the remote store.
+	PrimaryMode Mode = iota
+
+	// FallbackMode limits in memory until the remote store recovers.
+	FallbackMode
+)
+
+func (m Mode) String() string {
+	if m == FallbackMode {
+		return "fallback"
+	}
+	return "primary"
+}
+
+// New initializes a [RateLimiter] using a list of [Option]s.
+func New(withOptions ...Option) (_ *RateLimiter, err error) {
+	o := &options{}
+	for _, option := range append(
+		withOptions,
+		WithDefaultShare(),
+		WithDefaultLatencyBudget(),
+		WithDefaultProbeInterval(),
+		WithDefaultProbeTag(),
+		WithDefaultProbeContext(),
+		func(o *options) error { // validate
+			if o.Primary == nil {
+				return errors.New("primary rate limiter is required")
+			}
+			if o.Fallback != nil {
+				return nil
+			}
+			primary, burstLimit, err := rate.TagRate(o.ProbeContext, o.Primary, o.ProbeTag)
+			if err != nil {
+				return fmt.Errorf("cannot determine primary rate: %w", err)
+			}
+			shared, sharedBurstLimit, err := share(primary, burstLimit, o.Share)
+			if err != nil {
+				return err
+			}
+			o.Fallback, err = mutexrlm.New(
+				mutexrlm.WithRate(shared),
+				mutexrlm.WithBurst(sharedBurstLimit),
+				mutexrlm.WithCleanupContext(o.ProbeContext),
+			)
+			return err
+		},
+	) {
+		if err = option(o); err != nil {
+			return nil, fmt.Errorf("cannot initialize fallback rate limiter driver: %w", err)
+		}
+	}
+
+	r := &RateLimiter{
+		primary:       o.Primary,
+		fallback:      o.Fallback,
+		latencyBudget: o.LatencyBudget,
+	}
+	go r.probe(o.ProbeContext, o.ProbeInterval, o.ProbeTag)
+	return r, nil
+}
+
+// RateLimiter delegates to the primary [rate.Limiter], until it fails or exceeds the latency budget. Then the fallback takes over until the primary passes a background probe. Tokens taken before a switch are not carried over. A refund goes to the limiter that served the take, if the [context.Context] carries a memo created by [request.NewMemoContext], like the contexts of requests served by [oakratelimiter.RequestHandler]. Otherwise, it goes to the limiter currently in use.
+type RateLimiter struct {
+	primary       rate.Limiter
+	fallback      rate.Limiter
+	latencyBudget time.Duration
+	mode          atomic.Uint32
+}
+
+// Mode returns the [rate.Limiter] currently in use.
+func (r *RateLimiter) Mode() Mode {
+	return Mode(r.mode.Load())
+}
+
+// Rate returns the primary [rate.Rate].
+func (r *RateLimiter) Rate() *rate.Rate {
+	return r.primary.Rate()
+}
+
+// TagRate returns the [rate.Rate] and the burst limit of the primary.
+func (r *RateLimiter) TagRate(ctx context.Context, tag string) (*rate.Rate, float64, error) {
+	return rate.TagRate(ctx, r.primary, tag)
+}
+
+// switchTo changes the mode and logs the change.
+func (r *RateLimiter) switchTo(ctx context.Context, m Mode, cause error) {
+	if Mode(r.mode.Swap(uint32(m))) == m {
+		return // already switched
+	}
+	if m == FallbackMode {
+		slog.Log(ctx, slog.LevelWarn, "rate limiter switched to fallback", slog.Any("error", cause))
+		return
+	}
+	slog.Log(ctx, slog.LevelInfo, "rate limiter switched back to primary")
+}
+
+// failed returns true, if the primary error should switch to fallback. Errors caused by cancelled caller contexts do not count.
+func (r *RateLimiter) failed(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	r.switchTo(ctx, FallbackMode, err)
+	return true
+}
+
+func (r *RateLimiter) Remaining(ctx context.Context, tag string) (float64, error) {
+	if r.Mode() == PrimaryMode {
+		budget, cancel := context.WithTimeout(ctx, r.latencyBudget)
+		remaining, err := r.primary.Remaining(budget, tag)
+		cancel()
+		if !r.failed(ctx, err) {
+			return remaining, err
+		}
+	}
+	return r.fallback.Remaining(ctx, tag)
+}
+
+// served identifies the tag of a take in the request memo.
+type served struct {
+	limiter *RateLimiter
+	tag     string
+}
+
+func (r *RateLimiter) Take(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) (
+	remaining float64,
+	ok bool,
+	err error,
+) {
+	if r.Mode() == PrimaryMode {
+		budget, cancel := context.WithTimeout(ctx, r.latencyBudget)
+		remaining, ok, err = r.primary.Take(budget, tag, tokens)
+		cancel()
+		if !r.failed(ctx, err) {
+			request.Remember(ctx, served{limiter: r, tag: tag}, PrimaryMode)
+			return remaining, ok, err
+		}
+	}
+	request.Remember(ctx, served{limiter: r, tag: tag}, FallbackMode)
+	return r.fallback.Take(ctx, tag, tokens)
+}
+
+// Put returns tokens to the limiter that served the take.
+func (r *RateLimiter) Put(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) error {
+	m := r.Mode()
+	if value, ok := request.Recall(ctx, served{limiter: r, tag: tag}); ok {
+		m, _ = value.(Mode)
+	}
+	if m == FallbackMode {
+		return r.fallback.Put(ctx, tag, tokens)
+	}
+	budget, cancel := context.WithTimeout(ctx, r.latencyBudget)
+	err := r.primary.Put(budget, tag, tokens)
+	cancel()
+	r.failed(ctx, err)
+	return err
+}
+
+// probe checks the primary while the fallback is in use.
+func (r *RateLimiter) probe(ctx context.Context, every time.Duration, tag string) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if r.Mode() == PrimaryMode {
+				continue
+			}
+			budget, cancel := context.WithTimeout(ctx, r.latencyBudget)
+			_, err := r.primary.Remaining(budget, tag)
+			cancel()
+			if err == nil {
+				r.switchTo(ctx, PrimaryMode, nil)
+			}
+		}
+	}
+}
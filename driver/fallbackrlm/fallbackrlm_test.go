@@ -0,0 +1,176 @@
+package fallbackrlm
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/driver/mutexrlm"
+	"github.com/dkotik/oakratelimiter/rate"
+	"github.com/dkotik/oakratelimiter/request"
+	"github.com/dkotik/oakratelimiter/test"
+)
+
+// unreliableLimiter fails or stalls on demand.
+type unreliableLimiter struct {
+	rate.Limiter
+	broken atomic.Bool
+	stall  atomic.Bool
+}
+
+func (u *unreliableLimiter) check(ctx context.Context) error {
+	if u.stall.Load() {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if u.broken.Load() {
+		return errors.New("database is unreachable")
+	}
+	return nil
+}
+
+func (u *unreliableLimiter) Remaining(ctx context.Context, tag string) (float64, error) {
+	if err := u.check(ctx); err != nil {
+		return 0, err
+	}
+	return u.Limiter.Remaining(ctx, tag)
+}
+
+func (u *unreliableLimiter) Take(ctx context.Context, tag string, tokens float64) (float64, bool, error) {
+	if err := u.check(ctx); err != nil {
+		return 0, false, err
+	}
+	return u.Limiter.Take(ctx, tag, tokens)
+}
+
+func newUnreliableLimiter(t *testing.T, tokens float64, interval time.Duration) *unreliableLimiter {
+	l, err := mutexrlm.New(mutexrlm.WithNewRate(tokens, interval))
+	if err != nil {
+		t.Fatal("cannot initialize primary rate limiter:", err)
+	}
+	return &unreliableLimiter{Limiter: l}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter, err := New(WithPrimary(newUnreliableLimiter(t, 8, time.Millisecond*20)))
+	if err != nil {
+		t.Fatal("cannot initialize rate limiter:", err)
+	}
+	test.RateLimiterTest(context.Background(), limiter, 8)(t)
+}
+
+func TestFallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	primary := newUnreliableLimiter(t, 4, time.Minute)
+	limiter, err := New(
+		WithPrimary(primary),
+		WithShare(0.5),
+		WithLatencyBudget(time.Millisecond*20),
+		WithProbeInterval(time.Millisecond*20),
+		WithProbeContext(ctx),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize rate limiter:", err)
+	}
+
+	primary.broken.Store(true)
+	for i := 0; i < 2; i++ {
+		if _, ok, err := limiter.Take(ctx, "test", 1); err != nil || !ok {
+			t.Fatal("fallback rejected a request within its share:", err)
+		}
+	}
+	if limiter.Mode() != FallbackMode {
+		t.Fatal("rate limiter did not switch to fallback")
+	}
+	if _, ok, err := limiter.Take(ctx, "test", 1); err != nil || ok {
+		t.Fatal("fallback did not apply its share of the rate:", err)
+	}
+
+	primary.broken.Store(false)
+	time.Sleep(time.Millisecond * 100)
+	if limiter.Mode() != PrimaryMode {
+		t.Fatal("rate limiter did not switch back to primary")
+	}
+
+	primary.stall.Store(true)
+	started := time.Now()
+	if _, _, err := limiter.Take(ctx, "slow", 1); err != nil {
+		t.Fatal("slow primary was not replaced by fallback:", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Millisecond*200 {
+		t.Fatal("latency budget was not enforced:", elapsed)
+	}
+	if limiter.Mode() != FallbackMode {
+		t.Fatal("slow primary did not switch to fallback")
+	}
+}
+
+func TestRefundAfterSwitch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	primary := newUnreliableLimiter(t, 4, time.Hour)
+	limiter, err := New(
+		WithPrimary(primary),
+		WithProbeInterval(time.Minute),
+		WithProbeContext(ctx),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize rate limiter:", err)
+	}
+
+	served := request.NewMemoContext(ctx)
+	if _, ok, err := limiter.Take(served, "test", 1); err != nil || !ok {
+		t.Fatal("primary rejected a request:", err)
+	}
+	primary.broken.Store(true)
+	if _, _, err := limiter.Take(ctx, "other", 1); err != nil {
+		t.Fatal(err)
+	}
+	if limiter.Mode() != FallbackMode {
+		t.Fatal("rate limiter did not switch to fallback")
+	}
+	primary.broken.Store(false)
+
+	if err = limiter.Put(served, "test", 1); err != nil {
+		t.Fatal(err)
+	}
+	if remaining, err := primary.Limiter.Remaining(ctx, "test"); err != nil || remaining != 4 {
+		t.Fatal("refund did not reach the primary:", remaining, err)
+	}
+	if remaining, err := limiter.fallback.Remaining(ctx, "test"); err != nil || remaining != 4 {
+		t.Fatal("refund reached the fallback:", remaining, err)
+	}
+}
+
+func TestShareTooSmall(t *testing.T) {
+	primary := newUnreliableLimiter(t, 4, time.Minute)
+	if _, err := New(WithPrimary(primary), WithShare(0.2)); err == nil {
+		t.Fatal("share below one token was accepted")
+	}
+	if _, err := New(WithShare(0.2), WithPrimary(primary)); err == nil {
+		t.Fatal("share below one token was accepted before the primary was set")
+	}
+}
+
+func TestShareBurstLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	primary, err := mutexrlm.New(mutexrlm.WithNewRate(4, time.Minute), mutexrlm.WithBurst(8))
+	if err != nil {
+		t.Fatal("cannot initialize primary rate limiter:", err)
+	}
+	limiter, err := New(WithPrimary(primary), WithShare(0.5), WithProbeContext(ctx))
+	if err != nil {
+		t.Fatal("cannot initialize rate limiter:", err)
+	}
+	shared, burstLimit, err := rate.TagRate(ctx, limiter.fallback, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared.Burst() != 2 || burstLimit != 4 {
+		t.Fatal("fallback does not share the primary burst limit:", shared, burstLimit)
+	}
+}
@@ -0,0 +1,184 @@
+package fallbackrlm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+type options struct {
+	Primary       rate.Limiter
+	Fallback      rate.Limiter
+	Share         float64
+	LatencyBudget time.Duration
+	ProbeInterval time.Duration
+	ProbeTag      string
+	ProbeContext  context.Context
+}
+
+// Option configures the fallback rate limiter.
+type Option func(*options) error
+
+// WithPrimary sets the remote [rate.Limiter], like a database driver, that is used while it is healthy.
+func WithPrimary(l rate.Limiter) Option {
+	return func(o *options) error {
+		if l == nil {
+			return errors.New("cannot use a <nil> primary rate limiter")
+		}
+		if o.Primary != nil {
+			return errors.New("primary rate limiter is already set")
+		}
+		o.Primary = l
+		return nil
+	}
+}
+
+// WithFallback sets the [rate.Limiter] that takes over when the primary fails. Use it instead of [WithShare] for full control over the fallback.
+func WithFallback(l rate.Limiter) Option {
+	return func(o *options) error {
+		if l == nil {
+			return errors.New("cannot use a <nil> fallback rate limiter")
+		}
+		if o.Fallback != nil || o.Share != 0 {
+			return errors.New("fallback rate limiter is already set")
+		}
+		o.Fallback = l
+		return nil
+	}
+}
+
+// WithShare creates an in-memory fallback [rate.Limiter] with a fraction of the primary rate and burst limit. When several instances share one remote store, each should take over only its portion of the limit, like 0.25 for four instances. The fraction must leave the fallback at least one token per interval. If the primary applies different rates to different tags, the rate of the probe tag is shared.
+func WithShare(fraction float64) Option {
+	return func(o *options) error {
+		if fraction <= 0 || fraction > 1 {
+			return fmt.Errorf("share %f must be greater than zero and not exceed one", fraction)
+		}
+		if o.Fallback != nil || o.Share != 0 {
+			return errors.New("fallback rate limiter is already set")
+		}
+		o.Share = fraction
+		return nil
+	}
+}
+
+// share scales the [rate.Rate] and the burst limit of the primary by the fraction.
+func share(primary *rate.Rate, burstLimit, fraction float64) (*rate.Rate, float64, error) {
+	if primary.Burst()*fraction < 1 || burstLimit*fraction < 1 {
+		return nil, 0, fmt.Errorf("share %f of rate %q with burst limit %.2f leaves the fallback less than one token", fraction, primary, burstLimit)
+	}
+	shared, err := rate.New(primary.Burst()*fraction, primary.Interval())
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot share rate %q: %w", primary, err)
+	}
+	return shared, burstLimit * fraction, nil
+}
+
+// WithDefaultShare gives the in-memory fallback the full primary rate, if no other fallback was set.
+func WithDefaultShare() Option {
+	return func(o *options) error {
+		if o.Fallback != nil || o.Share != 0 {
+			return nil // already set
+		}
+		return WithShare(1)(o)
+	}
+}
+
+// WithLatencyBudget sets the longest time a call to the primary may take. Slower calls are abandoned and count as failures.
+func WithLatencyBudget(d time.Duration) Option {
+	return func(o *options) error {
+		if d <= 0 {
+			return errors.New("latency budget must be greater than zero")
+		}
+		if o.LatencyBudget != 0 {
+			return errors.New("latency budget is already set")
+		}
+		o.LatencyBudget = d
+		return nil
+	}
+}
+
+// WithDefaultLatencyBudget sets latency budget to 100 milliseconds.
+func WithDefaultLatencyBudget() Option {
+	return func(o *options) error {
+		if o.LatencyBudget != 0 {
+			return nil // already set
+		}
+		return WithLatencyBudget(time.Millisecond * 100)(o)
+	}
+}
+
+// WithProbeInterval sets how often the primary is checked for recovery while the fallback is in use.
+func WithProbeInterval(d time.Duration) Option {
+	return func(o *options) error {
+		if d < time.Millisecond*10 {
+			return errors.New("probe interval must not be less than 10 milliseconds")
+		}
+		if o.ProbeInterval != 0 {
+			return errors.New("probe interval is already set")
+		}
+		o.ProbeInterval = d
+		return nil
+	}
+}
+
+// WithDefaultProbeInterval sets probe interval to 5 seconds.
+func WithDefaultProbeInterval() Option {
+	return func(o *options) error {
+		if o.ProbeInterval != 0 {
+			return nil // already set
+		}
+		return WithProbeInterval(time.Second * 5)(o)
+	}
+}
+
+// WithProbeTag sets the tag used to check the primary for recovery. Probing only reads the remaining tokens.
+func WithProbeTag(tag string) Option {
+	return func(o *options) error {
+		if tag == "" {
+			return errors.New("cannot use an empty probe tag")
+		}
+		if o.ProbeTag != "" {
+			return errors.New("probe tag is already set")
+		}
+		o.ProbeTag = tag
+		return nil
+	}
+}
+
+// WithDefaultProbeTag sets probe tag to "oakratelimiter:probe".
+func WithDefaultProbeTag() Option {
+	return func(o *options) error {
+		if o.ProbeTag != "" {
+			return nil // already set
+		}
+		return WithProbeTag("oakratelimiter:probe")(o)
+	}
+}
+
+// WithProbeContext provides the [context.Context] for background probing. When the context is cancelled, probing stops. It is also passed to the in-memory fallback as its clean up context.
+func WithProbeContext(ctx context.Context) Option {
+	return func(o *options) error {
+		if ctx == nil {
+			return fmt.Errorf("cannot use a %q probe context", ctx)
+		}
+		if o.ProbeContext != nil {
+			return errors.New("probe context is already set")
+		}
+		o.ProbeContext = ctx
+		return nil
+	}
+}
+
+// WithDefaultProbeContext passes [context.Background] to [WithProbeContext] option.
+func WithDefaultProbeContext() Option {
+	return func(o *options) error {
+		if o.ProbeContext != nil {
+			return nil // already set
+		}
+		o.ProbeContext = context.Background()
+		return nil
+	}
+}
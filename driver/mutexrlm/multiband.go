@@ -0,0 +1,241 @@
+package mutexrlm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+var ( // enforce interface compliance
+	_ rate.Limiter  = (*MultiBandRateLimiter)(nil)
+	_ rate.Delayer  = (*MultiBandRateLimiter)(nil)
+	_ rate.TagRater = (*MultiBandRateLimiter)(nil)
+)
+
+// NewMultiBand initializes a [MultiBandRateLimiter] with at least two [WithBand] options.
+func NewMultiBand(withOptions ...Option) (*MultiBandRateLimiter, error) {
+	o := &options{}
+	for _, option := range append(
+		withOptions,
+		WithDefaultInitialAllocationSize(),
+		WithDefaultCleanupInterval(),
+		WithDefaultCleanupContext(),
+		func(o *options) error { // validate
+			if o.Coster != nil {
+				return errors.New("coster option does not apply to a rate limiter")
+			}
+			if o.Rate != nil || o.Burst != 0 {
+				return errors.New("rate and burst options do not apply to a multi-band rate limiter")
+			}
+			if len(o.Bands) < 2 {
+				return errors.New("at least two bands are required")
+			}
+			return nil
+		},
+	) {
+		if err := option(o); err != nil {
+			return nil, fmt.Errorf("cannot initialize mutex multi-band rate limiter driver: %w", err)
+		}
+	}
+
+	m := &MultiBandRateLimiter{
+		bands: o.Bands,
+		rate:  o.Bands[0].Rate,
+		mu:    sync.Mutex{},
+		buckets: make(
+			map[string][]rate.LeakyBucket,
+			o.InitialAllocationSize,
+		),
+	}
+	burstLimit := o.Bands[0].Burst
+	for _, band := range o.Bands[1:] {
+		if band.Burst < burstLimit {
+			m.rate, burstLimit = band.Rate, band.Burst
+		}
+	}
+
+	go func(ctx context.Context, every time.Duration, m *MultiBandRateLimiter) {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case t := <-ticker.C:
+				m.Purge(t)
+			}
+		}
+	}(o.CleanupContext, o.CleanupInterval, m)
+
+	return m, nil
+}
+
+// MultiBandRateLimiter keeps a [rate.LeakyBucket] for every [rate.Band] of each tag. Unlike [rate.MultiBandLimiter], all bands are checked and consumed under one lock, so concurrent requests never see tokens that are about to be returned.
+type MultiBandRateLimiter struct {
+	bands []rate.Band
+	rate  *rate.Rate
+
+	mu      sync.Mutex
+	buckets map[string][]rate.LeakyBucket
+}
+
+// Rate returns the [rate.Rate] of the band with the lowest burst limit, which binds a tag that did not use any tokens yet. Use [MultiBandRateLimiter.TagRate] to find the band that binds a given tag.
+func (m *MultiBandRateLimiter) Rate() *rate.Rate {
+	return m.rate
+}
+
+// TagRate returns the [rate.Rate] and the burst limit of the band with the fewest remaining tokens for the tag, so that the reported limit matches the reported remaining tokens. Ties go to the band with the lower burst limit.
+func (m *MultiBandRateLimiter) TagRate(ctx context.Context, tag string) (*rate.Rate, float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buckets := m.refilled(time.Now(), tag)
+	binding, least := 0, float64(0)
+	for i, band := range m.bands {
+		remaining := band.Burst
+		if buckets != nil {
+			remaining = buckets[i].Remaining()
+		}
+		if i == 0 || remaining < least || (remaining == least && band.Burst < m.bands[binding].Burst) {
+			binding, least = i, remaining
+		}
+	}
+	return m.bands[binding].Rate, m.bands[binding].Burst, nil
+}
+
+// refilled returns the buckets of a tag refilled to a given time. Returns <nil>, if the tag has no buckets, which means they are full. Must run inside mutex lock.
+func (m *MultiBandRateLimiter) refilled(at time.Time, tag string) []rate.LeakyBucket {
+	buckets, ok := m.buckets[tag]
+	if !ok {
+		return nil
+	}
+	for i, band := range m.bands {
+		buckets[i].Refill(at, band.Rate, band.Burst)
+	}
+	return buckets
+}
+
+// Remaining returns the fewest tokens left in any band.
+func (m *MultiBandRateLimiter) Remaining(ctx context.Context, tag string) (least float64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buckets := m.refilled(time.Now(), tag)
+	for i, band := range m.bands {
+		remaining := band.Burst
+		if buckets != nil {
+			remaining = buckets[i].Remaining()
+		}
+		if i == 0 || remaining < least {
+			least = remaining
+		}
+	}
+	return least, nil
+}
+
+// Take consumes tokens from every band, if every band has them. The remaining tokens are the fewest of all bands.
+func (m *MultiBandRateLimiter) Take(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) (
+	remaining float64,
+	ok bool,
+	err error,
+) {
+	t := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buckets := m.refilled(t, tag)
+	if buckets == nil {
+		buckets = make([]rate.LeakyBucket, len(m.bands))
+		for i, band := range m.bands {
+			buckets[i] = *rate.NewLeakyBucket(t, band.Rate, band.Burst)
+		}
+		m.buckets[tag] = buckets
+	}
+	ok = true
+	for i := range buckets {
+		left := buckets[i].Remaining()
+		if i == 0 || left < remaining {
+			remaining = left
+		}
+		if left < tokens {
+			ok = false
+		}
+	}
+	if !ok {
+		return remaining, false, nil
+	}
+	for i := range buckets {
+		left, _ := buckets[i].Take(tokens)
+		if i == 0 || left < remaining {
+			remaining = left
+		}
+	}
+	return remaining, true, nil
+}
+
+// Delay returns the longest time it takes for the tokens to become available in every band.
+func (m *MultiBandRateLimiter) Delay(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) (longest time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buckets := m.refilled(time.Now(), tag)
+	for i, band := range m.bands {
+		delay := band.Rate.ReplenishmentDuration(tokens - band.Burst)
+		if buckets != nil {
+			delay = buckets[i].Delay(band.Rate, tokens)
+		}
+		longest = max(longest, delay)
+	}
+	return longest, nil
+}
+
+// Put returns tokens to every band. If the tag has no buckets, they are already full.
+func (m *MultiBandRateLimiter) Put(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buckets := m.refilled(time.Now(), tag)
+	if buckets == nil {
+		return nil // full
+	}
+	for i, band := range m.bands {
+		buckets[i].Put(tokens, band.Burst)
+	}
+	return nil
+}
+
+// Purge removes the buckets of tags that were not touched within the interval of any band by given [time.Time].
+func (m *MultiBandRateLimiter) Purge(at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for tag, buckets := range m.buckets {
+		expired := true
+		for i, band := range m.bands {
+			if !buckets[i].Touched().Before(at.Add(-band.Rate.Interval())) {
+				expired = false
+				break
+			}
+		}
+		if expired {
+			delete(m.buckets, tag)
+		}
+	}
+}
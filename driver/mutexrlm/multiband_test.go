@@ -0,0 +1,125 @@
+package mutexrlm
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+func TestMultiBandRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter, err := NewMultiBand(
+		WithNewBand(100, time.Hour, 0),
+		WithNewBand(4, time.Hour, 0),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize multi-band rate limiter:", err)
+	}
+	if limiter.Rate().Burst() != 4 {
+		t.Fatal("rate is not the tightest band:", limiter.Rate())
+	}
+	multiBandTest(ctx, t, limiter)
+
+	if _, err = NewMultiBand(WithNewBand(4, time.Hour, 0)); err == nil {
+		t.Fatal("single band was accepted")
+	}
+	if _, err = New(WithNewRate(4, time.Hour), WithNewBand(4, time.Hour, 0)); err == nil {
+		t.Fatal("band was accepted by a single-band rate limiter")
+	}
+}
+
+func TestMultiBandLimiter(t *testing.T) {
+	ctx := context.Background()
+	loose, err := New(WithNewRate(100, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tight, err := New(WithNewRate(4, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter, err := rate.NewMultiBandLimiter(loose, tight)
+	if err != nil {
+		t.Fatal("cannot initialize multi-band rate limiter:", err)
+	}
+	multiBandTest(ctx, t, limiter)
+
+	remaining, err := loose.Remaining(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Round(remaining) != 96 {
+		t.Fatal("rejected requests took tokens from the loose band:", remaining)
+	}
+}
+
+func multiBandTest(ctx context.Context, t *testing.T, limiter rate.Limiter) {
+	remaining, ok, err := limiter.Take(ctx, "test", 3)
+	if err != nil || !ok {
+		t.Fatal("rate limiter blocked unexpectedly:", err)
+	}
+	if math.Round(remaining) != 1 {
+		t.Fatal("remaining tokens are not of the tightest band:", remaining)
+	}
+	if _, ok, err = limiter.Take(ctx, "test", 2); err != nil || ok {
+		t.Fatal("tight band did not reject:", err)
+	}
+	if remaining, err = limiter.Remaining(ctx, "test"); err != nil || math.Round(remaining) != 1 {
+		t.Fatal("rejected request consumed tokens:", remaining, err)
+	}
+	delay, err := rate.Delay(ctx, limiter, "test", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delay < time.Minute*14 || delay > time.Minute*15 {
+		t.Fatal("delay is not of the tightest band:", delay)
+	}
+	if _, ok, err = limiter.Take(ctx, "test", 1); err != nil || !ok {
+		t.Fatal("rate limiter blocked unexpectedly:", err)
+	}
+}
+
+func TestMultiBandTagRate(t *testing.T) {
+	ctx := context.Background()
+	native, err := NewMultiBand(
+		WithNewBand(100, time.Second, 10),
+		WithNewBand(12, time.Hour, 0),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize multi-band rate limiter:", err)
+	}
+	fast, err := New(WithNewRate(100, time.Second), WithBurst(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow, err := New(WithNewRate(12, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	composed, err := rate.NewMultiBandLimiter(fast, slow)
+	if err != nil {
+		t.Fatal("cannot initialize multi-band rate limiter:", err)
+	}
+
+	for _, limiter := range []rate.Limiter{native, composed} {
+		if _, burstLimit, err := rate.TagRate(ctx, limiter, "test"); err != nil || burstLimit != 10 {
+			t.Fatal("fresh tag is not bound by the band with the lowest burst limit:", burstLimit, err)
+		}
+		if _, ok, err := limiter.Take(ctx, "test", 8); err != nil || !ok {
+			t.Fatal("rate limiter blocked unexpectedly:", err)
+		}
+	}
+	time.Sleep(time.Millisecond * 100) // the fast band refills completely
+	for _, limiter := range []rate.Limiter{native, composed} {
+		r, burstLimit, err := rate.TagRate(ctx, limiter, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Interval() != time.Hour || burstLimit != 12 {
+			t.Fatal("tag is not bound by the band with the fewest remaining tokens:", r, burstLimit)
+		}
+	}
+}
@@ -5,7 +5,10 @@ package mutexrlm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
 	"sync"
 	"time"
 
@@ -21,6 +24,15 @@ func New(withOptions ...Option) (*RateLimiter, error) {
 		WithDefaultInitialAllocationSize(),
 		WithDefaultCleanupInterval(),
 		WithDefaultCleanupContext(),
+		func(o *options) error { // validate
+			if o.Coster != nil {
+				return errors.New("coster option does not apply to a rate limiter")
+			}
+			if len(o.Bands) > 0 {
+				return errors.New("band option does not apply to a single-band rate limiter")
+			}
+			return nil
+		},
 	) {
 		if err := option(o); err != nil {
 			return nil, fmt.Errorf("cannot initialize mutex rate limiter driver: %w", err)
@@ -63,9 +75,36 @@ type RateLimiter struct {
 }
 
 func (r *RateLimiter) Rate() *rate.Rate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.rate
 }
 
+// TagRate returns the [rate.Rate] and the burst limit, which are the same for every tag.
+func (r *RateLimiter) TagRate(context.Context, string) (*rate.Rate, float64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rate, r.burstLimit, nil
+}
+
+// SetRate replaces the [rate.Rate] and the burst limit. Every bucket is rescaled to keep the same fill ratio, so that a tag that used up half of its tokens still has half of the new burst limit. Zero burst limit is replaced by [rate.Rate.Burst].
+func (r *RateLimiter) SetRate(to *rate.Rate, burstLimit float64) (err error) {
+	if burstLimit, err = rate.ValidateBurst(to, burstLimit); err != nil {
+		return err
+	}
+	t := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, bucket := range r.buckets {
+		bucket.Refill(t, r.rate, r.burstLimit)
+		bucket.Rescale(r.burstLimit, burstLimit)
+	}
+	r.rate = to
+	r.burstLimit = burstLimit
+	return nil
+}
+
 // Remaining locates the proper [rate.LeakyBucket] by tag returns the number of tokens still in it. If the bucket does not exist, returns the burst limit.
 func (r *RateLimiter) Remaining(
 	ctx context.Context,
@@ -115,11 +154,110 @@ func (r *RateLimiter) Take(
 	return
 }
 
+// Delay locates the proper [rate.LeakyBucket] by tag and returns the time it takes to replenish the missing tokens. If the bucket does not exist, it is full.
+func (r *RateLimiter) Delay(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) (time.Duration, error) {
+	t := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	foundBucket, ok := r.buckets[tag]
+	if !ok {
+		return r.rate.ReplenishmentDuration(tokens - r.burstLimit), nil
+	}
+	foundBucket.Refill(t, r.rate, r.burstLimit)
+	return foundBucket.Delay(r.rate, tokens), nil
+}
+
+// Put locates the proper [rate.LeakyBucket] by tag and returns tokens to it. If the bucket does not exist, it is already full.
+func (r *RateLimiter) Put(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) error {
+	t := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	foundBucket, ok := r.buckets[tag]
+	if !ok {
+		return nil // full
+	}
+	foundBucket.Refill(t, r.rate, r.burstLimit)
+	foundBucket.Put(tokens, r.burstLimit)
+	return nil
+}
+
+// Buckets lists the tags that have a [rate.LeakyBucket], sorted by tag. Buckets of idle tags are removed by [RateLimiter.Purge].
+func (r *RateLimiter) Buckets(ctx context.Context) ([]rate.Bucket, error) {
+	t := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	buckets := make([]rate.Bucket, 0, len(r.buckets))
+	for tag, bucket := range r.buckets {
+		bucket.Refill(t, r.rate, r.burstLimit)
+		buckets = append(buckets, rate.Bucket{
+			Tag:       tag,
+			Remaining: bucket.Remaining(),
+			Touched:   bucket.Touched(),
+		})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Tag < buckets[j].Tag
+	})
+	return buckets, nil
+}
+
+// Reset removes the [rate.LeakyBucket] of a tag, so that the tag has all of its tokens.
+func (r *RateLimiter) Reset(ctx context.Context, tag string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.buckets, tag)
+	return nil
+}
+
+// Adjust grants tokens to a tag, or revokes them, if the amount is negative. The remaining tokens stay between zero and the burst limit.
+func (r *RateLimiter) Adjust(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) (
+	remaining float64,
+	err error,
+) {
+	t := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	foundBucket, ok := r.buckets[tag]
+	if !ok {
+		if tokens >= 0 {
+			return r.burstLimit, nil // full
+		}
+		foundBucket = rate.NewLeakyBucket(t, r.rate, r.burstLimit)
+		r.buckets[tag] = foundBucket
+	} else {
+		foundBucket.Refill(t, r.rate, r.burstLimit)
+	}
+	if tokens >= 0 {
+		return foundBucket.Put(tokens, r.burstLimit), nil
+	}
+	remaining, _ = foundBucket.Take(math.Min(-tokens, foundBucket.Remaining()))
+	return remaining, nil
+}
+
 // Purge removes all tokens that are expired by given [time.Time].
 func (r *RateLimiter) Purge(at time.Time) {
-	at = at.Add(-r.rate.Interval())
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	at = at.Add(-r.rate.Interval())
 
 	for k, bucket := range r.buckets {
 		if bucket.Touched().Before(at) {
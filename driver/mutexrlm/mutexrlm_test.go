@@ -2,9 +2,11 @@ package mutexrlm
 
 import (
 	"context"
+	"math"
 	"testing"
 	"time"
 
+	"github.com/dkotik/oakratelimiter/rate"
 	"github.com/dkotik/oakratelimiter/test"
 )
 
@@ -15,3 +17,45 @@ func TestRateLimiter(t *testing.T) {
 	}
 	test.RateLimiterTest(context.Background(), limiter, 8)(t)
 }
+
+func TestRateLimiterRefund(t *testing.T) {
+	limiter, err := New(WithNewRate(8, time.Second))
+	if err != nil {
+		t.Fatal("cannot initialize request limiter:", err)
+	}
+	test.RateLimiterRefundTest(context.Background(), limiter, "test")(t)
+}
+
+func TestRateLimiterSetRate(t *testing.T) {
+	ctx := context.Background()
+	limiter, err := New(WithNewRate(4, time.Hour))
+	if err != nil {
+		t.Fatal("cannot initialize request limiter:", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, ok, err := limiter.Take(ctx, "test", 1); err != nil || !ok {
+			t.Fatal("rate limiter blocked unexpectedly:", err)
+		}
+	}
+
+	r, err := rate.New(8, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = limiter.SetRate(r, 0); err != nil {
+		t.Fatal("cannot set rate:", err)
+	}
+	remaining, err := limiter.Remaining(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Round(remaining) != 4 {
+		t.Fatal("half-empty bucket was not rescaled to half of the new burst:", remaining)
+	}
+	if limiter.Rate() != r {
+		t.Fatal("rate was not replaced")
+	}
+	if err = limiter.SetRate(nil, 0); err == nil {
+		t.Fatal("<nil> rate was accepted")
+	}
+}
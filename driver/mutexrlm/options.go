@@ -7,11 +7,14 @@ import (
 	"time"
 
 	"github.com/dkotik/oakratelimiter/rate"
+	"github.com/dkotik/oakratelimiter/request"
 )
 
 type options struct {
 	Rate                  *rate.Rate
 	Burst                 float64
+	Bands                 []rate.Band
+	Coster                request.Coster
 	InitialAllocationSize int
 	CleanupInterval       time.Duration
 	CleanupContext        context.Context
@@ -73,6 +76,52 @@ func WithDefaultBurst() Option {
 	}
 }
 
+// WithBand adds a [rate.Band] to a [MultiBandRateLimiter]. Zero burst limit is replaced by [rate.Rate.Burst].
+func WithBand(r *rate.Rate, burstLimit float64) Option {
+	return func(o *options) (err error) {
+		if burstLimit, err = rate.ValidateBurst(r, burstLimit); err != nil {
+			return fmt.Errorf("invalid band: %w", err)
+		}
+		o.Bands = append(o.Bands, rate.Band{Rate: r, Burst: burstLimit})
+		return nil
+	}
+}
+
+// WithNewBand creates a [rate.Rate] to pass to [WithBand] option.
+func WithNewBand(limit float64, interval time.Duration, burstLimit float64) Option {
+	return func(o *options) error {
+		r, err := rate.New(limit, interval)
+		if err != nil {
+			return fmt.Errorf("cannot use new band rate: %w", err)
+		}
+		return WithBand(r, burstLimit)(o)
+	}
+}
+
+// WithCoster determines how many tokens each request takes from a request limiter. It does not apply to [RateLimiter], which receives the number of tokens from the caller.
+func WithCoster(c request.Coster) Option {
+	return func(o *options) error {
+		if c == nil {
+			return errors.New("cannot use a <nil> coster")
+		}
+		if o.Coster != nil {
+			return errors.New("coster is already set")
+		}
+		o.Coster = c
+		return nil
+	}
+}
+
+// WithDefaultCoster charges one token per request, if no coster was provided by another option.
+func WithDefaultCoster() Option {
+	return func(o *options) error {
+		if o.Coster != nil {
+			return nil // already set
+		}
+		return WithCoster(request.UnitCost)(o)
+	}
+}
+
 // WithInitialAllocationSize sets the number of pre-allocated items for a tagged bucket map. Higher number can improve starting performance at the cost of using more memory.
 func WithInitialAllocationSize(buckets int) Option {
 	return func(o *options) error {
@@ -16,6 +16,7 @@ func NewRequestLimiter(withOptions ...Option) (request.Limiter, error) {
 	for _, option := range append(
 		withOptions,
 		WithDefaultBurst(),
+		WithDefaultCoster(),
 		func(o *options) error { // validate
 			if o.InitialAllocationSize != 0 {
 				return errors.New("initial allocation option does not apply to a request limiter")
@@ -26,6 +27,9 @@ func NewRequestLimiter(withOptions ...Option) (request.Limiter, error) {
 			if o.CleanupInterval != 0 {
 				return errors.New("clean up interval option does not apply to a request limiter")
 			}
+			if len(o.Bands) > 0 {
+				return errors.New("band option does not apply to a request limiter")
+			}
 			return nil
 		},
 	) {
@@ -36,6 +40,7 @@ func NewRequestLimiter(withOptions ...Option) (request.Limiter, error) {
 	return &requestLimiter{
 		rate:       o.Rate,
 		burstLimit: o.Burst,
+		coster:     o.Coster,
 		mu:         sync.Mutex{},
 		bucket:     *rate.NewLeakyBucket(time.Now(), o.Rate, o.Burst),
 	}, nil
@@ -44,6 +49,7 @@ func NewRequestLimiter(withOptions ...Option) (request.Limiter, error) {
 type requestLimiter struct {
 	rate       *rate.Rate
 	burstLimit float64
+	coster     request.Coster
 
 	mu     sync.Mutex
 	bucket rate.LeakyBucket
@@ -51,19 +57,79 @@ type requestLimiter struct {
 
 // Rate returns the rate limiter [rate.Rate].
 func (l *requestLimiter) Rate() *rate.Rate {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	return l.rate
 }
 
-// Take consumes one token per request.
+// RequestRate returns the [rate.Rate] and the burst limit.
+func (l *requestLimiter) RequestRate(*http.Request) (*rate.Rate, float64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate, l.burstLimit, nil
+}
+
+// SetRate replaces the [rate.Rate] and the burst limit. The bucket is rescaled to keep the same fill ratio. Zero burst limit is replaced by [rate.Rate.Burst].
+func (l *requestLimiter) SetRate(to *rate.Rate, burstLimit float64) (err error) {
+	if burstLimit, err = rate.ValidateBurst(to, burstLimit); err != nil {
+		return err
+	}
+	t := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bucket.Refill(t, l.rate, l.burstLimit)
+	l.bucket.Rescale(l.burstLimit, burstLimit)
+	l.rate = to
+	l.burstLimit = burstLimit
+	return nil
+}
+
+// Cost returns the tokens determined by the [request.Coster].
+func (l *requestLimiter) Cost(r *http.Request) (float64, error) {
+	return l.coster(r)
+}
+
+// Take consumes tokens determined by the [request.Coster] per request.
 func (l *requestLimiter) Take(r *http.Request) (
 	remaining float64,
 	ok bool,
 	err error,
 ) {
+	tokens, err := l.coster(r)
+	if err != nil {
+		return 0, false, err
+	}
 	t := time.Now()
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	l.bucket.Refill(t, l.rate, l.burstLimit)
-	remaining, ok = l.bucket.Take(1.0)
+	remaining, ok = l.bucket.Take(tokens)
 	return
 }
+
+// Delay returns the time it takes to replenish the tokens missing for a request.
+func (l *requestLimiter) Delay(r *http.Request) (time.Duration, error) {
+	tokens, err := l.coster(r)
+	if err != nil {
+		return 0, err
+	}
+	t := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bucket.Refill(t, l.rate, l.burstLimit)
+	return l.bucket.Delay(l.rate, tokens), nil
+}
+
+// Put returns tokens taken by a request.
+func (l *requestLimiter) Put(r *http.Request) error {
+	tokens, err := l.coster(r)
+	if err != nil {
+		return err
+	}
+	t := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bucket.Refill(t, l.rate, l.burstLimit)
+	l.bucket.Put(tokens, l.burstLimit)
+	return nil
+}
@@ -0,0 +1,35 @@
+package postgresrlm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+// NewFromSettings creates a [RateLimiter] from driver settings of the config package. Register it explicitly, like `config.RegisterDriver("postgres", postgresrlm.NewFromSettings)`, because storage drivers do not depend on the config package. Settings: "url", "urlFromEnvironment", "table", and "cleanupInterval". Without a URL, the connection is taken from the `DATABASE_URL` environment variable.
+func NewFromSettings(r *rate.Rate, burst float64, settings map[string]string) (rate.Limiter, error) {
+	withOptions := []Option{WithRate(r)}
+	if burst != 0 {
+		withOptions = append(withOptions, WithBurst(burst))
+	}
+	for key, value := range settings {
+		switch key {
+		case "url":
+			withOptions = append(withOptions, WithDatabaseURL(value))
+		case "urlFromEnvironment":
+			withOptions = append(withOptions, WithDatabaseFromEnvironment(value))
+		case "table":
+			withOptions = append(withOptions, WithTable(value))
+		case "cleanupInterval":
+			interval, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid setting %q: %w", key, err)
+			}
+			withOptions = append(withOptions, WithCleanupInterval(interval))
+		default:
+			return nil, fmt.Errorf("unknown setting %q", key)
+		}
+	}
+	return New(withOptions...)
+}
@@ -1,8 +1,11 @@
 module github.com/dkotik/oakratelimiter/postgresrlm
 
-go 1.21.0
+go 1.22.0
 
 require (
-	github.com/dkotik/oakratelimiter v0.0.2 // indirect
-	github.com/lib/pq v1.10.9 // indirect
+	github.com/dkotik/oakratelimiter v0.0.2
+	github.com/lib/pq v1.10.9
 )
+
+// The driver builds against the oakratelimiter module in this repository until its next release.
+replace github.com/dkotik/oakratelimiter => ../..
@@ -19,6 +19,7 @@ type options struct {
 	Table           string
 	Rate            *rate.Rate
 	Burst           float64
+	Counter         bool
 	CleanupInterval time.Duration
 	CleanupContext  context.Context
 }
@@ -98,16 +99,30 @@ func WithTable(name string) Option {
 	}
 }
 
-// WithDefaultTable sets [WithTable] to `oakratelimiter`.
+// WithDefaultTable sets [WithTable] to `oakratelimiter`, or to `oakratelimiter_counter` with [WithSlidingWindowCounter].
 func WithDefaultTable() Option {
 	return func(o *options) error {
 		if o.Table != "" {
 			return nil // already set
 		}
+		if o.Counter {
+			return WithTable("oakratelimiter_counter")(o)
+		}
 		return WithTable("oakratelimiter")(o)
 	}
 }
 
+// WithSlidingWindowCounter keeps a [rate.WindowCounter] row for each tag instead of a record for each take. The table stays small for high-cardinality tags, at the cost of the approximation bounded as described by [rate.WindowCounter]. The burst limit is the number of tokens allowed within the sliding window. Changing the interval of the rate with [RateLimiter.SetRate] clears the counters.
+func WithSlidingWindowCounter() Option {
+	return func(o *options) error {
+		if o.Counter {
+			return errors.New("sliding window counter is already set")
+		}
+		o.Counter = true
+		return nil
+	}
+}
+
 // WithRate specifies [rate.Rate] setting to use with this rate limiter.
 func WithRate(r *rate.Rate) Option {
 	return func(o *options) error {
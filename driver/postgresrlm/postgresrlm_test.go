@@ -26,4 +26,5 @@ func TestPostgresDriver(t *testing.T) {
 	// ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
 	// defer cancel()
 	test.RateLimiterTest(context.Background(), rlm, 4)(t)
+	test.RateLimiterRefundTest(context.Background(), rlm, "refund")(t)
 }
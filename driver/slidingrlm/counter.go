@@ -0,0 +1,141 @@
+package slidingrlm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+var ( // enforce interface compliance
+	_ rate.Limiter = (*Counter)(nil)
+	_ rate.Delayer = (*Counter)(nil)
+)
+
+// NewCounter initializes a sliding window [Counter] using a list of [Option]s.
+func NewCounter(withOptions ...Option) (*Counter, error) {
+	o := &options{}
+	for _, option := range append(
+		withOptions,
+		WithDefaultInitialAllocationSize(),
+		WithDefaultCleanupInterval(),
+		WithDefaultCleanupContext(),
+		func(o *options) error { // validate
+			if o.Rate == nil {
+				return errors.New("rate is required")
+			}
+			if o.Capacity != 0 {
+				return errors.New("capacity option does not apply to a sliding window counter")
+			}
+			return nil
+		},
+	) {
+		if err := option(o); err != nil {
+			return nil, fmt.Errorf("cannot initialize sliding window counter rate limiter driver: %w", err)
+		}
+	}
+
+	c := &Counter{
+		rate:     o.Rate,
+		limit:    o.Rate.Burst(),
+		mu:       sync.Mutex{},
+		counters: make(map[string]*rate.WindowCounter, o.InitialAllocationSize),
+		now:      time.Now,
+	}
+	go purgeLoop(o.CleanupContext, o.CleanupInterval, c.Purge)
+	return c, nil
+}
+
+// Counter keeps a [rate.WindowCounter] for each tag. It suits high-cardinality tags, because it keeps two numbers per tag regardless of the limit. It is approximate: see [rate.WindowCounter] for its error bound.
+type Counter struct {
+	rate  *rate.Rate
+	limit float64
+
+	mu       sync.Mutex
+	counters map[string]*rate.WindowCounter
+	now      func() time.Time // replaced by tests
+}
+
+func (c *Counter) Rate() *rate.Rate {
+	return c.rate
+}
+
+func (c *Counter) Remaining(ctx context.Context, tag string) (float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	found, ok := c.counters[tag]
+	if !ok {
+		return c.limit, nil
+	}
+	return c.limit - found.Taken(c.now(), c.rate), nil
+}
+
+func (c *Counter) Take(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) (
+	remaining float64,
+	ok bool,
+	err error,
+) {
+	t := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	found, ok := c.counters[tag]
+	if !ok {
+		found = &rate.WindowCounter{}
+		c.counters[tag] = found
+	}
+	remaining, ok = found.Take(t, c.rate, c.limit, tokens)
+	return remaining, ok, nil
+}
+
+func (c *Counter) Delay(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) (time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	found, ok := c.counters[tag]
+	if !ok {
+		return c.rate.ReplenishmentDuration(tokens - c.limit), nil
+	}
+	return found.Delay(c.now(), c.rate, c.limit, tokens), nil
+}
+
+func (c *Counter) Put(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	found, ok := c.counters[tag]
+	if !ok {
+		return nil // nothing taken
+	}
+	found.Advance(c.now(), c.rate)
+	found.Put(tokens)
+	return nil
+}
+
+// Purge removes the counters of tags without takes in the current or the previous fixed window by given [time.Time].
+func (c *Counter) Purge(at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for tag, found := range c.counters {
+		if found.Advance(at, c.rate); found.Previous == 0 && found.Current == 0 {
+			delete(c.counters, tag)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package slidingrlm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/test"
+)
+
+// TestCounter cannot use [test.RateLimiterTest], which runs steady traffic at nearly the full rate and expects no rejections. The counter may reject some of it within its error bound.
+func TestCounter(t *testing.T) {
+	ctx := context.Background()
+	limiter, err := NewCounter(WithNewRate(8, time.Millisecond*200))
+	if err != nil {
+		t.Fatal("cannot initialize rate limiter:", err)
+	}
+	clock := newClock()
+	limiter.now = clock.Now
+	for i := 0; i < 12; i++ { // half of the rate
+		if _, ok, err := limiter.Take(ctx, "steady", 1); err != nil || !ok {
+			t.Fatal("steady traffic was rejected:", err)
+		}
+		clock.Advance(time.Millisecond * 50)
+	}
+
+	passed := 0
+	for i := 0; i < 20; i++ {
+		_, ok, err := limiter.Take(ctx, "burst", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			passed++
+		}
+	}
+	if passed != 8 {
+		t.Fatalf("burst passed %d requests instead of 8", passed)
+	}
+}
+
+func TestCounterRefund(t *testing.T) {
+	limiter, err := NewCounter(WithNewRate(8, time.Second))
+	if err != nil {
+		t.Fatal("cannot initialize rate limiter:", err)
+	}
+	test.RateLimiterRefundTest(context.Background(), limiter, "test")(t)
+}
+
+func TestCounterPurge(t *testing.T) {
+	ctx := context.Background()
+	limiter, err := NewCounter(WithNewRate(4, time.Hour))
+	if err != nil {
+		t.Fatal("cannot initialize rate limiter:", err)
+	}
+	if _, ok, err := limiter.Take(ctx, "test", 4); err != nil || !ok {
+		t.Fatal("rate limiter blocked unexpectedly:", err)
+	}
+	delay, err := limiter.Delay(ctx, "test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delay <= 0 || delay > time.Hour*2 {
+		t.Fatal("delay is beyond the previous window:", delay)
+	}
+	limiter.Purge(time.Now())
+	if len(limiter.counters) != 1 {
+		t.Fatal("active tag was purged")
+	}
+	limiter.Purge(time.Now().Add(time.Hour * 2))
+	if len(limiter.counters) != 0 {
+		t.Fatal("idle tag was not purged")
+	}
+	if _, err = NewCounter(WithNewRate(4, time.Hour), WithCapacity(8)); err == nil {
+		t.Fatal("capacity option was accepted")
+	}
+}
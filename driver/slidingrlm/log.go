@@ -0,0 +1,254 @@
+/*
+Package slidingrlm provides in-memory [rate.Limiter]s that count tokens within a sliding window instead of a leaky bucket. The window is the interval of the [rate.Rate] and the limit is [rate.Rate.Burst], so a [Log] with a rate of 5 per 15 minutes allows no more than 5 tokens in any rolling 15 minutes.
+
+A [Log] is exact, which suits compliance limits, like one-time password sends. It keeps a timestamp for every take within the window, so its memory grows with the limit. A [Counter] keeps two numbers per tag instead, which suits high-cardinality tags, at the cost of an approximation bounded as described by [rate.WindowCounter].
+*/
+package slidingrlm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+var ( // enforce interface compliance
+	_ rate.Limiter = (*Log)(nil)
+	_ rate.Delayer = (*Log)(nil)
+)
+
+// NewLog initializes a sliding window [Log] using a list of [Option]s.
+func NewLog(withOptions ...Option) (*Log, error) {
+	o := &options{}
+	for _, option := range append(
+		withOptions,
+		WithDefaultCapacity(),
+		WithDefaultInitialAllocationSize(),
+		WithDefaultCleanupInterval(),
+		WithDefaultCleanupContext(),
+		func(o *options) error { // validate
+			if o.Rate == nil {
+				return errors.New("rate is required")
+			}
+			return nil
+		},
+	) {
+		if err := option(o); err != nil {
+			return nil, fmt.Errorf("cannot initialize sliding window log rate limiter driver: %w", err)
+		}
+	}
+
+	l := &Log{
+		rate:     o.Rate,
+		limit:    o.Rate.Burst(),
+		window:   o.Rate.Interval().Nanoseconds(),
+		capacity: o.Capacity,
+		mu:       sync.Mutex{},
+		rings:    make(map[string]*ring, o.InitialAllocationSize),
+		now:      time.Now,
+	}
+	go purgeLoop(o.CleanupContext, o.CleanupInterval, l.Purge)
+	return l, nil
+}
+
+// Log keeps a bounded ring of take timestamps for each tag. A take is allowed, if the tokens taken within the window, including this one, do not exceed the limit, and the ring has room for one more timestamp.
+type Log struct {
+	rate     *rate.Rate
+	limit    float64
+	window   int64
+	capacity int
+
+	mu    sync.Mutex
+	rings map[string]*ring
+	now   func() time.Time // replaced by tests
+}
+
+func (l *Log) Rate() *rate.Rate {
+	return l.rate
+}
+
+// current returns the ring of a tag without timestamps that left the window. Returns <nil>, if the tag has no ring. Must run inside mutex lock.
+func (l *Log) current(at int64, tag string) *ring {
+	found, ok := l.rings[tag]
+	if !ok {
+		return nil
+	}
+	found.Expire(at - l.window)
+	return found
+}
+
+// Remaining returns the number of tokens that can be taken within the window.
+func (l *Log) Remaining(ctx context.Context, tag string) (float64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	found := l.current(l.now().UnixNano(), tag)
+	if found == nil {
+		return l.limit, nil
+	}
+	return l.limit - found.used, nil
+}
+
+func (l *Log) Take(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) (
+	remaining float64,
+	ok bool,
+	err error,
+) {
+	t := l.now().UnixNano()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	found := l.current(t, tag)
+	if found == nil {
+		found = &ring{}
+		l.rings[tag] = found
+	}
+	remaining = l.limit - found.used
+	if tokens > remaining || found.size >= l.capacity {
+		return remaining, false, nil
+	}
+	found.Push(t, tokens, l.capacity)
+	return remaining - tokens, true, nil
+}
+
+// Delay returns the time until enough timestamps leave the window to allow the tokens.
+func (l *Log) Delay(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) (time.Duration, error) {
+	t := l.now().UnixNano()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	found := l.current(t, tag)
+	if found == nil {
+		return l.rate.ReplenishmentDuration(tokens - l.limit), nil
+	}
+	remaining := l.limit - found.used
+	if tokens <= remaining && found.size < l.capacity {
+		return 0, nil
+	}
+	for i := 0; i < found.size; i++ {
+		s := found.At(i)
+		remaining += s.tokens
+		if tokens <= remaining && found.size-i-1 < l.capacity {
+			return time.Duration(s.at + l.window - t), nil
+		}
+	}
+	return l.rate.ReplenishmentDuration(tokens - remaining), nil // more than the limit
+}
+
+// Put returns tokens by removing them from the latest takes of a tag.
+func (l *Log) Put(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	found := l.current(l.now().UnixNano(), tag)
+	if found == nil {
+		return nil // nothing taken
+	}
+	found.Refund(tokens)
+	return nil
+}
+
+// Purge removes the rings of tags without takes within the window before given [time.Time].
+func (l *Log) Purge(at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for tag, found := range l.rings {
+		if found.Expire(at.UnixNano() - l.window); found.size == 0 {
+			delete(l.rings, tag)
+		}
+	}
+}
+
+func purgeLoop(ctx context.Context, every time.Duration, purge func(time.Time)) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			purge(t)
+		}
+	}
+}
+
+type stamp struct {
+	at     int64 // nanoseconds since Unix epoch
+	tokens float64
+}
+
+// ring holds timestamps from oldest to newest. It grows up to the capacity of the [Log], so that tags that take few tokens stay small.
+type ring struct {
+	stamps []stamp
+	head   int
+	size   int
+	used   float64
+}
+
+// At returns the timestamp at a position, counting from the oldest.
+func (r *ring) At(i int) stamp {
+	return r.stamps[(r.head+i)%len(r.stamps)]
+}
+
+// Expire removes timestamps taken at or before a given time.
+func (r *ring) Expire(before int64) {
+	for r.size > 0 {
+		oldest := r.stamps[r.head]
+		if oldest.at > before {
+			return
+		}
+		r.used -= oldest.tokens
+		r.head = (r.head + 1) % len(r.stamps)
+		r.size--
+	}
+	r.used = 0 // avoid floating point drift
+}
+
+// Push adds the newest timestamp. The caller must check that the size is below capacity.
+func (r *ring) Push(at int64, tokens float64, capacity int) {
+	if r.size == len(r.stamps) {
+		grown := make([]stamp, min(max(r.size*2, 4), capacity))
+		for i := 0; i < r.size; i++ {
+			grown[i] = r.At(i)
+		}
+		r.stamps = grown
+		r.head = 0
+	}
+	r.stamps[(r.head+r.size)%len(r.stamps)] = stamp{at: at, tokens: tokens}
+	r.size++
+	r.used += tokens
+}
+
+// Refund subtracts tokens from the newest timestamps, removing the ones that become empty.
+func (r *ring) Refund(tokens float64) {
+	for r.size > 0 && tokens > 0 {
+		newest := &r.stamps[(r.head+r.size-1)%len(r.stamps)]
+		refunded := min(newest.tokens, tokens)
+		newest.tokens -= refunded
+		r.used -= refunded
+		tokens -= refunded
+		if newest.tokens == 0 {
+			r.size--
+		}
+	}
+	if r.size == 0 {
+		r.used = 0
+	}
+}
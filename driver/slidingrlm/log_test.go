@@ -0,0 +1,118 @@
+package slidingrlm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/test"
+)
+
+// clock is a manually advanced time source, so that tests do not depend on the scheduler.
+type clock struct {
+	t time.Time
+}
+
+func newClock() *clock {
+	return &clock{t: time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *clock) Now() time.Time {
+	return c.t
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func TestLog(t *testing.T) {
+	limiter, err := NewLog(WithNewRate(8, time.Millisecond*20))
+	if err != nil {
+		t.Fatal("cannot initialize rate limiter:", err)
+	}
+	test.RateLimiterTest(context.Background(), limiter, 8)(t)
+}
+
+func TestLogRefund(t *testing.T) {
+	limiter, err := NewLog(WithNewRate(8, time.Second))
+	if err != nil {
+		t.Fatal("cannot initialize rate limiter:", err)
+	}
+	test.RateLimiterRefundTest(context.Background(), limiter, "test")(t)
+}
+
+func TestLogRollingWindow(t *testing.T) {
+	ctx := context.Background()
+	limiter, err := NewLog(WithNewRate(3, time.Millisecond*300))
+	if err != nil {
+		t.Fatal("cannot initialize rate limiter:", err)
+	}
+	clock := newClock()
+	limiter.now = clock.Now
+	for i := 0; i < 3; i++ {
+		if _, ok, err := limiter.Take(ctx, "otp", 1); err != nil || !ok {
+			t.Fatal("rate limiter blocked unexpectedly:", err)
+		}
+	}
+	delay, err := limiter.Delay(ctx, "otp", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delay != time.Millisecond*300 {
+		t.Fatal("delay does not match the oldest timestamp:", delay)
+	}
+
+	// a leaky bucket would have replenished one token by now
+	clock.Advance(time.Millisecond * 150)
+	if _, ok, err := limiter.Take(ctx, "otp", 1); err != nil || ok {
+		t.Fatal("take within the window was allowed:", err)
+	}
+	clock.Advance(delay - time.Millisecond*150 + time.Millisecond)
+	remaining, err := limiter.Remaining(ctx, "otp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining != 3 {
+		t.Fatal("timestamps did not leave the window:", remaining)
+	}
+
+	limiter.Purge(clock.Now())
+	if len(limiter.rings) != 0 {
+		t.Fatal("idle tag was not purged")
+	}
+}
+
+func TestLogCapacity(t *testing.T) {
+	ctx := context.Background()
+	limiter, err := NewLog(WithNewRate(10, time.Hour), WithCapacity(2))
+	if err != nil {
+		t.Fatal("cannot initialize rate limiter:", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, ok, err := limiter.Take(ctx, "test", 0.5); err != nil || !ok {
+			t.Fatal("rate limiter blocked unexpectedly:", err)
+		}
+	}
+	remaining, ok, err := limiter.Take(ctx, "test", 0.5)
+	if err != nil || ok {
+		t.Fatal("full ring accepted a timestamp:", err)
+	}
+	if remaining != 9 {
+		t.Fatal("unexpected remaining tokens:", remaining)
+	}
+	if err = limiter.Put(ctx, "test", 0.75); err != nil {
+		t.Fatal(err)
+	}
+	if remaining, err = limiter.Remaining(ctx, "test"); err != nil || remaining != 9.75 {
+		t.Fatal("tokens were not refunded from the newest takes:", remaining, err)
+	}
+	if _, ok, err = limiter.Take(ctx, "test", 0.5); err != nil || !ok {
+		t.Fatal("refunded timestamp did not free room:", err)
+	}
+}
+
+func TestLogRequiresRate(t *testing.T) {
+	if _, err := NewLog(WithCapacity(10)); err == nil {
+		t.Fatal("sliding window log without a rate was accepted")
+	}
+}
@@ -0,0 +1,155 @@
+package slidingrlm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+type options struct {
+	Rate                  *rate.Rate
+	Capacity              int
+	InitialAllocationSize int
+	CleanupInterval       time.Duration
+	CleanupContext        context.Context
+}
+
+// Option configures the sliding window rate limiters.
+type Option func(*options) error
+
+// WithRate sets the [rate.Rate]. Its interval is the length of the sliding window and [rate.Rate.Burst] is the number of tokens allowed within any window.
+func WithRate(r *rate.Rate) Option {
+	return func(o *options) error {
+		if r == nil {
+			return errors.New("cannot use a <nil> rate")
+		}
+		if o.Rate != nil {
+			return errors.New("rate is already set")
+		}
+		o.Rate = r
+		return nil
+	}
+}
+
+// WithNewRate creates a [rate.Rate] to pass to [WithRate] option.
+func WithNewRate(limit float64, interval time.Duration) Option {
+	return func(o *options) error {
+		rate, err := rate.New(limit, interval)
+		if err != nil {
+			return fmt.Errorf("cannot use new rate: %w", err)
+		}
+		return WithRate(rate)(o)
+	}
+}
+
+// WithCapacity sets the maximum number of timestamps kept for each tag by a [Log]. Each successful take adds one timestamp, so tags that take fractional tokens need more room than the limit.
+func WithCapacity(timestamps int) Option {
+	return func(o *options) error {
+		if o.Capacity != 0 {
+			return errors.New("capacity is already set")
+		}
+		if timestamps < 1 {
+			return errors.New("capacity must be greater than zero")
+		}
+		if timestamps > 1<<20 {
+			return errors.New("capacity is too great")
+		}
+		o.Capacity = timestamps
+		return nil
+	}
+}
+
+// WithDefaultCapacity keeps as many timestamps as the number of tokens allowed within the window, which is enough when every take is at least one token.
+func WithDefaultCapacity() Option {
+	return func(o *options) error {
+		if o.Capacity != 0 {
+			return nil // already set
+		}
+		if o.Rate == nil {
+			return errors.New("rate is required")
+		}
+		return WithCapacity(int(math.Ceil(o.Rate.Burst())))(o)
+	}
+}
+
+// WithInitialAllocationSize sets the number of pre-allocated items for the tag map. Higher number can improve starting performance at the cost of using more memory.
+func WithInitialAllocationSize(tags int) Option {
+	return func(o *options) error {
+		if o.InitialAllocationSize != 0 {
+			return errors.New("initial allocation size is already set")
+		}
+		if tags < 64 {
+			return errors.New("initial allocation size must not be less than 64")
+		}
+		if tags > 1<<32 {
+			return errors.New("initial allocation size is too great")
+		}
+		o.InitialAllocationSize = tags
+		return nil
+	}
+}
+
+// WithDefaultInitialAllocationSize sets initial map allocation to 1024.
+func WithDefaultInitialAllocationSize() Option {
+	return func(o *options) error {
+		if o.InitialAllocationSize == 0 {
+			return WithInitialAllocationSize(1024)(o)
+		}
+		return nil
+	}
+}
+
+// WithCleanupInterval sets the frequency of map clean up. Lower value frees up more memory at the cost of CPU cycles.
+func WithCleanupInterval(of time.Duration) Option {
+	return func(o *options) error {
+		if o.CleanupInterval != 0 {
+			return errors.New("clean up period is already set")
+		}
+		if of < time.Second {
+			return errors.New("clean up period must be greater than 1 second")
+		}
+		if of > time.Hour {
+			return errors.New("clean up period must be less than one hour")
+		}
+		o.CleanupInterval = of
+		return nil
+	}
+}
+
+// WithDefaultCleanupInterval sets clean up period to 11 minutes.
+func WithDefaultCleanupInterval() Option {
+	return func(o *options) error {
+		if o.CleanupInterval != 0 {
+			return nil // already set
+		}
+		return WithCleanupInterval(time.Minute * 11)(o)
+	}
+}
+
+// WithCleanupContext provides the [context.Context] for garbage collection. When the context is cancelled, garbage collection stops.
+func WithCleanupContext(ctx context.Context) Option {
+	return func(o *options) error {
+		if ctx == nil {
+			return errors.New("cannot use a <nil> clean up context")
+		}
+		if o.CleanupContext != nil {
+			return errors.New("clean up context is already set")
+		}
+		o.CleanupContext = ctx
+		return nil
+	}
+}
+
+// WithDefaultCleanupContext passes [context.Background] to [WithCleanupContext] option.
+func WithDefaultCleanupContext() Option {
+	return func(o *options) error {
+		if o.CleanupContext != nil {
+			return nil // already set
+		}
+		return WithCleanupContext(context.Background())(o)
+	}
+}
@@ -0,0 +1,232 @@
+package sqliterlm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+// windowCounter keeps a [rate.WindowCounter] row for each tag. It is selected by [WithSlidingWindowCounter].
+type windowCounter struct {
+	db          *sql.DB
+	ensureStmt  *sql.Stmt
+	selectStmt  *sql.Stmt
+	updateStmt  *sql.Stmt
+	listStmt    *sql.Stmt
+	resetStmt   *sql.Stmt
+	cleanupStmt *sql.Stmt
+}
+
+func createCounterTable(db *sql.DB, table string) error {
+	_, err := db.Exec(fmt.Sprintf(`
+    CREATE TABLE IF NOT EXISTS %q (
+      tag TEXT PRIMARY KEY,
+      window_index INTEGER NOT NULL,
+      previous_tokens REAL NOT NULL,
+      current_tokens REAL NOT NULL
+    )`, table))
+	if err != nil {
+		return fmt.Errorf("cannot create database table %q: %w", table, err)
+	}
+	return nil
+}
+
+func prepareCounter(db *sql.DB, table string) (c *windowCounter, err error) {
+	c = &windowCounter{db: db}
+	c.ensureStmt, err = db.Prepare(fmt.Sprintf(`INSERT INTO %q(tag, window_index, previous_tokens, current_tokens) VALUES($1, 0, 0, 0) ON CONFLICT (tag) DO NOTHING`, table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare ensure statement: %w", err)
+	}
+	c.selectStmt, err = db.Prepare(fmt.Sprintf(`SELECT window_index, previous_tokens, current_tokens FROM %q WHERE tag=$1`, table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare select statement: %w", err)
+	}
+	c.updateStmt, err = db.Prepare(fmt.Sprintf(`UPDATE %q SET window_index=$2, previous_tokens=$3, current_tokens=$4 WHERE tag=$1`, table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare update statement: %w", err)
+	}
+	c.listStmt, err = db.Prepare(fmt.Sprintf(`SELECT tag, window_index, previous_tokens, current_tokens FROM %q ORDER BY tag`, table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare list statement: %w", err)
+	}
+	c.resetStmt, err = db.Prepare(fmt.Sprintf(`DELETE FROM %q WHERE tag=$1`, table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare reset statement: %w", err)
+	}
+	c.cleanupStmt, err = db.Prepare(fmt.Sprintf(`DELETE FROM %q WHERE window_index<$1`, table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare delete statement: %w", err)
+	}
+	return c, nil
+}
+
+func (c *windowCounter) Remaining(
+	ctx context.Context,
+	tag string,
+	settings func() (*rate.Rate, float64),
+) (float64, error) {
+	limiterRate, burstLimit := settings()
+	counter := rate.WindowCounter{}
+	err := c.selectStmt.QueryRowContext(ctx, tag).Scan(&counter.Window, &counter.Previous, &counter.Current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return burstLimit, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return burstLimit - counter.Taken(time.Now(), limiterRate), nil
+}
+
+// update locks the row of a tag within a transaction, lets the function change the counter, and saves it, unless the function returns false.
+func (c *windowCounter) update(
+	ctx context.Context,
+	tag string,
+	change func(*rate.WindowCounter) bool,
+) (err error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rerr := tx.Rollback(); err != nil && rerr != nil {
+			slog.Warn("transaction rollback failed", slog.Any("error", rerr), slog.Any("rollback_cause", err))
+		}
+	}()
+
+	if _, err = tx.StmtContext(ctx, c.ensureStmt).ExecContext(ctx, tag); err != nil {
+		return fmt.Errorf("cannot create counter: %w", err)
+	}
+	counter := rate.WindowCounter{}
+	if err = tx.StmtContext(ctx, c.selectStmt).QueryRowContext(ctx, tag).Scan(&counter.Window, &counter.Previous, &counter.Current); err != nil {
+		return fmt.Errorf("cannot retrieve counter: %w", err)
+	}
+	if !change(&counter) {
+		return nil // rolled back
+	}
+	if _, err = tx.StmtContext(ctx, c.updateStmt).ExecContext(ctx, tag, counter.Window, counter.Previous, counter.Current); err != nil {
+		return fmt.Errorf("cannot update counter: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (c *windowCounter) Take(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+	settings func() (*rate.Rate, float64),
+) (
+	remaining float64,
+	ok bool,
+	err error,
+) {
+	limiterRate, burstLimit := settings()
+	t := time.Now()
+	err = c.update(ctx, tag, func(counter *rate.WindowCounter) bool {
+		remaining, ok = counter.Take(t, limiterRate, burstLimit, tokens)
+		return ok
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return remaining, ok, nil
+}
+
+func (c *windowCounter) Put(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+	settings func() (*rate.Rate, float64),
+) error {
+	limiterRate, _ := settings()
+	t := time.Now()
+	err := c.update(ctx, tag, func(counter *rate.WindowCounter) bool {
+		counter.Advance(t, limiterRate)
+		counter.Put(tokens)
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("cannot put tokens: %w", err)
+	}
+	return nil
+}
+
+// Buckets lists the tags with tokens taken within the sliding window, sorted by tag. Counters do not record when they were touched.
+func (c *windowCounter) Buckets(
+	ctx context.Context,
+	settings func() (*rate.Rate, float64),
+) (buckets []rate.Bucket, err error) {
+	limiterRate, burstLimit := settings()
+	t := time.Now()
+	rows, err := c.listStmt.QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list buckets: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			tag     string
+			counter rate.WindowCounter
+		)
+		if err = rows.Scan(&tag, &counter.Window, &counter.Previous, &counter.Current); err != nil {
+			return nil, fmt.Errorf("cannot list buckets: %w", err)
+		}
+		if taken := counter.Taken(t, limiterRate); taken > 0 {
+			buckets = append(buckets, rate.Bucket{Tag: tag, Remaining: burstLimit - taken})
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("cannot list buckets: %w", err)
+	}
+	return buckets, nil
+}
+
+func (c *windowCounter) Reset(ctx context.Context, tag string) error {
+	if _, err := c.resetStmt.ExecContext(ctx, tag); err != nil {
+		return fmt.Errorf("cannot reset tokens: %w", err)
+	}
+	return nil
+}
+
+// Adjust grants tokens by returning them to the counter, or revokes them by counting them as taken, if the amount is negative.
+func (c *windowCounter) Adjust(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+	settings func() (*rate.Rate, float64),
+) (remaining float64, err error) {
+	limiterRate, burstLimit := settings()
+	t := time.Now()
+	err = c.update(ctx, tag, func(counter *rate.WindowCounter) bool {
+		remaining = burstLimit - counter.Taken(t, limiterRate)
+		if tokens < 0 {
+			revoked := math.Min(-tokens, math.Max(remaining, 0))
+			counter.Current += revoked
+			remaining -= revoked
+		} else {
+			counter.Put(tokens)
+			remaining = burstLimit - counter.Taken(t, limiterRate)
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	return remaining, nil
+}
+
+// Cleanup removes the counters without tokens taken in the current or the previous fixed window by given [time.Time].
+func (c *windowCounter) Cleanup(
+	ctx context.Context,
+	at time.Time,
+	settings func() (*rate.Rate, float64),
+) error {
+	limiterRate, _ := settings()
+	_, err := c.cleanupStmt.ExecContext(ctx, at.UnixNano()/limiterRate.Interval().Nanoseconds()-1)
+	return err
+}
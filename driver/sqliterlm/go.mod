@@ -1,9 +1,13 @@
 module github.com/dkotik/oakratelimiter/postgresrlm
 
-go 1.21.0
+go 1.22.0
+
+require github.com/dkotik/oakratelimiter v0.0.2
+
+// The driver builds against the oakratelimiter module in this repository until its next release.
+replace github.com/dkotik/oakratelimiter => ../..
 
 require (
-	github.com/dkotik/oakratelimiter v0.0.2 // indirect
 	github.com/dustin/go-humanize v1.0.1 // indirect
 	github.com/google/uuid v1.3.0 // indirect
 	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51 // indirect
@@ -0,0 +1,124 @@
+package sqliterlm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/quota"
+)
+
+var _ quota.Store = (*QuotaStore)(nil) // enforce interface compliance
+
+// QuotaStore keeps [quota.Usage] in a SQLite database, so that quotas survive restarts. Rows of past windows are kept for usage dashboards until they are removed by [QuotaStore.Purge].
+type QuotaStore struct {
+	consumeStmt *sql.Stmt
+	releaseStmt *sql.Stmt
+	usedStmt    *sql.Stmt
+	listStmt    *sql.Stmt
+	purgeStmt   *sql.Stmt
+}
+
+// NewQuotaStore creates the table, if it does not exist, and prepares the queries.
+func NewQuotaStore(db *sql.DB, table string) (s *QuotaStore, err error) {
+	if db == nil {
+		return nil, errors.New("cannot use a <nil> database")
+	}
+	if !regexp.MustCompile(`^\w+$`).MatchString(table) {
+		return nil, fmt.Errorf("table name %q is invalid", table)
+	}
+	_, err = db.Exec(fmt.Sprintf(`
+    CREATE TABLE IF NOT EXISTS %q (
+      tag TEXT NOT NULL,
+      window_start INTEGER NOT NULL,
+      used REAL NOT NULL,
+      PRIMARY KEY (tag, window_start)
+    )`, table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create database table %q: %w", table, err)
+	}
+
+	s = &QuotaStore{}
+	s.consumeStmt, err = db.Prepare(fmt.Sprintf(`
+    INSERT INTO %[1]q(tag, window_start, used) VALUES($1, $2, $3)
+    ON CONFLICT (tag, window_start) DO UPDATE SET used=%[1]q.used+excluded.used
+    WHERE %[1]q.used+excluded.used<=$4
+    RETURNING used`, table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare consume statement: %w", err)
+	}
+	s.releaseStmt, err = db.Prepare(fmt.Sprintf(`UPDATE %q SET used=MAX(used-$3, 0) WHERE tag=$1 AND window_start=$2`, table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare release statement: %w", err)
+	}
+	s.usedStmt, err = db.Prepare(fmt.Sprintf(`SELECT used FROM %q WHERE tag=$1 AND window_start=$2`, table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare usage statement: %w", err)
+	}
+	s.listStmt, err = db.Prepare(fmt.Sprintf(`SELECT tag, used FROM %q WHERE window_start=$1 ORDER BY tag`, table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare list statement: %w", err)
+	}
+	s.purgeStmt, err = db.Prepare(fmt.Sprintf(`DELETE FROM %q WHERE window_start<$1`, table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare purge statement: %w", err)
+	}
+	return s, nil
+}
+
+// Consume adds tokens in a single statement, so that concurrent requests cannot exceed the limit together. Requires SQLite 3.35 or later for the RETURNING clause.
+func (s *QuotaStore) Consume(ctx context.Context, tag string, window time.Time, tokens, limit float64) (used float64, ok bool, err error) {
+	if tokens > limit {
+		used, err = s.Used(ctx, tag, window)
+		return used, false, err
+	}
+	err = s.consumeStmt.QueryRowContext(ctx, tag, window.Unix(), tokens, limit).Scan(&used)
+	if errors.Is(err, sql.ErrNoRows) { // the update was refused by the limit
+		used, err = s.Used(ctx, tag, window)
+		return used, false, err
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return used, true, nil
+}
+
+func (s *QuotaStore) Release(ctx context.Context, tag string, window time.Time, tokens float64) error {
+	_, err := s.releaseStmt.ExecContext(ctx, tag, window.Unix(), tokens)
+	return err
+}
+
+func (s *QuotaStore) Used(ctx context.Context, tag string, window time.Time) (used float64, err error) {
+	err = s.usedStmt.QueryRowContext(ctx, tag, window.Unix()).Scan(&used)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return used, err
+}
+
+func (s *QuotaStore) List(ctx context.Context, window time.Time) (_ []quota.Usage, err error) {
+	rows, err := s.listStmt.QueryContext(ctx, window.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := make([]quota.Usage, 0)
+	for rows.Next() {
+		u := quota.Usage{Start: window}
+		if err = rows.Scan(&u.Tag, &u.Used); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// Purge removes the usage of windows that started before a given time.
+func (s *QuotaStore) Purge(ctx context.Context, before time.Time) error {
+	_, err := s.purgeStmt.ExecContext(ctx, before.Unix())
+	return err
+}
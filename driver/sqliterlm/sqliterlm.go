@@ -7,6 +7,8 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"math"
+	"sync"
 	"time"
 
 	"log/slog"
@@ -16,13 +18,19 @@ import (
 
 // RateLimiter keep leaky bucket state in a Postgres database.
 type RateLimiter struct {
+	mu              sync.RWMutex
 	rate            *rate.Rate
 	microSecondRate float64
 	burstLimit      float64
 	db              *sql.DB
 	createStmt      *sql.Stmt
 	retrieveStmt    *sql.Stmt
+	listStmt        *sql.Stmt
+	resetStmt       *sql.Stmt
+	recordsStmt     *sql.Stmt
+	deductStmt      *sql.Stmt
 	cleanupStmt     *sql.Stmt
+	counter         *windowCounter
 }
 
 func New(withOptions ...Option) (r *RateLimiter, err error) {
@@ -35,6 +43,9 @@ func New(withOptions ...Option) (r *RateLimiter, err error) {
 		WithDefaultCleanupInterval(),
 		WithDefaultCleanupContext(),
 		func(o *options) (err error) {
+			if o.Counter {
+				return createCounterTable(o.Database, o.Table)
+			}
 			_, err = o.Database.Exec(fmt.Sprintf(`
         CREATE TABLE IF NOT EXISTS %q (
           tag TEXT NOT NULL,
@@ -68,17 +79,13 @@ func New(withOptions ...Option) (r *RateLimiter, err error) {
 		db:              o.Database,
 	}
 
-	r.createStmt, err = r.db.Prepare(fmt.Sprintf(`INSERT INTO %q(tag, touched, tokens) VALUES($1, $2, $3)`, o.Table))
-	if err != nil {
-		return nil, fmt.Errorf("cannot prepare create statement: %w", err)
-	}
-	r.retrieveStmt, err = r.db.Prepare(fmt.Sprintf(`SELECT SUM(tokens) FROM %q WHERE tag=$1 AND touched>$2`, o.Table))
-	if err != nil {
-		return nil, fmt.Errorf("cannot prepare retrieve statement: %w", err)
+	if o.Counter {
+		r.counter, err = prepareCounter(r.db, o.Table)
+	} else {
+		err = r.prepare(o.Table)
 	}
-	r.cleanupStmt, err = r.db.Prepare(fmt.Sprintf(`DELETE FROM %q WHERE touched < $1`, o.Table))
 	if err != nil {
-		return nil, fmt.Errorf("cannot prepare delete statement: %w", err)
+		return nil, err
 	}
 
 	go func(ctx context.Context, r *RateLimiter, every time.Duration) {
@@ -104,10 +111,90 @@ func New(withOptions ...Option) (r *RateLimiter, err error) {
 	return r, nil
 }
 
+// prepare creates statements for the table with a record for each take.
+func (r *RateLimiter) prepare(table string) (err error) {
+	r.createStmt, err = r.db.Prepare(fmt.Sprintf(`INSERT INTO %q(tag, touched, tokens) VALUES($1, $2, $3)`, table))
+	if err != nil {
+		return fmt.Errorf("cannot prepare create statement: %w", err)
+	}
+	r.retrieveStmt, err = r.db.Prepare(fmt.Sprintf(`SELECT SUM(tokens) FROM %q WHERE tag=$1 AND touched>$2`, table))
+	if err != nil {
+		return fmt.Errorf("cannot prepare retrieve statement: %w", err)
+	}
+	r.listStmt, err = r.db.Prepare(fmt.Sprintf(`SELECT tag, SUM(tokens), MAX(touched) FROM %q WHERE touched>$1 GROUP BY tag ORDER BY tag`, table))
+	if err != nil {
+		return fmt.Errorf("cannot prepare list statement: %w", err)
+	}
+	r.resetStmt, err = r.db.Prepare(fmt.Sprintf(`DELETE FROM %q WHERE tag=$1`, table))
+	if err != nil {
+		return fmt.Errorf("cannot prepare reset statement: %w", err)
+	}
+	r.recordsStmt, err = r.db.Prepare(fmt.Sprintf(`SELECT rowid, tokens FROM %q WHERE tag=$1 AND touched>$2 AND tokens>0 ORDER BY touched DESC`, table))
+	if err != nil {
+		return fmt.Errorf("cannot prepare records statement: %w", err)
+	}
+	r.deductStmt, err = r.db.Prepare(fmt.Sprintf(`UPDATE %q SET tokens=tokens-$2 WHERE rowid=$1`, table))
+	if err != nil {
+		return fmt.Errorf("cannot prepare deduct statement: %w", err)
+	}
+	r.cleanupStmt, err = r.db.Prepare(fmt.Sprintf(`DELETE FROM %q WHERE touched < $1`, table))
+	if err != nil {
+		return fmt.Errorf("cannot prepare delete statement: %w", err)
+	}
+	return nil
+}
+
 func (r *RateLimiter) Rate() *rate.Rate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	return r.rate
 }
 
+// SetRate replaces the [rate.Rate] and the burst limit. Recorded tokens are kept, so the new limit applies to the usage within the current interval instead of resetting it. Zero burst limit is replaced by [rate.Rate.Burst].
+func (r *RateLimiter) SetRate(to *rate.Rate, burstLimit float64) (err error) {
+	if burstLimit, err = rate.ValidateBurst(to, burstLimit); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rate = to
+	r.microSecondRate = to.PerNanosecond() * 1000
+	r.burstLimit = burstLimit
+	return nil
+}
+
+// TagRate returns the [rate.Rate] and the burst limit, which are the same for every tag.
+func (r *RateLimiter) TagRate(context.Context, string) (*rate.Rate, float64, error) {
+	limiterRate, burstLimit := r.settings()
+	return limiterRate, burstLimit, nil
+}
+
+func (r *RateLimiter) settings() (*rate.Rate, float64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rate, r.burstLimit
+}
+
+// Remaining retrieves available tokens by tag. If no records can be found, the burst limit is returned.
+func (r *RateLimiter) Remaining(
+	ctx context.Context,
+	tag string,
+) (
+	remaining float64,
+	err error,
+) {
+	if r.counter != nil {
+		return r.counter.Remaining(ctx, tag, r.settings)
+	}
+	limiterRate, burstLimit := r.settings()
+	var taken sql.NullFloat64
+	row := r.retrieveStmt.QueryRowContext(ctx, tag, time.Now().Add(-limiterRate.Interval()).UnixMicro())
+	if err = row.Scan(&taken); err != nil {
+		return 0, err
+	}
+	return burstLimit - taken.Float64, nil
+}
+
 // Take retrieves available tokens by tag and takes one token from it.
 func (r *RateLimiter) Take(
 	ctx context.Context,
@@ -118,6 +205,10 @@ func (r *RateLimiter) Take(
 	ok bool,
 	err error,
 ) {
+	if r.counter != nil {
+		return r.counter.Take(ctx, tag, tokens, r.settings)
+	}
+	limiterRate, burstLimit := r.settings()
 	tx, err := r.db.BeginTx(ctx, nil)
 	// tx, err := r.db.Begin() // does not throw "sql: transaction has already been committed or rolled back"
 	if err != nil {
@@ -137,14 +228,14 @@ func (r *RateLimiter) Take(
 		return 0, false, fmt.Errorf("cannot create tokens: %w", err)
 	}
 
-	row := tx.Stmt(r.retrieveStmt).QueryRow(tag, t.Add(-r.rate.Interval()).UnixMicro())
+	row := tx.Stmt(r.retrieveStmt).QueryRow(tag, t.Add(-limiterRate.Interval()).UnixMicro())
 	if err = row.Err(); err != nil {
 		return 0, false, err
 	}
 	if err = row.Scan(&remaining); err != nil {
 		return 0, false, err
 	}
-	remaining = r.burstLimit - remaining
+	remaining = burstLimit - remaining
 	if remaining < 0 { // not enough
 		if rerr := tx.Rollback(); err != nil {
 			slog.Warn("transaction rollback failed", slog.Any("error", rerr), slog.Any("rollback_cause", err))
@@ -157,8 +248,178 @@ func (r *RateLimiter) Take(
 	return remaining, true, nil
 }
 
+// Put returns tokens to the tag by deducting them from the most recent records within the rate interval. Refunds are never greater than the tokens recorded by those records.
+func (r *RateLimiter) Put(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) (err error) {
+	if r.counter != nil {
+		return r.counter.Put(ctx, tag, tokens, r.settings)
+	}
+	limiterRate, _ := r.settings()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot put tokens: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rerr := tx.Rollback(); rerr != nil {
+				slog.Warn("transaction rollback failed", slog.Any("error", rerr), slog.Any("rollback_cause", err))
+			}
+		}
+	}()
+	since := time.Now().Add(-limiterRate.Interval()).UnixMicro()
+	if _, err = r.refund(ctx, tx, tag, tokens, since); err != nil {
+		return fmt.Errorf("cannot put tokens: %w", err)
+	}
+	return tx.Commit()
+}
+
+// refund deducts tokens from the records of a tag taken since the given time in microseconds, starting with the newest, so that a refund larger than a single record is spread across several. Returns the number of tokens refunded, which never exceeds the tokens recorded.
+func (r *RateLimiter) refund(
+	ctx context.Context,
+	tx *sql.Tx,
+	tag string,
+	tokens float64,
+	since int64,
+) (refunded float64, err error) {
+	rows, err := tx.StmtContext(ctx, r.recordsStmt).QueryContext(ctx, tag, since)
+	if err != nil {
+		return 0, fmt.Errorf("cannot retrieve records: %w", err)
+	}
+	type record struct {
+		id     int64
+		tokens float64
+	}
+	var records []record
+	for rows.Next() {
+		var current record
+		if err = rows.Scan(&current.id, &current.tokens); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("cannot retrieve records: %w", err)
+		}
+		records = append(records, current)
+	}
+	if err = rows.Close(); err != nil {
+		return 0, fmt.Errorf("cannot retrieve records: %w", err)
+	}
+
+	deduct := tx.StmtContext(ctx, r.deductStmt)
+	for _, current := range records {
+		if tokens <= 0 {
+			break
+		}
+		deducted := math.Min(current.tokens, tokens)
+		if _, err = deduct.ExecContext(ctx, current.id, deducted); err != nil {
+			return 0, fmt.Errorf("cannot deduct tokens: %w", err)
+		}
+		tokens -= deducted
+		refunded += deducted
+	}
+	return refunded, nil
+}
+
+// Buckets lists the tags that have records within the last interval, sorted by tag.
+func (r *RateLimiter) Buckets(ctx context.Context) (buckets []rate.Bucket, err error) {
+	if r.counter != nil {
+		return r.counter.Buckets(ctx, r.settings)
+	}
+	limiterRate, burstLimit := r.settings()
+	rows, err := r.listStmt.QueryContext(ctx, time.Now().Add(-limiterRate.Interval()).UnixMicro())
+	if err != nil {
+		return nil, fmt.Errorf("cannot list buckets: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			bucket  rate.Bucket
+			taken   float64
+			touched int64
+		)
+		if err = rows.Scan(&bucket.Tag, &taken, &touched); err != nil {
+			return nil, fmt.Errorf("cannot list buckets: %w", err)
+		}
+		bucket.Remaining = burstLimit - taken
+		bucket.Touched = time.UnixMicro(touched)
+		buckets = append(buckets, bucket)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("cannot list buckets: %w", err)
+	}
+	return buckets, nil
+}
+
+// Reset deletes all records of a tag, so that the tag has all of its tokens.
+func (r *RateLimiter) Reset(ctx context.Context, tag string) error {
+	if r.counter != nil {
+		return r.counter.Reset(ctx, tag)
+	}
+	if _, err := r.resetStmt.ExecContext(ctx, tag); err != nil {
+		return fmt.Errorf("cannot reset tokens: %w", err)
+	}
+	return nil
+}
+
+// Adjust grants tokens to a tag by deducting them from the most recent records, or revokes them by recording their use, if the amount is negative. The remaining tokens stay between zero and the burst limit.
+func (r *RateLimiter) Adjust(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) (
+	remaining float64,
+	err error,
+) {
+	if r.counter != nil {
+		return r.counter.Adjust(ctx, tag, tokens, r.settings)
+	}
+	limiterRate, burstLimit := r.settings()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			if rerr := tx.Rollback(); rerr != nil {
+				slog.Warn("transaction rollback failed", slog.Any("error", rerr), slog.Any("rollback_cause", err))
+			}
+		}
+	}()
+	t := time.Now()
+	since := t.Add(-limiterRate.Interval()).UnixMicro()
+
+	var taken sql.NullFloat64
+	if err = tx.StmtContext(ctx, r.retrieveStmt).QueryRowContext(ctx, tag, since).Scan(&taken); err != nil {
+		return 0, fmt.Errorf("cannot retrieve tokens: %w", err)
+	}
+	remaining = burstLimit - taken.Float64
+
+	if tokens < 0 {
+		revoked := math.Min(-tokens, math.Max(remaining, 0))
+		if revoked > 0 {
+			if _, err = tx.StmtContext(ctx, r.createStmt).ExecContext(ctx, tag, t.UnixMicro(), revoked); err != nil {
+				return 0, fmt.Errorf("cannot revoke tokens: %w", err)
+			}
+			remaining -= revoked
+		}
+	} else if granted := math.Min(tokens, taken.Float64); granted > 0 {
+		if granted, err = r.refund(ctx, tx, tag, granted, since); err != nil {
+			return 0, fmt.Errorf("cannot grant tokens: %w", err)
+		}
+		remaining += granted
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return remaining, nil
+}
+
 // Cleanup removes all tokens that are expired by given [time.Time].
 func (r *RateLimiter) Cleanup(ctx context.Context, at time.Time) error {
-	_, err := r.cleanupStmt.ExecContext(ctx, at.Add(-r.rate.Interval()).UnixMicro())
+	if r.counter != nil {
+		return r.counter.Cleanup(ctx, at, r.settings)
+	}
+	limiterRate, _ := r.settings()
+	_, err := r.cleanupStmt.ExecContext(ctx, at.Add(-limiterRate.Interval()).UnixMicro())
 	return err
 }
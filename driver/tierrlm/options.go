@@ -0,0 +1,102 @@
+package tierrlm
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+type options struct {
+	Resolver      Resolver
+	Fallback      Tier
+	Factory       Factory
+	CacheTTL      time.Duration
+	CacheCapacity int
+	CacheSet      bool
+}
+
+// Option configures the tier rate limiter.
+type Option func(*options) error
+
+// WithResolver sets the [Resolver] that decides the [Tier] of each tag.
+func WithResolver(r Resolver) Option {
+	return func(o *options) error {
+		if r == nil {
+			return errors.New("cannot use a <nil> resolver")
+		}
+		if o.Resolver != nil {
+			return errors.New("resolver is already set")
+		}
+		o.Resolver = r
+		return nil
+	}
+}
+
+// WithDefaultTier sets the [Tier] of tags that the [Resolver] does not recognize. Its rate is reported by [RateLimiter.Rate].
+func WithDefaultTier(name string, r *rate.Rate, burst float64) Option {
+	return func(o *options) error {
+		t := Tier{Name: name, Rate: r, Burst: burst}
+		if err := t.Validate(); err != nil {
+			return err
+		}
+		if o.Fallback.Name != "" {
+			return errors.New("default tier is already set")
+		}
+		o.Fallback = t
+		return nil
+	}
+}
+
+// WithFactory sets the [Factory] that creates a [rate.Limiter] for each [Tier]. Use it to keep tiers in a database driver.
+func WithFactory(f Factory) Option {
+	return func(o *options) error {
+		if f == nil {
+			return errors.New("cannot use a <nil> factory")
+		}
+		if o.Factory != nil {
+			return errors.New("factory is already set")
+		}
+		o.Factory = f
+		return nil
+	}
+}
+
+// WithDefaultFactory keeps tiers in memory using [NewMutexFactory].
+func WithDefaultFactory() Option {
+	return func(o *options) error {
+		if o.Factory != nil {
+			return nil // already set
+		}
+		return WithFactory(NewMutexFactory())(o)
+	}
+}
+
+// WithCache remembers resolved tiers for a while, so that the [Resolver] is not consulted on every request. Changes of a tag tier, like a plan upgrade, take effect after the cache expires. Zero TTL disables the cache.
+func WithCache(ttl time.Duration, capacity int) Option {
+	return func(o *options) error {
+		if ttl < 0 {
+			return errors.New("cache time to live must not be negative")
+		}
+		if ttl > 0 && capacity < 1 {
+			return errors.New("cache capacity must be greater than zero")
+		}
+		if o.CacheSet {
+			return errors.New("cache is already set")
+		}
+		o.CacheTTL = ttl
+		o.CacheCapacity = capacity
+		o.CacheSet = true
+		return nil
+	}
+}
+
+// WithDefaultCache remembers up to ten thousand resolved tiers for one minute.
+func WithDefaultCache() Option {
+	return func(o *options) error {
+		if o.CacheSet {
+			return nil // already set
+		}
+		return WithCache(time.Minute, 10_000)(o)
+	}
+}
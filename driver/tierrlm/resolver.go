@@ -0,0 +1,200 @@
+package tierrlm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// ErrTierNotFound is returned by a [Resolver] for tags that belong to the default [Tier].
+var ErrTierNotFound = errors.New("tier not found")
+
+// Resolver decides the [Tier] of a tag. The context is the request context, so the tier can be taken from a value placed there by authentication middleware.
+type Resolver interface {
+	Resolve(ctx context.Context, tag string) (Tier, error)
+}
+
+// ResolverFunc is a function that satisfies the [Resolver] interface.
+type ResolverFunc func(ctx context.Context, tag string) (Tier, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context, tag string) (Tier, error) {
+	return f(ctx, tag)
+}
+
+// StaticResolver is a [Resolver] that assigns tags to tiers from a fixed list.
+type StaticResolver map[string]Tier
+
+// NewStaticResolver creates a [StaticResolver] from a map of tags to tier names.
+func NewStaticResolver(tags map[string]string, tiers ...Tier) (StaticResolver, error) {
+	byName, err := indexTiers(tiers)
+	if err != nil {
+		return nil, err
+	}
+	r := make(StaticResolver, len(tags))
+	for tag, name := range tags {
+		t, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("tag %q belongs to unknown tier %q", tag, name)
+		}
+		r[tag] = t
+	}
+	return r, nil
+}
+
+func (r StaticResolver) Resolve(ctx context.Context, tag string) (Tier, error) {
+	t, ok := r[tag]
+	if !ok {
+		return Tier{}, ErrTierNotFound
+	}
+	return t, nil
+}
+
+func indexTiers(tiers []Tier) (map[string]Tier, error) {
+	if len(tiers) == 0 {
+		return nil, errors.New("at least one tier is required")
+	}
+	byName := make(map[string]Tier, len(tiers))
+	for _, t := range tiers {
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := byName[t.Name]; ok {
+			return nil, fmt.Errorf("tier %q is already set", t.Name)
+		}
+		byName[t.Name] = t
+	}
+	return byName, nil
+}
+
+// CachedResolver remembers the tiers returned by another [Resolver], including [ErrTierNotFound]. Other errors are not cached.
+type CachedResolver struct {
+	resolver Resolver
+	ttl      time.Duration
+	capacity int
+
+	mu      sync.Mutex
+	entries map[string]cachedTier
+}
+
+type cachedTier struct {
+	tier    Tier
+	err     error
+	expires time.Time
+}
+
+// NewCachedResolver wraps a [Resolver] with a cache of limited capacity. When the cache is full, expired entries are removed first.
+func NewCachedResolver(r Resolver, ttl time.Duration, capacity int) (*CachedResolver, error) {
+	if r == nil {
+		return nil, errors.New("cannot use a <nil> resolver")
+	}
+	if ttl <= 0 {
+		return nil, errors.New("cache time to live must be greater than zero")
+	}
+	if capacity < 1 {
+		return nil, errors.New("cache capacity must be greater than zero")
+	}
+	return &CachedResolver{
+		resolver: r,
+		ttl:      ttl,
+		capacity: capacity,
+		entries:  make(map[string]cachedTier),
+	}, nil
+}
+
+func (c *CachedResolver) Resolve(ctx context.Context, tag string) (Tier, error) {
+	t := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[tag]
+	c.mu.Unlock()
+	if ok && entry.expires.After(t) {
+		return entry.tier, entry.err
+	}
+
+	tier, err := c.resolver.Resolve(ctx, tag)
+	if err != nil && !errors.Is(err, ErrTierNotFound) {
+		return Tier{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok = c.entries[tag]; !ok && len(c.entries) >= c.capacity {
+		c.evict(t)
+	}
+	c.entries[tag] = cachedTier{tier: tier, err: err, expires: t.Add(c.ttl)}
+	return tier, err
+}
+
+// evict removes expired entries. If none expired, an arbitrary entry is removed to make room.
+func (c *CachedResolver) evict(at time.Time) {
+	for tag, entry := range c.entries {
+		if !entry.expires.After(at) {
+			delete(c.entries, tag)
+		}
+	}
+	if len(c.entries) < c.capacity {
+		return
+	}
+	for tag := range c.entries {
+		delete(c.entries, tag)
+		return
+	}
+}
+
+// Forget removes a tag from the cache, so that a change of its tier, like a plan upgrade, takes effect on the next request.
+func (c *CachedResolver) Forget(tag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, tag)
+}
+
+// SQLResolver is a [Resolver] that looks up tier names of tags in a database table with "tag" and "tier" columns. Tags absent from the table belong to the default [Tier]. The queries work with both SQLite and Postgres.
+type SQLResolver struct {
+	tiers      map[string]Tier
+	lookupStmt *sql.Stmt
+}
+
+// NewSQLResolver creates the table, if it does not exist, and prepares the query. Every tier name in the table must be one of the given tiers.
+func NewSQLResolver(db *sql.DB, table string, tiers ...Tier) (_ *SQLResolver, err error) {
+	if db == nil {
+		return nil, errors.New("cannot use a <nil> database")
+	}
+	if !regexp.MustCompile(`^\w+$`).MatchString(table) {
+		return nil, fmt.Errorf("table name %q is invalid", table)
+	}
+	r := &SQLResolver{}
+	if r.tiers, err = indexTiers(tiers); err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(fmt.Sprintf(`
+    CREATE TABLE IF NOT EXISTS %q (
+      tag varchar(128) PRIMARY KEY,
+      tier varchar(64) NOT NULL
+    )`, table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create database table %q: %w", table, err)
+	}
+	r.lookupStmt, err = db.Prepare(fmt.Sprintf(`SELECT tier FROM %q WHERE tag=$1`, table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare lookup statement: %w", err)
+	}
+	return r, nil
+}
+
+func (r *SQLResolver) Resolve(ctx context.Context, tag string) (Tier, error) {
+	var name string
+	err := r.lookupStmt.QueryRowContext(ctx, tag).Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Tier{}, ErrTierNotFound
+	}
+	if err != nil {
+		return Tier{}, err
+	}
+	t, ok := r.tiers[name]
+	if !ok {
+		return Tier{}, fmt.Errorf("tag %q belongs to unknown tier %q", tag, name)
+	}
+	return t, nil
+}
@@ -0,0 +1,231 @@
+/*
+Package tierrlm provides a [rate.Limiter] that applies a different [rate.Rate] to each tag, like free, pro, and enterprise plans of a service.
+
+A [Resolver] decides which [Tier] a tag belongs to. Each tier is limited by its own [rate.Limiter], created on first use, so tiers can be stored by any driver. Because the result is a [rate.Limiter], it works behind any request tagger:
+
+	limiter, err := tierrlm.New(
+		tierrlm.WithResolver(tierrlm.ResolverFunc(
+			func(ctx context.Context, tag string) (tierrlm.Tier, error) {
+				return plans[ctx.Value(planContextKey).(string)], nil
+			},
+		)),
+		tierrlm.WithDefaultTier("free", freeRate, 0),
+	)
+*/
+package tierrlm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/driver/mutexrlm"
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+var ( // enforce interface compliance
+	_ rate.Limiter  = (*RateLimiter)(nil)
+	_ rate.Delayer  = (*RateLimiter)(nil)
+	_ rate.TagRater = (*RateLimiter)(nil)
+)
+
+// Tier is a named [rate.Rate] with its burst limit. Zero burst limit is replaced by [rate.Rate.Burst].
+type Tier struct {
+	Name  string
+	Rate  *rate.Rate
+	Burst float64
+}
+
+// Validate checks that the tier has a name and a valid rate.
+func (t Tier) Validate() error {
+	if t.Name == "" {
+		return errors.New("tier name is required")
+	}
+	if _, err := rate.ValidateBurst(t.Rate, t.Burst); err != nil {
+		return fmt.Errorf("invalid tier %q: %w", t.Name, err)
+	}
+	return nil
+}
+
+// Factory creates the [rate.Limiter] that stores the tokens of one [Tier].
+type Factory func(Tier) (rate.Limiter, error)
+
+// NewMutexFactory creates in-memory [mutexrlm.RateLimiter]s for each [Tier].
+func NewMutexFactory(withOptions ...mutexrlm.Option) Factory {
+	return func(t Tier) (rate.Limiter, error) {
+		tierOptions := append([]mutexrlm.Option{mutexrlm.WithRate(t.Rate)}, withOptions...)
+		if t.Burst != 0 {
+			tierOptions = append(tierOptions, mutexrlm.WithBurst(t.Burst))
+		}
+		return mutexrlm.New(tierOptions...)
+	}
+}
+
+// RateLimiter resolves the [Tier] of each tag and delegates to the [rate.Limiter] of that tier.
+type RateLimiter struct {
+	resolver Resolver
+	fallback Tier
+	factory  Factory
+
+	mu       sync.Mutex
+	limiters map[string]*tierLimiter
+}
+
+type tierLimiter struct {
+	Tier
+	rate.Limiter
+}
+
+// New creates a [RateLimiter]. A [Resolver] and a default [Tier] are required. Without other options, resolved tiers are cached for a minute and kept in memory.
+func New(withOptions ...Option) (_ *RateLimiter, err error) {
+	o := &options{}
+	for _, option := range append(
+		withOptions,
+		WithDefaultFactory(),
+		WithDefaultCache(),
+		func(o *options) error { // validate
+			if o.Resolver == nil {
+				return errors.New("resolver is required")
+			}
+			if o.Fallback.Name == "" {
+				return errors.New("default tier is required")
+			}
+			if _, cached := o.Resolver.(*CachedResolver); o.CacheTTL > 0 && !cached {
+				o.Resolver, err = NewCachedResolver(o.Resolver, o.CacheTTL, o.CacheCapacity)
+				return err
+			}
+			return nil
+		},
+	) {
+		if err = option(o); err != nil {
+			return nil, fmt.Errorf("cannot initialize tier rate limiter driver: %w", err)
+		}
+	}
+	return &RateLimiter{
+		resolver: o.Resolver,
+		fallback: o.Fallback,
+		factory:  o.Factory,
+		limiters: make(map[string]*tierLimiter),
+	}, nil
+}
+
+// Rate returns the rate of the default [Tier]. It does not describe tags of other tiers: use [RateLimiter.TagRate] or [RateLimiter.Tier] instead. Request limiters report the tier of each request through [request.RequestRate], so that rate limit headers and retry estimates match the tier.
+func (r *RateLimiter) Rate() *rate.Rate {
+	return r.fallback.Rate
+}
+
+// TagRate returns the rate and the burst limit of the tag [Tier].
+func (r *RateLimiter) TagRate(ctx context.Context, tag string) (*rate.Rate, float64, error) {
+	t, err := r.Tier(ctx, tag)
+	if err != nil {
+		return nil, 0, err
+	}
+	burstLimit, err := rate.ValidateBurst(t.Rate, t.Burst)
+	if err != nil {
+		return nil, 0, err
+	}
+	return t.Rate, burstLimit, nil
+}
+
+// Tier resolves the [Tier] of a tag. Tags without a tier get the default one.
+func (r *RateLimiter) Tier(ctx context.Context, tag string) (Tier, error) {
+	t, err := r.resolver.Resolve(ctx, tag)
+	if errors.Is(err, ErrTierNotFound) {
+		return r.fallback, nil
+	}
+	if err != nil {
+		return Tier{}, fmt.Errorf("cannot resolve tier of tag %q: %w", tag, err)
+	}
+	if err = t.Validate(); err != nil {
+		return Tier{}, err
+	}
+	return t, nil
+}
+
+// Forget removes the cached tier of a tag, so that a change of its tier, like a plan upgrade, takes effect on the next request.
+func (r *RateLimiter) Forget(tag string) {
+	if cached, ok := r.resolver.(*CachedResolver); ok {
+		cached.Forget(tag)
+	}
+}
+
+// limiter returns the [rate.Limiter] of the tag tier. When the rate of a tier changes, its limiter is reconfigured, if it is [rate.Reconfigurable], or replaced.
+func (r *RateLimiter) limiter(ctx context.Context, tag string) (rate.Limiter, error) {
+	t, err := r.Tier(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.limiters[t.Name]
+	if ok {
+		if *existing.Tier.Rate == *t.Rate && existing.Tier.Burst == t.Burst {
+			return existing.Limiter, nil
+		}
+		if reconfigurable, ok := existing.Limiter.(rate.Reconfigurable); ok {
+			if err = reconfigurable.SetRate(t.Rate, t.Burst); err != nil {
+				return nil, fmt.Errorf("cannot reconfigure tier %q: %w", t.Name, err)
+			}
+			existing.Tier = t
+			return existing.Limiter, nil
+		}
+	}
+	l, err := r.factory(t)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create rate limiter for tier %q: %w", t.Name, err)
+	}
+	r.limiters[t.Name] = &tierLimiter{Tier: t, Limiter: l}
+	return l, nil
+}
+
+func (r *RateLimiter) Remaining(ctx context.Context, tag string) (float64, error) {
+	l, err := r.limiter(ctx, tag)
+	if err != nil {
+		return 0, err
+	}
+	return l.Remaining(ctx, tag)
+}
+
+func (r *RateLimiter) Take(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) (
+	remaining float64,
+	ok bool,
+	err error,
+) {
+	l, err := r.limiter(ctx, tag)
+	if err != nil {
+		return 0, false, err
+	}
+	return l.Take(ctx, tag, tokens)
+}
+
+func (r *RateLimiter) Delay(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) (time.Duration, error) {
+	l, err := r.limiter(ctx, tag)
+	if err != nil {
+		return 0, err
+	}
+	return rate.Delay(ctx, l, tag, tokens)
+}
+
+func (r *RateLimiter) Put(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) error {
+	l, err := r.limiter(ctx, tag)
+	if err != nil {
+		return err
+	}
+	return l.Put(ctx, tag, tokens)
+}
@@ -0,0 +1,154 @@
+package tierrlm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+type planContextKey struct{}
+
+func newTestTier(t *testing.T, name string, limit float64) Tier {
+	t.Helper()
+	r, err := rate.New(limit, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Tier{Name: name, Rate: r}
+}
+
+func TestStaticTiers(t *testing.T) {
+	ctx := context.Background()
+	free := newTestTier(t, "free", 1)
+	pro := newTestTier(t, "pro", 3)
+	resolver, err := NewStaticResolver(map[string]string{"alice": "pro"}, pro)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := New(
+		WithResolver(resolver),
+		WithDefaultTier(free.Name, free.Rate, 0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for tag, limit := range map[string]int{"alice": 3, "bob": 1} {
+		for i := 0; i < limit; i++ {
+			if _, ok, err := l.Take(ctx, tag, 1); err != nil || !ok {
+				t.Fatalf("request %d of %q was limited: %v", i+1, tag, err)
+			}
+		}
+		if _, ok, err := l.Take(ctx, tag, 1); err != nil || ok {
+			t.Fatalf("tier of %q was not limited: %v", tag, err)
+		}
+		delay, err := l.Delay(ctx, tag, 1)
+		if err != nil || delay <= 0 {
+			t.Fatalf("tier of %q was not delayed: %v", tag, err)
+		}
+		tagRate, burstLimit, err := rate.TagRate(ctx, l, tag)
+		if err != nil || burstLimit != float64(limit) || tagRate.Burst() != float64(limit) {
+			t.Fatalf("rate of %q does not match its tier: %v", tag, err)
+		}
+	}
+
+	if _, err = NewStaticResolver(map[string]string{"alice": "gold"}, pro); err == nil {
+		t.Fatal("unknown tier was accepted")
+	}
+}
+
+func TestContextTiers(t *testing.T) {
+	free := newTestTier(t, "free", 1)
+	plans := map[string]Tier{
+		"enterprise": newTestTier(t, "enterprise", 100),
+	}
+	calls := 0
+	l, err := New(
+		WithResolver(ResolverFunc(func(ctx context.Context, tag string) (Tier, error) {
+			calls++
+			plan, ok := plans[ctx.Value(planContextKey{}).(string)]
+			if !ok {
+				return Tier{}, ErrTierNotFound
+			}
+			return plan, nil
+		})),
+		WithDefaultTier(free.Name, free.Rate, 0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(context.Background(), planContextKey{}, "enterprise")
+	for i := 0; i < 10; i++ {
+		if _, ok, err := l.Take(ctx, "acme", 1); err != nil || !ok {
+			t.Fatal("enterprise request was limited:", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("resolver was called %d times instead of being cached", calls)
+	}
+
+	tier, err := l.Tier(context.WithValue(context.Background(), planContextKey{}, "none"), "nobody")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tier.Name != "free" {
+		t.Fatalf("unknown plan resolved to tier %q instead of the default", tier.Name)
+	}
+
+	l.Forget("acme")
+	if _, _, err = l.Take(ctx, "acme", 1); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Fatalf("forgotten tag was not resolved again: %d calls", calls)
+	}
+}
+
+func TestTierRateChange(t *testing.T) {
+	ctx := context.Background()
+	free := newTestTier(t, "free", 2)
+	current := newTestTier(t, "pro", 4)
+	l, err := New(
+		WithResolver(ResolverFunc(func(ctx context.Context, tag string) (Tier, error) {
+			return current, nil
+		})),
+		WithDefaultTier(free.Name, free.Rate, 0),
+		WithCache(0, 0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = l.Take(ctx, "alice", 2); err != nil {
+		t.Fatal(err)
+	}
+	current = newTestTier(t, "pro", 8)
+	remaining, err := l.Remaining(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining < 3.9 || remaining > 4.1 {
+		t.Fatalf("reconfigured tier has %f tokens instead of about four", remaining)
+	}
+
+	current = Tier{Name: "broken"}
+	if _, _, err = l.Take(ctx, "alice", 1); err == nil {
+		t.Fatal("invalid tier was accepted")
+	}
+	failure := errors.New("database is down")
+	l, err = New(
+		WithResolver(ResolverFunc(func(ctx context.Context, tag string) (Tier, error) {
+			return Tier{}, failure
+		})),
+		WithDefaultTier(free.Name, free.Rate, 0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = l.Take(ctx, "alice", 1); !errors.Is(err, failure) {
+		t.Fatal("resolver error was not returned:", err)
+	}
+}
@@ -4,65 +4,409 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"log/slog"
 
+	"github.com/dkotik/oakratelimiter/rate"
 	"github.com/dkotik/oakratelimiter/request"
 )
 
-// RequestHandler applies a set of [request.Limiter]s to an [http.Request].
+// EvaluationStrategy determines how [RequestHandler] consults its [request.Limiter]s.
+type EvaluationStrategy uint8
+
+const (
+	// FullEvaluation consults every [request.Limiter] and reports all the rejections in [TooManyRequestsError]. It is useful for auditing.
+	FullEvaluation EvaluationStrategy = iota + 1
+
+	// ShortCircuitEvaluation stops at the first [request.Limiter] that rejects the request. Combine it with [WithEvaluationCost] to avoid expensive remote calls for requests that would be rejected anyway.
+	ShortCircuitEvaluation
+)
+
+// minimumDelay is the shortest time a request waits in the queue before trying to take tokens again.
+const minimumDelay = time.Millisecond
+
+// RequestHandler applies a set of [request.Limiter]s to an [http.Request]. The set can be replaced while the handler is serving requests using [RequestHandler.Reconfigure].
 type RequestHandler struct {
-	next            Handler
-	headerWriter    HeaderWriter
-	names           []string
-	requestLimiters []request.Limiter
+	next    Handler
+	current atomic.Pointer[limiterSet]
+
+	mu      sync.Mutex
+	wrapped map[wrapperKey]request.Limiter
 }
 
-// ServeHyperText satisfies an improved [http.Handler] interface.
+// Reconfigure atomically replaces the request limiters and all other settings, as if the [RequestHandler] was created anew with the given [Option]s. Requests in progress finish with the previous set. Pass the same request limiter instances to keep their state, and use [rate.Reconfigurable] to change their rates. Penalty boxes and failure policies of the same request limiter instances under the same names are kept together with their bans and circuit state, so their options are not applied again.
+func (rh *RequestHandler) Reconfigure(withOptions ...Option) error {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	o, err := newOptions(withOptions, rh.wrapped)
+	if err != nil {
+		return fmt.Errorf("cannot reconfigure Oak rate limiter: %w", err)
+	}
+	rh.wrapped = o.wrappers.current
+	rh.current.Store(o.newLimiterSet())
+	return nil
+}
+
+// limiterSet holds the request limiters and settings of a [RequestHandler]. Limiters are referred to by their index in aligned slices.
+type limiterSet struct {
+	headerWriter     HeaderWriter
+	errorRenderer    ErrorRenderer
+	observer         Observer
+	shortCircuit     bool
+	shadows          []bool
+	maxWait          time.Duration
+	queue            chan struct{}
+	names            []string
+	requestLimiters  []request.Limiter
+	responsePolicies []ResponsePolicy
+	deferred         []bool // aligned with responsePolicies, true for limiters charged after the response
+}
+
+// ServeHyperText satisfies an improved [http.Handler] interface. Taking tokens is all-or-nothing: if any [request.Limiter] rejects the request or fails, the tokens taken by the others are returned. Tokens may also be returned after the next [Handler] responds, if a [ResponsePolicy] says so, or taken only after the response, if the limiter was set up with [WithResponseCharge].
 func (rh *RequestHandler) ServeHyperText(
 	w http.ResponseWriter, r *http.Request,
+) error {
+	return rh.current.Load().serve(w, r, rh.next)
+}
+
+func (ls *limiterSet) serve(
+	w http.ResponseWriter, r *http.Request, next Handler,
 ) (err error) {
+	if ctx := request.NewMemoContext(r.Context()); ctx != r.Context() {
+		r = r.WithContext(ctx) // limiters remember what they took until release or refund
+	}
 	header := w.Header()
-	rejected := []string{}
-	remaining := float64(0)
-	ok := false
-	leastRemaining := float64(99999999)
-	for i, limiter := range rh.requestLimiters {
-		remaining, ok, err = limiter.Take(r)
-		if leastRemaining > remaining {
-			leastRemaining = remaining
+	policyHeaderWriter, reportPolicies := ls.headerWriter.(PolicyHeaderWriter)
+	d, err := ls.take(r, reportPolicies)
+	if err == nil && len(d.rejected) > 0 && ls.queue != nil {
+		d, err = ls.wait(r, d, reportPolicies)
+	}
+	if err != nil {
+		ls.headerWriter.ReportError(header)
+		return err
+	}
+	if reportPolicies {
+		policyHeaderWriter.ReportPolicies(header, d.policies)
+	}
+	if len(d.rejected) > 0 {
+		ls.headerWriter.ReportAccessDenied(header, d.leastRemaining)
+		rejected := make([]string, len(d.rejected))
+		for i, index := range d.rejected {
+			rejected[i] = ls.names[index]
+		}
+		return &TooManyRequestsError{
+			rejectedEndpointAccessControlNames: rejected,
+			reasons:                            d.reasons,
+			retryAfter:                         d.retryAfter,
+		}
+	}
+	ls.headerWriter.ReportAccessAllowed(header, d.leastRemaining)
+	defer ls.release(r, d.granted)
+	if ls.responsePolicies == nil {
+		return next.ServeHyperText(w, r)
+	}
+
+	var checked []int
+	if ls.deferred != nil {
+		checked = make([]int, 0, len(d.granted))
+		for _, i := range d.granted {
+			if ls.deferred[i] {
+				checked = append(checked, i)
+			}
+		}
+		ls.refund(r, checked) // charged after the response
+	}
+
+	recorder := &statusRecorder{ResponseWriter: w}
+	err = next.ServeHyperText(recorder, r)
+	status := responseStatus(recorder, err)
+	refunded := make([]int, 0, len(d.granted))
+	charged := make([]int, 0, len(checked))
+	for _, i := range d.granted {
+		policy := ls.responsePolicies[i]
+		switch {
+		case policy == nil:
+		case ls.deferred != nil && ls.deferred[i]:
+			if !policy(status) {
+				charged = append(charged, i)
+			}
+		case policy(status):
+			refunded = append(refunded, i)
+		}
+	}
+	ls.refund(r, refunded)
+	ls.charge(r, charged)
+	return err
+}
+
+// decision records the outcome of consulting the request limiters once. Limiters are referred to by their index.
+type decision struct {
+	granted        []int
+	rejected       []int
+	reasons        []string // aligned with rejected, empty unless a [request.Explainer] tells more
+	policies       []Policy
+	leastRemaining float64
+	retryAfter     time.Duration
+}
+
+// take consults the request limiters. If any of them rejects the request or fails, the tokens taken by the others are returned. Limiters without a [rate.Rate], like concurrency limits, are not reported as policies. Shadow limiters take tokens, but their rejections and failures are only logged.
+func (ls *limiterSet) take(
+	r *http.Request,
+	reportPolicies bool,
+) (*decision, error) {
+	d := &decision{
+		granted:        make([]int, 0, len(ls.requestLimiters)),
+		leastRemaining: float64(99999999),
+	}
+	if reportPolicies {
+		d.policies = make([]Policy, 0, len(ls.requestLimiters))
+	}
+	for i, limiter := range ls.requestLimiters {
+		started := time.Now()
+		remaining, ok, err := limiter.Take(r)
+		if ls.observer != nil {
+			ls.observe(r, i, started, remaining, ok, err)
+		}
+		if ls.shadows != nil && ls.shadows[i] {
+			switch {
+			case err != nil:
+				ls.logShadow(r, i, "shadow rate limiter failed", slog.Any("error", err))
+			case ok:
+				d.granted = append(d.granted, i)
+			default:
+				ls.logShadow(r, i, "shadow rate limiter would reject request", slog.Float64("remaining", remaining))
+			}
+			continue
 		}
 		if err != nil {
-			rh.headerWriter.ReportError(header)
-			return fmt.Errorf("rate limiter %q failed: %w", rh.names[i], err)
+			ls.refund(r, d.granted)
+			return nil, fmt.Errorf("rate limiter %q failed: %w", ls.names[i], err)
 		}
-		if !ok {
-			rejected = append(rejected, rh.names[i])
+		if d.leastRemaining > remaining {
+			d.leastRemaining = remaining
+		}
+		var (
+			cost        = float64(1)
+			limiterRate *rate.Rate
+			burstLimit  float64
+		)
+		if !ok || reportPolicies {
+			if cost, err = request.Cost(limiter, r); err != nil {
+				cost = 1 // the request was already decided
+			}
+			if limiterRate, burstLimit, err = request.RequestRate(limiter, r); err != nil {
+				if limiterRate = limiter.Rate(); limiterRate != nil {
+					burstLimit = limiterRate.Burst()
+				}
+			}
+		}
+		if ok {
+			d.granted = append(d.granted, i)
+		} else {
+			d.rejected = append(d.rejected, i)
+			reason, wait := request.Explain(limiter, r)
+			d.reasons = append(d.reasons, reason)
+			if wait == 0 && limiterRate != nil {
+				wait = limiterRate.ReplenishmentDuration(cost - remaining)
+			}
+			if wait > d.retryAfter {
+				d.retryAfter = wait
+			}
+		}
+		if reportPolicies && limiterRate != nil {
+			d.policies = append(d.policies, Policy{
+				Name:      ls.names[i],
+				Rate:      limiterRate,
+				Limit:     burstLimit,
+				Cost:      cost,
+				Remaining: remaining,
+				Rejected:  !ok,
+			})
+		}
+		if !ok && ls.shortCircuit {
+			break
 		}
 	}
-	if len(rejected) > 0 {
-		rh.headerWriter.ReportAccessDenied(header, leastRemaining)
-		return &TooManyRequestsError{
-			rejectedEndpointAccessControlNames: rejected,
+	if len(d.rejected) > 0 {
+		ls.refund(r, d.granted)
+	}
+	return d, nil
+}
+
+// wait holds a rejected request in the queue until the rejecting request limiters replenish their tokens. The request is rejected, if the queue is full or if the wait would exceed either the configured limit or the request [context.Context] deadline.
+func (ls *limiterSet) wait(
+	r *http.Request,
+	d *decision,
+	reportPolicies bool,
+) (*decision, error) {
+	select {
+	case ls.queue <- struct{}{}:
+		defer func() { <-ls.queue }()
+	default:
+		return d, nil // queue is full
+	}
+
+	ctx := r.Context()
+	deadline := time.Now().Add(ls.maxWait)
+	if contextDeadline, ok := ctx.Deadline(); ok && contextDeadline.Before(deadline) {
+		deadline = contextDeadline
+	}
+	for len(d.rejected) > 0 {
+		delay, err := ls.delay(r, d.rejected)
+		if errors.Is(err, request.ErrUnknownDelay) {
+			return d, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return d, nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return d, nil
+		case <-timer.C:
+		}
+		if d, err = ls.take(r, reportPolicies); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// delay returns the longest time it takes for the rejecting request limiters to replenish the tokens for a request.
+func (ls *limiterSet) delay(
+	r *http.Request,
+	rejected []int,
+) (longest time.Duration, err error) {
+	for _, i := range rejected {
+		current, err := request.Delay(ls.requestLimiters[i], r)
+		if errors.Is(err, request.ErrUnknownDelay) {
+			return 0, err
+		}
+		if err != nil {
+			return 0, fmt.Errorf("rate limiter %q failed: %w", ls.names[i], err)
+		}
+		if current > longest {
+			longest = current
+		}
+	}
+	if longest < minimumDelay {
+		longest = minimumDelay // avoid spinning when tokens are contested
+	}
+	return longest, nil
+}
+
+// refund returns tokens to the request limiters that granted them. Refund failures are logged, because the request is already decided.
+func (ls *limiterSet) refund(r *http.Request, granted []int) {
+	for _, i := range granted {
+		if err := ls.requestLimiters[i].Put(r); err != nil {
+			slog.Log(
+				r.Context(),
+				slog.LevelWarn,
+				"rate limiter could not refund tokens",
+				slog.String("name", ls.names[i]),
+				slog.Any("error", err),
+			)
+		}
+	}
+}
+
+// charge takes tokens from the request limiters after the response. Failures are logged, because the request is already served. A limiter that ran out of tokens in the meantime is not charged.
+func (ls *limiterSet) charge(r *http.Request, charged []int) {
+	for _, i := range charged {
+		if _, _, err := ls.requestLimiters[i].Take(r); err != nil {
+			slog.Log(
+				r.Context(),
+				slog.LevelWarn,
+				"rate limiter could not charge tokens",
+				slog.String("name", ls.names[i]),
+				slog.Any("error", err),
+			)
+		}
+	}
+}
+
+// observe notifies the [Observer] about the outcome of a single request limiter.
+func (ls *limiterSet) observe(
+	r *http.Request,
+	i int,
+	started time.Time,
+	remaining float64,
+	ok bool,
+	err error,
+) {
+	event := Event{
+		Name:      ls.names[i],
+		Outcome:   OutcomeAllowed,
+		Shadow:    ls.shadows != nil && ls.shadows[i],
+		Remaining: remaining,
+		Latency:   time.Since(started),
+		Error:     err,
+	}
+	switch {
+	case err != nil:
+		event.Outcome = OutcomeFailed
+	case !ok:
+		event.Outcome = OutcomeDenied
+	}
+	if tag, err := request.Tag(ls.requestLimiters[i], r); err == nil {
+		event.Tag = tag
+	}
+	ls.observer.Observe(r.Context(), event)
+}
+
+// logShadow records a would-be rejection or a failure of a shadow request limiter together with the request tag, if the limiter can tell it.
+func (ls *limiterSet) logShadow(r *http.Request, i int, msg string, attrs ...slog.Attr) {
+	attrs = append(attrs, slog.String("name", ls.names[i]))
+	if tag, err := request.Tag(ls.requestLimiters[i], r); err == nil {
+		attrs = append(attrs, slog.String("tag", tag))
+	}
+	slog.LogAttrs(r.Context(), slog.LevelWarn, msg, attrs...)
+}
+
+// release frees the tokens held by [request.Releaser]s while the request was being served.
+func (ls *limiterSet) release(r *http.Request, granted []int) {
+	for _, i := range granted {
+		releaser, ok := ls.requestLimiters[i].(request.Releaser)
+		if !ok {
+			continue
+		}
+		if err := releaser.Release(r); err != nil {
+			slog.Log(
+				r.Context(),
+				slog.LevelWarn,
+				"rate limiter could not release tokens",
+				slog.String("name", ls.names[i]),
+				slog.Any("error", err),
+			)
 		}
 	}
-	rh.headerWriter.ReportAccessAllowed(header, leastRemaining)
-	return rh.next.ServeHyperText(w, r)
 }
 
 // ServeHTTP satisfies [http.Handler] for compatibility with the standard library.
 func (rh *RequestHandler) ServeHTTP(
 	w http.ResponseWriter, r *http.Request,
 ) {
-	err := rh.ServeHyperText(w, r)
-	if err == nil {
-		return
+	set := rh.current.Load()
+	if err := set.serve(w, r, rh.next); err != nil {
+		writeError(w, r, err, set.errorRenderer)
 	}
+}
+
+// writeError renders the error and logs it.
+func writeError(w http.ResponseWriter, r *http.Request, err error, renderer ErrorRenderer) {
+	renderer.RenderError(w, r, err)
 	var httpError Error
 	if errors.As(err, &httpError) {
 		msg := err.Error()
 		code := httpError.HyperTextStatusCode()
-		http.Error(w, msg, code)
 		slog.Log(
 			r.Context(),
 			slog.LevelWarn,
@@ -72,7 +416,6 @@ func (rh *RequestHandler) ServeHTTP(
 		)
 		return
 	}
-	http.Error(w, err.Error(), http.StatusInternalServerError)
 	slog.Log(
 		r.Context(),
 		slog.LevelError,
@@ -110,7 +453,7 @@ func (rh *RequestHandler) ServeHTTP(
 // 		}
 // 	}
 // 	rh.headerWriter.ReportAccessAllowed(header, leastRemaining)
-// 	return rh.next.ServeHyperText(w, r)
+// 	return next.ServeHyperText(w, r)
 // }
 //
 // func (rh *SingleLimiterRequestHandler) ServeHTTP(
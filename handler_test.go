@@ -0,0 +1,527 @@
+package oakratelimiter
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/driver/mutexrlm"
+	"github.com/dkotik/oakratelimiter/rate"
+	"github.com/dkotik/oakratelimiter/request"
+	"github.com/dkotik/oakratelimiter/request/breaker"
+	"github.com/dkotik/oakratelimiter/request/inflight"
+	"github.com/dkotik/oakratelimiter/request/penalty"
+	"github.com/dkotik/oakratelimiter/request/tagbyip"
+)
+
+var noContent = HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+})
+
+// rejectingLimiter rejects every request.
+type rejectingLimiter struct {
+	rate *rate.Rate
+}
+
+func (l *rejectingLimiter) Rate() *rate.Rate {
+	return l.rate
+}
+
+func (l *rejectingLimiter) Take(*http.Request) (float64, bool, error) {
+	return 0, false, nil
+}
+
+func (l *rejectingLimiter) Put(*http.Request) error {
+	return nil
+}
+
+func TestRequestHandlerRefund(t *testing.T) {
+	global, err := mutexrlm.NewRequestLimiter(mutexrlm.WithNewRate(2, time.Minute))
+	if err != nil {
+		t.Fatal("cannot initialize request limiter:", err)
+	}
+	rejecting := &rejectingLimiter{rate: global.Rate()}
+	h, err := New(
+		noContent,
+		WithRequestLimiter("global", global),
+		WithRequestLimiter("rejecting", rejecting),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize request handler:", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		err = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		var tooMany *TooManyRequestsError
+		if !errors.As(err, &tooMany) {
+			t.Fatal("request was not rejected:", err)
+		}
+		if tooMany.RetryAfter() != 30*time.Second {
+			t.Fatal("unexpected retry after:", tooMany.RetryAfter())
+		}
+	}
+
+	_, ok, err := global.Take(httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("rejected requests drained the global limiter")
+	}
+}
+
+// countingLimiter counts how many times it was consulted.
+type countingLimiter struct {
+	rejectingLimiter
+	taken int
+}
+
+func (l *countingLimiter) Take(r *http.Request) (float64, bool, error) {
+	l.taken++
+	return 1, true, nil
+}
+
+func TestRequestHandlerShortCircuitEvaluation(t *testing.T) {
+	r, err := rate.New(2, time.Minute)
+	if err != nil {
+		t.Fatal("cannot initialize rate:", err)
+	}
+	expensive := &countingLimiter{rejectingLimiter: rejectingLimiter{rate: r}}
+	cheap := &rejectingLimiter{rate: r}
+
+	cases := []struct {
+		Strategy EvaluationStrategy
+		Rejected int
+		Taken    int
+	}{
+		{Strategy: ShortCircuitEvaluation, Rejected: 1, Taken: 0},
+		{Strategy: FullEvaluation, Rejected: 1, Taken: 1},
+	}
+	for _, c := range cases {
+		expensive.taken = 0
+		h, err := New(
+			noContent,
+			WithRequestLimiter("expensive", expensive),
+			WithRequestLimiter("cheap", cheap),
+			WithEvaluationStrategy(c.Strategy),
+			WithEvaluationCost("expensive", 10),
+			WithEvaluationCost("cheap", 1),
+		)
+		if err != nil {
+			t.Fatal("cannot initialize request handler:", err)
+		}
+
+		err = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		var tooMany *TooManyRequestsError
+		if !errors.As(err, &tooMany) {
+			t.Fatal("request was not rejected:", err)
+		}
+		if len(tooMany.rejectedEndpointAccessControlNames) != c.Rejected {
+			t.Fatal("unexpected rejections:", tooMany.rejectedEndpointAccessControlNames)
+		}
+		if expensive.taken != c.Taken {
+			t.Fatalf("expensive limiter was consulted %d times instead of %d", expensive.taken, c.Taken)
+		}
+	}
+
+	if _, err = New(
+		noContent,
+		WithRequestLimiter("cheap", cheap),
+		WithEvaluationCost("unknown", 1),
+	); err == nil {
+		t.Fatal("evaluation cost of an unknown limiter was accepted")
+	}
+}
+
+func TestRequestHandlerResponsePolicy(t *testing.T) {
+	status := http.StatusOK
+	next := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if status == http.StatusInternalServerError {
+			return errors.New("server failure")
+		}
+		w.WriteHeader(status)
+		return nil
+	})
+
+	unauthorized, err := ChargeOnly(http.StatusUnauthorized)
+	if err != nil {
+		t.Fatal("cannot initialize response policy:", err)
+	}
+	if _, err = ChargeOnly(); err == nil {
+		t.Fatal("empty list of charged status codes was accepted")
+	}
+
+	cases := []struct {
+		Policy   ResponsePolicy
+		Deferred bool
+		Status   int
+		Rejected bool
+	}{
+		{Policy: unauthorized, Status: http.StatusOK, Rejected: false},
+		{Policy: unauthorized, Status: http.StatusUnauthorized, Rejected: true},
+		{Policy: RefundServerErrors, Status: http.StatusInternalServerError, Rejected: false},
+		{Policy: RefundServerErrors, Status: http.StatusOK, Rejected: true},
+		{Policy: unauthorized, Deferred: true, Status: http.StatusOK, Rejected: false},
+		{Policy: unauthorized, Deferred: true, Status: http.StatusUnauthorized, Rejected: true},
+		{Policy: RefundServerErrors, Deferred: true, Status: http.StatusInternalServerError, Rejected: false},
+	}
+
+	for i, c := range cases {
+		global, err := mutexrlm.NewRequestLimiter(mutexrlm.WithNewRate(2, time.Minute))
+		if err != nil {
+			t.Fatal("cannot initialize request limiter:", err)
+		}
+		withPolicy := WithResponsePolicy("global", c.Policy)
+		if c.Deferred {
+			withPolicy = WithResponseCharge("global", c.Policy)
+		}
+		h, err := New(
+			next,
+			WithRequestLimiter("global", global),
+			withPolicy,
+		)
+		if err != nil {
+			t.Fatal("cannot initialize request handler:", err)
+		}
+
+		status = c.Status
+		var tooMany *TooManyRequestsError
+		for j := 0; j < 5; j++ {
+			err = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/login", nil))
+			if errors.As(err, &tooMany) {
+				break
+			}
+		}
+		if rejected := tooMany != nil; rejected != c.Rejected {
+			t.Fatalf("case %d: rejection %t does not match %t", i+1, rejected, c.Rejected)
+		}
+	}
+}
+
+func TestRequestHandlerResponseCharge(t *testing.T) {
+	global, err := mutexrlm.NewRequestLimiter(mutexrlm.WithNewRate(2, time.Minute))
+	if err != nil {
+		t.Fatal("cannot initialize request limiter:", err)
+	}
+	h, err := New(
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			if remaining, _, err := global.Take(r); err != nil || remaining < 1 {
+				t.Fatal("tokens were held while the request was served:", remaining, err)
+			}
+			if err := global.Put(r); err != nil {
+				t.Fatal(err)
+			}
+			w.(http.Flusher).Flush()
+			return nil
+		}),
+		WithRequestLimiter("global", global),
+		WithResponseCharge("global", RefundServerErrors),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize request handler:", err)
+	}
+	w := httptest.NewRecorder()
+	if err = h.ServeHyperText(w, httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if !w.Flushed {
+		t.Fatal("flush did not reach the response writer")
+	}
+	remaining, _, err := global.Take(httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil || remaining > 0.01 {
+		t.Fatal("tokens were not charged after the response:", remaining, err)
+	}
+}
+
+func TestRequestHandlerWaitQueue(t *testing.T) {
+	global, err := mutexrlm.NewRequestLimiter(mutexrlm.WithNewRate(1, 50*time.Millisecond))
+	if err != nil {
+		t.Fatal("cannot initialize request limiter:", err)
+	}
+	h, err := New(
+		noContent,
+		WithRequestLimiter("global", global),
+		WithWaitQueue(time.Second, 1),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize request handler:", err)
+	}
+
+	started := time.Now()
+	for i := 0; i < 3; i++ {
+		if err = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+			t.Fatal("queued request was rejected:", err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed < 90*time.Millisecond {
+		t.Fatal("queued requests did not wait for tokens:", elapsed)
+	}
+
+	h, err = New(
+		noContent,
+		WithRequestLimiter("global", global),
+		WithWaitQueue(10*time.Millisecond, 1),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize request handler:", err)
+	}
+	_ = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	err = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	var tooMany *TooManyRequestsError
+	if !errors.As(err, &tooMany) {
+		t.Fatal("request that cannot wait long enough was not rejected:", err)
+	}
+
+	h, err = New(
+		noContent,
+		WithRequestLimiter("rejecting", &rejectingLimiter{rate: global.Rate()}),
+		WithWaitQueue(time.Second, 1),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize request handler:", err)
+	}
+	err = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !errors.As(err, &tooMany) {
+		t.Fatal("request with unknown delay was not rejected:", err)
+	}
+}
+
+func TestRequestHandlerConcurrencyLimiter(t *testing.T) {
+	release := make(chan struct{})
+	served := make(chan struct{})
+	h, err := New(
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			switch r.URL.Path {
+			case "/slow":
+				served <- struct{}{}
+				<-release
+			case "/panic":
+				panic("handler failed")
+			}
+			return nil
+		}),
+		WithConcurrencyLimiter("reports", inflight.WithLimit(1)),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize request handler:", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	}()
+	<-served
+	err = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	var tooMany *TooManyRequestsError
+	if !errors.As(err, &tooMany) {
+		t.Fatal("concurrent request was not rejected:", err)
+	}
+	close(release)
+	if err = <-done; err != nil {
+		t.Fatal("slow request failed:", err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("handler did not panic")
+			}
+		}()
+		_ = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+	}()
+	if err = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+		t.Fatal("slot was not released after panic:", err)
+	}
+}
+
+func TestRequestHandlerShadowMode(t *testing.T) {
+	global, err := mutexrlm.NewRequestLimiter(mutexrlm.WithNewRate(2, time.Minute))
+	if err != nil {
+		t.Fatal("cannot initialize request limiter:", err)
+	}
+	h, err := New(
+		noContent,
+		WithRequestLimiter("global", global),
+		WithRequestLimiter("candidate", &rejectingLimiter{rate: global.Rate()}),
+		WithShadowMode("candidate"),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize request handler:", err)
+	}
+
+	if err = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+		t.Fatal("shadow limiter rejected the request:", err)
+	}
+	_ = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	err = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	var tooMany *TooManyRequestsError
+	if !errors.As(err, &tooMany) {
+		t.Fatal("enforcing limiter did not reject the request:", err)
+	}
+	if rejected := tooMany.RejectedBy(); len(rejected) != 1 || rejected[0] != "global" {
+		t.Fatal("shadow limiter was reported as rejecting:", rejected)
+	}
+
+	if _, err = New(
+		noContent,
+		WithRequestLimiter("global", global),
+		WithShadowMode("unknown"),
+	); err == nil {
+		t.Fatal("shadow mode of an unknown limiter was accepted")
+	}
+}
+
+// failingLimiter always returns an error.
+type failingLimiter struct {
+	rejectingLimiter
+}
+
+func (l *failingLimiter) Take(*http.Request) (float64, bool, error) {
+	return 0, false, errors.New("database is unreachable")
+}
+
+func TestRequestHandlerFailurePolicy(t *testing.T) {
+	r, err := rate.New(2, time.Minute)
+	if err != nil {
+		t.Fatal("cannot initialize rate:", err)
+	}
+	h, err := New(
+		noContent,
+		WithRequestLimiter("database", &failingLimiter{rejectingLimiter{rate: r}}),
+		WithFailurePolicy("database", breaker.WithPolicy(breaker.FailOpen)),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize request handler:", err)
+	}
+	if err = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+		t.Fatal("failing limiter did not fail open:", err)
+	}
+}
+
+func TestRequestHandlerReconfigure(t *testing.T) {
+	global, err := mutexrlm.NewRequestLimiter(mutexrlm.WithNewRate(1, time.Minute))
+	if err != nil {
+		t.Fatal("cannot initialize request limiter:", err)
+	}
+	h, err := New(noContent, WithRequestLimiter("global", global))
+	if err != nil {
+		t.Fatal("cannot initialize request handler:", err)
+	}
+	_ = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	var tooMany *TooManyRequestsError
+	if err = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil)); !errors.As(err, &tooMany) {
+		t.Fatal("request was not rejected:", err)
+	}
+
+	relaxed, err := mutexrlm.NewRequestLimiter(mutexrlm.WithNewRate(5, time.Minute))
+	if err != nil {
+		t.Fatal("cannot initialize request limiter:", err)
+	}
+	if err = h.Reconfigure(WithRequestLimiter("global", relaxed)); err != nil {
+		t.Fatal("cannot reconfigure request handler:", err)
+	}
+	if err = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+		t.Fatal("replaced limiter was still used:", err)
+	}
+	if err = h.Reconfigure(); err == nil {
+		t.Fatal("reconfiguration without request limiters was accepted")
+	}
+}
+
+func TestRequestHandlerPenaltyBox(t *testing.T) {
+	h, err := New(
+		noContent,
+		WithIPAddressTagger(tagbyip.WithNewRate(1, time.Minute)),
+		WithPenaltyBox(
+			"internetProtocolAddress",
+			penalty.WithThreshold(1, time.Hour),
+			penalty.WithEscalation(time.Hour),
+		),
+		WithFailurePolicy("internetProtocolAddress"),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize request handler:", err)
+	}
+	var tooMany *TooManyRequestsError
+	for i := 0; i < 3; i++ {
+		err = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	if !errors.As(err, &tooMany) {
+		t.Fatal("request was not rejected:", err)
+	}
+	if reason := tooMany.Reasons()["internetProtocolAddress"]; reason == "" {
+		t.Fatal("ban was not reported:", tooMany.Reasons())
+	}
+	if tooMany.RetryAfter() < time.Minute*59 {
+		t.Fatal("retry after does not match the ban:", tooMany.RetryAfter())
+	}
+
+	if _, err = New(
+		noContent,
+		WithIPAddressTagger(tagbyip.WithNewRate(1, time.Minute)),
+		WithPenaltyBox("unknown"),
+	); err == nil {
+		t.Fatal("penalty box of an unknown limiter was accepted")
+	}
+}
+
+func TestRequestHandlerReconfigureKeepsPenaltyBox(t *testing.T) {
+	byAddress, err := tagbyip.New(tagbyip.WithNewRate(1, time.Minute))
+	if err != nil {
+		t.Fatal("cannot initialize request limiter:", err)
+	}
+	withOptions := []Option{
+		WithRequestLimiter("address", byAddress),
+		WithPenaltyBox(
+			"address",
+			penalty.WithThreshold(1, time.Hour),
+			penalty.WithEscalation(time.Hour),
+		),
+	}
+	h, err := New(noContent, withOptions...)
+	if err != nil {
+		t.Fatal("cannot initialize request handler:", err)
+	}
+	for i := 0; i < 3; i++ {
+		_ = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	if err = h.Reconfigure(withOptions...); err != nil {
+		t.Fatal("cannot reconfigure request handler:", err)
+	}
+	var tooMany *TooManyRequestsError
+	err = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !errors.As(err, &tooMany) || tooMany.Reasons()["address"] == "" {
+		t.Fatal("reconfiguration lifted the ban:", err)
+	}
+}
+
+func TestRequestHandlerRetryAfterCost(t *testing.T) {
+	expensive, err := request.NewFixedCoster(10)
+	if err != nil {
+		t.Fatal("cannot initialize coster:", err)
+	}
+	l, err := mutexrlm.NewRequestLimiter(
+		mutexrlm.WithNewRate(10, time.Minute),
+		mutexrlm.WithCoster(expensive),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize request limiter:", err)
+	}
+	h, err := New(noContent, WithRequestLimiter("export", l))
+	if err != nil {
+		t.Fatal("cannot initialize request handler:", err)
+	}
+	_ = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	var tooMany *TooManyRequestsError
+	err = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !errors.As(err, &tooMany) {
+		t.Fatal("request was not rejected:", err)
+	}
+	if tooMany.RetryAfter() < time.Second*59 {
+		t.Fatal("retry after does not cover the request cost:", tooMany.RetryAfter())
+	}
+}
@@ -4,14 +4,18 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dkotik/oakratelimiter/rate"
 )
 
 var ( // enforce interface compliance
-	_ HeaderWriter = (*SilentHeaderWriter)(nil)
-	_ HeaderWriter = (*ObfuscatingHeaderWriter)(nil)
+	_ HeaderWriter       = (*SilentHeaderWriter)(nil)
+	_ HeaderWriter       = (*ObfuscatingHeaderWriter)(nil)
+	_ PolicyHeaderWriter = (*RateLimitHeaderWriter)(nil)
+	_ PolicyHeaderWriter = (*TruthfulHeaderWriter)(nil)
 )
 
 // HeaderWriter reports rate limiter state.
@@ -21,6 +25,22 @@ type HeaderWriter interface {
 	ReportError(header http.Header)
 }
 
+// Policy describes the state of a named [request.Limiter] after [RequestHandler] consulted it. Rate and Limit are the refill rate and the burst limit applied to the request, as reported by [request.RequestRate]. Cost is the number of tokens the request takes, as reported by [request.Cost].
+type Policy struct {
+	Name      string
+	Rate      *rate.Rate
+	Limit     float64
+	Cost      float64
+	Remaining float64
+	Rejected  bool
+}
+
+// PolicyHeaderWriter is a [HeaderWriter] that also receives the state of every consulted [request.Limiter]. [RequestHandler] calls ReportPolicies before ReportAccessAllowed or ReportAccessDenied. Policies are ordered the same way as the request limiters.
+type PolicyHeaderWriter interface {
+	HeaderWriter
+	ReportPolicies(header http.Header, policies []Policy)
+}
+
 // SilentHeaderWriter does not write any headers.
 type SilentHeaderWriter struct{}
 
@@ -36,9 +56,18 @@ type ObfuscatingHeaderWriter struct {
 
 // NewObfuscatingHeaderWriter creates an [ObfuscatingHeaderWriter] using a given rate, which may differ from the actual rate.
 func NewObfuscatingHeaderWriter(displayRate *rate.Rate) HeaderWriter {
-	limit := uint(1)
+	oneTokenWindow, limit := obfuscate(displayRate)
+	return &ObfuscatingHeaderWriter{
+		oneTokenWindow:   oneTokenWindow,
+		displayRateLimit: fmt.Sprintf("%d", limit),
+	}
+}
+
+// obfuscate rounds the display [rate.Rate] to a window of at least one second that promises no more than one token.
+func obfuscate(displayRate *rate.Rate) (oneTokenWindow time.Duration, limit uint) {
+	limit = uint(1)
 	perNano := displayRate.PerNanosecond()
-	oneTokenWindow := time.Nanosecond * time.Duration(1.05/perNano)
+	oneTokenWindow = time.Nanosecond * time.Duration(1.05/perNano)
 	if oneTokenWindow < time.Second {
 		limit = uint(math.Min(
 			math.Floor(float64(time.Second.Nanoseconds())*float64(perNano*0.95)),
@@ -46,10 +75,7 @@ func NewObfuscatingHeaderWriter(displayRate *rate.Rate) HeaderWriter {
 		))
 		oneTokenWindow = time.Second
 	}
-	return &ObfuscatingHeaderWriter{
-		oneTokenWindow:   oneTokenWindow,
-		displayRateLimit: fmt.Sprintf("%d", limit),
-	}
+	return oneTokenWindow, limit
 }
 
 // ReportAccessAllowed indicates that the request was not limited.
@@ -93,3 +119,165 @@ func (o *ObfuscatingHeaderWriter) ReportError(h http.Header) {
 	h.Set("X-RateLimit-Limit", o.displayRateLimit)
 	h.Set("X-RateLimit-Reset", t)
 }
+
+// TruthfulHeaderWriter reports the real state of the most limiting [request.Limiter] using "X-RateLimit-*" headers. Unlike [ObfuscatingHeaderWriter], it reveals the actual number of remaining tokens, the burst limit, and the time when the bucket is full again. Use it for internal and partner APIs, where callers are trusted to pace themselves.
+type TruthfulHeaderWriter struct{}
+
+// NewTruthfulHeaderWriter creates a [TruthfulHeaderWriter].
+func NewTruthfulHeaderWriter() *TruthfulHeaderWriter {
+	return &TruthfulHeaderWriter{}
+}
+
+// ReportPolicies writes the state of the rejecting policy that takes the longest to replenish one token or, if none rejected the request, the policy with the fewest remaining tokens. Reset time is computed from the refill speed of the policy [rate.Rate].
+func (w *TruthfulHeaderWriter) ReportPolicies(
+	h http.Header,
+	policies []Policy,
+) {
+	if len(policies) == 0 {
+		return
+	}
+	limiting := policies[0]
+	for _, policy := range policies[1:] {
+		switch {
+		case limiting.Rejected && !policy.Rejected:
+			continue
+		case policy.Rejected && !limiting.Rejected:
+			limiting = policy
+		case policy.Rejected:
+			if retryAfter(policy) > retryAfter(limiting) {
+				limiting = policy
+			}
+		case policy.Remaining < limiting.Remaining:
+			limiting = policy
+		}
+	}
+
+	at := time.Now()
+	burst := limiting.Limit
+	perNano := limiting.Rate.PerNanosecond()
+	h.Set("X-RateLimit-Limit", strconv.FormatFloat(math.Floor(burst), 'f', 0, 64))
+	h.Set("X-RateLimit-Remaining", strconv.FormatFloat(
+		math.Max(math.Floor(limiting.Remaining), 0), 'f', 0, 64))
+	h.Set("X-RateLimit-Reset", at.Add(time.Duration(
+		math.Max(burst-limiting.Remaining, 0)/perNano,
+	)).UTC().Format(http.TimeFormat))
+	if limiting.Rejected {
+		h.Set("Retry-After", at.Add(retryAfter(limiting)).UTC().Format(http.TimeFormat))
+	}
+}
+
+// ReportAccessAllowed does nothing, because all the headers are written by [TruthfulHeaderWriter.ReportPolicies].
+func (w *TruthfulHeaderWriter) ReportAccessAllowed(http.Header, float64) {}
+
+// ReportAccessDenied does nothing, because all the headers are written by [TruthfulHeaderWriter.ReportPolicies].
+func (w *TruthfulHeaderWriter) ReportAccessDenied(http.Header, float64) {}
+
+// ReportError does nothing, because the state of the failing rate limiter is unknown.
+func (w *TruthfulHeaderWriter) ReportError(http.Header) {}
+
+// RateLimitHeaderWriter reports the state of every named [request.Limiter] using "RateLimit" and "RateLimit-Policy" structured fields from the IETF HTTP API working group [draft]. Each limiter is listed as a separate policy. Quota is the burst limit of the limiter and window is the interval of its [rate.Rate]. When constructed with a display [rate.Rate], every policy promises no more than one token, like [ObfuscatingHeaderWriter].
+//
+// [draft]: https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/
+type RateLimitHeaderWriter struct {
+	obfuscated     bool
+	oneTokenWindow time.Duration
+	displayLimit   uint
+}
+
+// NewRateLimitHeaderWriter creates a [RateLimitHeaderWriter] that reports real quotas, remaining tokens, and reset times.
+func NewRateLimitHeaderWriter() *RateLimitHeaderWriter {
+	return &RateLimitHeaderWriter{}
+}
+
+// NewObfuscatingRateLimitHeaderWriter creates a [RateLimitHeaderWriter] that reports the display rate for every policy, which may differ from the actual rate.
+func NewObfuscatingRateLimitHeaderWriter(displayRate *rate.Rate) *RateLimitHeaderWriter {
+	oneTokenWindow, limit := obfuscate(displayRate)
+	return &RateLimitHeaderWriter{
+		obfuscated:     true,
+		oneTokenWindow: oneTokenWindow,
+		displayLimit:   limit,
+	}
+}
+
+// ReportPolicies writes one "RateLimit-Policy" and one "RateLimit" list member for each policy. If any policy rejected the request, "Retry-After" is also set.
+func (w *RateLimitHeaderWriter) ReportPolicies(
+	h http.Header,
+	policies []Policy,
+) {
+	var (
+		quota, window, remaining, reset int64
+		longestWait                     int64
+	)
+	for _, policy := range policies {
+		if w.obfuscated {
+			quota = int64(w.displayLimit)
+			window = seconds(w.oneTokenWindow)
+			reset = window
+			remaining = 1
+			if policy.Rejected {
+				remaining = 0
+			}
+		} else {
+			perNano := policy.Rate.PerNanosecond()
+			quota = int64(math.Floor(policy.Limit))
+			window = seconds(policy.Rate.Interval())
+			remaining = int64(math.Max(math.Floor(policy.Remaining), 0))
+			reset = seconds(time.Duration(
+				math.Max(policy.Limit-policy.Remaining, 0) / perNano,
+			))
+		}
+		if policy.Rejected {
+			wait := reset
+			if !w.obfuscated {
+				wait = seconds(retryAfter(policy))
+			}
+			if wait > longestWait {
+				longestWait = wait
+			}
+		}
+
+		name := structuredFieldString(policy.Name)
+		h.Add("RateLimit-Policy", fmt.Sprintf("%s;q=%d;w=%d", name, quota, window))
+		h.Add("RateLimit", fmt.Sprintf("%s;r=%d;t=%d", name, remaining, reset))
+	}
+	if longestWait > 0 {
+		h.Set("Retry-After", strconv.FormatInt(longestWait, 10))
+	}
+}
+
+// ReportAccessAllowed does nothing, because all the headers are written by [RateLimitHeaderWriter.ReportPolicies].
+func (w *RateLimitHeaderWriter) ReportAccessAllowed(http.Header, float64) {}
+
+// ReportAccessDenied does nothing, because all the headers are written by [RateLimitHeaderWriter.ReportPolicies].
+func (w *RateLimitHeaderWriter) ReportAccessDenied(http.Header, float64) {}
+
+// ReportError does nothing, because the state of the failing rate limiter is unknown.
+func (w *RateLimitHeaderWriter) ReportError(http.Header) {}
+
+// retryAfter estimates how long it takes for the [Policy] to replenish the tokens the request costs.
+func retryAfter(p Policy) time.Duration {
+	return time.Duration(math.Max(p.Cost-p.Remaining, 0) / p.Rate.PerNanosecond())
+}
+
+// seconds rounds a [time.Duration] up to whole seconds.
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// structuredFieldString quotes a policy name as a structured field string as defined by RFC 8941. Characters outside of printable ASCII are dropped.
+func structuredFieldString(s string) string {
+	b := strings.Builder{}
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for _, c := range []byte(s) {
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c >= 0x20 && c < 0x7f:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
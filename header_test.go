@@ -0,0 +1,127 @@
+package oakratelimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/driver/mutexrlm"
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+func TestRateLimitHeaderWriter(t *testing.T) {
+	r, err := rate.New(10, time.Minute)
+	if err != nil {
+		t.Fatal("cannot initialize rate:", err)
+	}
+	policies := []Policy{
+		{Name: "global", Rate: r, Limit: 20, Cost: 1, Remaining: 4.5},
+		{Name: `cookie:"session"`, Rate: r, Limit: 10, Cost: 3, Remaining: 0.5, Rejected: true},
+	}
+
+	h := http.Header{}
+	NewRateLimitHeaderWriter().ReportPolicies(h, policies)
+	cases := []struct {
+		Header   string
+		Expected []string
+	}{
+		{Header: "RateLimit-Policy", Expected: []string{
+			`"global";q=20;w=60`,
+			`"cookie:\"session\"";q=10;w=60`,
+		}},
+		{Header: "RateLimit", Expected: []string{
+			`"global";r=4;t=93`,
+			`"cookie:\"session\"";r=0;t=57`,
+		}},
+		{Header: "Retry-After", Expected: []string{"15"}}, // waits for the whole cost
+	}
+	for _, c := range cases {
+		values := h.Values(c.Header)
+		if len(values) != len(c.Expected) {
+			t.Fatalf("header %q has values %v, expected %v", c.Header, values, c.Expected)
+		}
+		for i, value := range values {
+			if value != c.Expected[i] {
+				t.Fatalf("header %q value %q does not match %q", c.Header, value, c.Expected[i])
+			}
+		}
+	}
+
+	h = http.Header{}
+	NewObfuscatingRateLimitHeaderWriter(r).ReportPolicies(h, policies)
+	if value := h.Values("RateLimit")[1]; value != `"cookie:\"session\"";r=0;t=7` {
+		t.Fatal("obfuscated rejection does not match:", value)
+	}
+}
+
+func TestTruthfulHeaderWriter(t *testing.T) {
+	fast, err := rate.New(10, time.Second)
+	if err != nil {
+		t.Fatal("cannot initialize rate:", err)
+	}
+	slow, err := rate.New(4, time.Minute)
+	if err != nil {
+		t.Fatal("cannot initialize rate:", err)
+	}
+
+	h := http.Header{}
+	NewTruthfulHeaderWriter().ReportPolicies(h, []Policy{
+		{Name: "global", Rate: fast, Limit: 15, Cost: 1, Remaining: 2.7},
+		{Name: "internetProtocolAddress", Rate: slow, Limit: 4, Cost: 1, Remaining: 3},
+	})
+	if limit := h.Get("X-RateLimit-Limit"); limit != "15" { // configured burst limit
+		t.Fatal("limit does not match:", limit)
+	}
+	if remaining := h.Get("X-RateLimit-Remaining"); remaining != "2" {
+		t.Fatal("remaining tokens do not match:", remaining)
+	}
+	if h.Get("Retry-After") != "" {
+		t.Fatal("retry after header must not be set when access is allowed")
+	}
+
+	h = http.Header{}
+	NewTruthfulHeaderWriter().ReportPolicies(h, []Policy{
+		{Name: "global", Rate: fast, Limit: 10, Cost: 1, Remaining: 0.2, Rejected: true},
+		{Name: "internetProtocolAddress", Rate: slow, Limit: 4, Cost: 1, Remaining: 0.5, Rejected: true},
+		{Name: "cookie:session", Rate: fast, Limit: 10, Cost: 1, Remaining: 0},
+	})
+	if limit := h.Get("X-RateLimit-Limit"); limit != "4" {
+		t.Fatal("limit does not match:", limit)
+	}
+	if remaining := h.Get("X-RateLimit-Remaining"); remaining != "0" {
+		t.Fatal("remaining tokens do not match:", remaining)
+	}
+	retryAfter, err := http.ParseTime(h.Get("Retry-After"))
+	if err != nil {
+		t.Fatal("cannot parse retry after header:", err)
+	}
+	if wait := time.Until(retryAfter); wait < time.Second*5 || wait > time.Second*8 {
+		t.Fatal("retry after is out of range:", wait)
+	}
+}
+
+func TestTruthfulHeaderWriterBurstLimit(t *testing.T) {
+	l, err := mutexrlm.NewRequestLimiter(
+		mutexrlm.WithNewRate(10, time.Minute),
+		mutexrlm.WithBurst(3),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize request limiter:", err)
+	}
+	h, err := New(
+		noContent,
+		WithRequestLimiter("global", l),
+		WithHeaderWriter(NewTruthfulHeaderWriter()),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize request handler:", err)
+	}
+	w := httptest.NewRecorder()
+	if err = h.ServeHyperText(w, httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if limit := w.Header().Get("X-RateLimit-Limit"); limit != "3" {
+		t.Fatal("limit does not match the burst limit:", limit)
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Package metrics collects [oakratelimiter.Event]s into counters and latency histograms. The [Collector] exposes them in Prometheus text format over HTTP and as an [expvar.Var].
+
+	collector := metrics.NewCollector()
+	expvar.Publish("oakratelimiter", collector)
+	http.Handle("/metrics", collector)
+
+	limiter, err := oakratelimiter.New(next, oakratelimiter.WithObserver(collector))
+
+Request tags are not used as labels to keep the number of series bounded.
+*/
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dkotik/oakratelimiter"
+)
+
+var ( // enforce interface compliance
+	_ oakratelimiter.Observer = (*Collector)(nil)
+	_ http.Handler            = (*Collector)(nil)
+	_ expvar.Var              = (*Collector)(nil)
+)
+
+// DefaultBuckets are the upper bounds of latency histogram buckets in seconds. They span in-memory limiters that answer in microseconds and database round-trips that take tens of milliseconds.
+var DefaultBuckets = []float64{
+	.0001, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5,
+}
+
+// Collector counts [oakratelimiter.Event]s by request limiter name and outcome, and records their latency.
+type Collector struct {
+	buckets []float64
+
+	mu       sync.Mutex
+	limiters map[string]*series
+}
+
+type series struct {
+	outcomes map[string]uint64
+	buckets  []uint64 // cumulative counts are computed on exposition
+	sum      float64
+	count    uint64
+}
+
+// NewCollector creates a [Collector] with the given histogram bucket upper bounds in seconds. If none are given, [DefaultBuckets] are used.
+func NewCollector(buckets ...float64) *Collector {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Collector{
+		buckets:  buckets,
+		limiters: make(map[string]*series),
+	}
+}
+
+// Observe satisfies [oakratelimiter.Observer].
+func (c *Collector) Observe(_ context.Context, e oakratelimiter.Event) {
+	outcome := e.Outcome.String()
+	if e.Shadow {
+		outcome = "shadow_" + outcome
+	}
+	seconds := e.Latency.Seconds()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.limiters[e.Name]
+	if !ok {
+		s = &series{
+			outcomes: make(map[string]uint64),
+			buckets:  make([]uint64, len(c.buckets)),
+		}
+		c.limiters[e.Name] = s
+	}
+	s.outcomes[outcome]++
+	s.sum += seconds
+	s.count++
+	if i := sort.SearchFloat64s(c.buckets, seconds); i < len(c.buckets) {
+		s.buckets[i]++
+	}
+}
+
+func (c *Collector) names() []string {
+	names := make([]string, 0, len(c.limiters))
+	for name := range c.limiters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteTo writes the metrics in Prometheus text exposition format.
+func (c *Collector) WriteTo(w io.Writer) (n int64, err error) {
+	b := &strings.Builder{}
+	c.mu.Lock()
+	names := c.names()
+
+	b.WriteString("# HELP oakratelimiter_decisions_total Request limiter decisions by limiter name and outcome.\n")
+	b.WriteString("# TYPE oakratelimiter_decisions_total counter\n")
+	for _, name := range names {
+		s := c.limiters[name]
+		outcomes := make([]string, 0, len(s.outcomes))
+		for outcome := range s.outcomes {
+			outcomes = append(outcomes, outcome)
+		}
+		sort.Strings(outcomes)
+		for _, outcome := range outcomes {
+			fmt.Fprintf(b, "oakratelimiter_decisions_total{limiter=%s,outcome=%s} %d\n",
+				label(name), label(outcome), s.outcomes[outcome])
+		}
+	}
+
+	b.WriteString("# HELP oakratelimiter_decision_latency_seconds Time spent taking tokens from a request limiter.\n")
+	b.WriteString("# TYPE oakratelimiter_decision_latency_seconds histogram\n")
+	for _, name := range names {
+		s := c.limiters[name]
+		cumulative := uint64(0)
+		for i, bound := range c.buckets {
+			cumulative += s.buckets[i]
+			fmt.Fprintf(b, "oakratelimiter_decision_latency_seconds_bucket{limiter=%s,le=%q} %d\n",
+				label(name), strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(b, "oakratelimiter_decision_latency_seconds_bucket{limiter=%s,le=\"+Inf\"} %d\n", label(name), s.count)
+		fmt.Fprintf(b, "oakratelimiter_decision_latency_seconds_sum{limiter=%s} %s\n", label(name), strconv.FormatFloat(s.sum, 'g', -1, 64))
+		fmt.Fprintf(b, "oakratelimiter_decision_latency_seconds_count{limiter=%s} %d\n", label(name), s.count)
+	}
+	c.mu.Unlock()
+
+	written, err := io.WriteString(w, b.String())
+	return int64(written), err
+}
+
+// label quotes and escapes a Prometheus label value.
+func label(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+// ServeHTTP exposes the metrics in Prometheus text format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = c.WriteTo(w)
+}
+
+// Snapshot is the state of a [Collector] for one request limiter.
+type Snapshot struct {
+	Outcomes       map[string]uint64 `json:"outcomes"`
+	Count          uint64            `json:"count"`
+	LatencySeconds float64           `json:"latency_seconds_sum"`
+}
+
+// Snapshot returns the current counters by request limiter name.
+func (c *Collector) Snapshot() map[string]Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	snapshot := make(map[string]Snapshot, len(c.limiters))
+	for name, s := range c.limiters {
+		outcomes := make(map[string]uint64, len(s.outcomes))
+		for outcome, count := range s.outcomes {
+			outcomes[outcome] = count
+		}
+		snapshot[name] = Snapshot{
+			Outcomes:       outcomes,
+			Count:          s.count,
+			LatencySeconds: s.sum,
+		}
+	}
+	return snapshot
+}
+
+// String satisfies [expvar.Var] by encoding [Collector.Snapshot] as JSON.
+func (c *Collector) String() string {
+	b, err := json.Marshal(c.Snapshot())
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dkotik/oakratelimiter"
+	"github.com/dkotik/oakratelimiter/driver/mutexrlm"
+)
+
+func TestCollector(t *testing.T) {
+	collector := NewCollector()
+	global, err := mutexrlm.NewRequestLimiter(mutexrlm.WithNewRate(1, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := oakratelimiter.New(
+		oakratelimiter.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			return nil
+		}),
+		oakratelimiter.WithRequestLimiter("global", global),
+		oakratelimiter.WithObserver(collector),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		_ = h.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	w := httptest.NewRecorder()
+	collector.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, expected := range []string{
+		`oakratelimiter_decisions_total{limiter="global",outcome="allow"} 1`,
+		`oakratelimiter_decisions_total{limiter="global",outcome="deny"} 1`,
+		`oakratelimiter_decision_latency_seconds_bucket{limiter="global",le="+Inf"} 2`,
+		`oakratelimiter_decision_latency_seconds_count{limiter="global"} 2`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("metrics do not contain %q:\n%s", expected, body)
+		}
+	}
+
+	if s := collector.String(); !strings.Contains(s, `"deny":1`) {
+		t.Fatal("unexpected expvar value:", s)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	if escaped := label("a\"b\\c\nd"); escaped != `"a\"b\\c\nd"` {
+		t.Fatal("unexpected escaping:", escaped)
+	}
+}
@@ -6,6 +6,7 @@ package oakratelimiter
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"log/slog"
 )
@@ -30,6 +31,29 @@ func (f HandlerFunc) ServeHyperText(w http.ResponseWriter, r *http.Request) erro
 // TooManyRequestsError indicates overflowing request [Rate].
 type TooManyRequestsError struct {
 	rejectedEndpointAccessControlNames []string
+	reasons                            []string
+	retryAfter                         time.Duration
+}
+
+// RejectedBy returns the names of the request limiters that rejected the request.
+func (e *TooManyRequestsError) RejectedBy() []string {
+	return append([]string(nil), e.rejectedEndpointAccessControlNames...)
+}
+
+// Reasons returns the explanations given by the rejecting request limiters that explain themselves, like a temporary ban, keyed by the request limiter name.
+func (e *TooManyRequestsError) Reasons() map[string]string {
+	reasons := make(map[string]string)
+	for i, reason := range e.reasons {
+		if reason != "" {
+			reasons[e.rejectedEndpointAccessControlNames[i]] = reason
+		}
+	}
+	return reasons
+}
+
+// RetryAfter returns the estimated time until the rejecting request limiters replenish a token. Zero means unknown.
+func (e *TooManyRequestsError) RetryAfter() time.Duration {
+	return e.retryAfter
 }
 
 // Error returns a generic text, regardless of what caused the [TooManyRequestsError].
@@ -44,30 +68,30 @@ func (e *TooManyRequestsError) HyperTextStatusCode() int {
 
 // LogValue captures causes into structured log entries.
 func (e *TooManyRequestsError) LogValue() slog.Value {
-	return slog.GroupValue(
+	attrs := []slog.Attr{
 		slog.String("error", e.Error()),
 		slog.Any("rejected_by", e.rejectedEndpointAccessControlNames),
-	)
+		slog.Duration("retry_after", e.retryAfter),
+	}
+	if reasons := e.Reasons(); len(reasons) > 0 {
+		attrs = append(attrs, slog.Any("reasons", reasons))
+	}
+	return slog.GroupValue(attrs...)
 }
 
 // New initializes a [RequestHandler] using a list of [Option]s.
 func New(next Handler, withOptions ...Option) (*RequestHandler, error) {
-	o, err := newOptions(withOptions)
+	o, err := newOptions(withOptions, nil)
 	if err != nil {
 		return nil, fmt.Errorf("cannot initialize Oak rate limiter: %w", err)
 	}
 
-	return &RequestHandler{
-		next:            next,
-		headerWriter:    o.headerWriter,
-		names:           o.names,
-		requestLimiters: o.requestLimiters,
-	}, nil
+	return o.newRequestHandler(next), nil
 }
 
 // NewMiddleware creates a [Middleware] that wraps [Handler]s into a [RequestHandler].
 func NewMiddleware(withOptions ...Option) (Middleware, error) {
-	o, err := newOptions(withOptions)
+	o, err := newOptions(withOptions, nil)
 	if err != nil {
 		return nil, fmt.Errorf("cannot initialize Oak rate limiting middleware: %w", err)
 	}
@@ -76,12 +100,7 @@ func NewMiddleware(withOptions ...Option) (Middleware, error) {
 		if next == nil {
 			panic(fmt.Errorf("cannot use a %q handler", next))
 		}
-		return &RequestHandler{
-			next:            next,
-			headerWriter:    o.headerWriter,
-			names:           o.names,
-			requestLimiters: o.requestLimiters,
-		}
+		return o.newRequestHandler(next)
 	}, nil
 }
 
@@ -0,0 +1,56 @@
+package oakratelimiter
+
+import (
+	"context"
+	"time"
+)
+
+// Outcome is the decision of a single [request.Limiter] about a request.
+type Outcome uint8
+
+const (
+	// OutcomeAllowed means the request limiter granted the tokens.
+	OutcomeAllowed Outcome = iota + 1
+
+	// OutcomeDenied means the request limiter ran out of tokens.
+	OutcomeDenied
+
+	// OutcomeFailed means the request limiter returned an error.
+	OutcomeFailed
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeAllowed:
+		return "allow"
+	case OutcomeDenied:
+		return "deny"
+	case OutcomeFailed:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes how a named [request.Limiter] treated a request. Tag is empty, if the limiter is not a [request.TaggingLimiter]. Latency measures the Take call alone.
+type Event struct {
+	Name      string
+	Tag       string
+	Outcome   Outcome
+	Shadow    bool
+	Remaining float64
+	Latency   time.Duration
+	Error     error
+}
+
+// Observer receives an [Event] every time [RequestHandler] consults a [request.Limiter]. It is called synchronously on the request path, so it must be fast and safe for concurrent use.
+type Observer interface {
+	Observe(context.Context, Event)
+}
+
+// ObserverFunc adapts a function to the [Observer] interface.
+type ObserverFunc func(context.Context, Event)
+
+func (f ObserverFunc) Observe(ctx context.Context, e Event) {
+	f(ctx, e)
+}
@@ -3,10 +3,16 @@ package oakratelimiter
 import (
 	"errors"
 	"fmt"
+	"reflect"
+	"sort"
+	"time"
 
 	"github.com/dkotik/oakratelimiter/driver/mutexrlm"
 	"github.com/dkotik/oakratelimiter/rate"
 	"github.com/dkotik/oakratelimiter/request"
+	"github.com/dkotik/oakratelimiter/request/breaker"
+	"github.com/dkotik/oakratelimiter/request/inflight"
+	"github.com/dkotik/oakratelimiter/request/penalty"
 	"github.com/dkotik/oakratelimiter/request/tagbycontext"
 	"github.com/dkotik/oakratelimiter/request/tagbycookie"
 	"github.com/dkotik/oakratelimiter/request/tagbyheader"
@@ -15,14 +21,162 @@ import (
 
 type options struct {
 	headerWriter    HeaderWriter
+	errorRenderer   ErrorRenderer
+	observer        Observer
+	strategy        EvaluationStrategy
+	costs           map[string]uint
+	responses       map[string]ResponsePolicy
+	deferred        map[string]struct{}
+	shadows         map[string]struct{}
+	failures        map[string][]breaker.Option
+	penalties       map[string][]penalty.Option
+	maxWait         time.Duration
+	queueLength     int
 	names           []string
 	requestLimiters []request.Limiter
+	wrappers        *wrappers
 }
 
-func newOptions(from []Option) (o *options, err error) {
-	o = &options{}
-	for _, option := range append(
-		from,
+// wrapperKey identifies a named request limiter wrapped by a penalty box or a circuit breaker.
+type wrapperKey struct {
+	name     string
+	limiter  request.Limiter
+	breaking bool
+}
+
+// wrappers keeps penalty boxes and circuit breakers, so that their bans, rejection counts, and circuit state survive [RequestHandler.Reconfigure] and are shared by [Router] routes that inherit the same request limiter. Wrappers that are no longer used are dropped by the next reconfiguration.
+type wrappers struct {
+	previous map[wrapperKey]request.Limiter
+	current  map[wrapperKey]request.Limiter
+}
+
+func newWrappers(previous map[wrapperKey]request.Limiter) *wrappers {
+	return &wrappers{
+		previous: previous,
+		current:  make(map[wrapperKey]request.Limiter),
+	}
+}
+
+// wrap returns the wrapper of the named request limiter that was built before or builds a new one. Request limiters that cannot be used as map keys are wrapped every time.
+func (w *wrappers) wrap(
+	key wrapperKey,
+	build func() (request.Limiter, error),
+) (request.Limiter, error) {
+	if !reflect.TypeOf(key.limiter).Comparable() {
+		return build()
+	}
+	if l, ok := w.current[key]; ok {
+		return l, nil
+	}
+	l, ok := w.previous[key]
+	if !ok {
+		var err error
+		if l, err = build(); err != nil {
+			return nil, err
+		}
+	}
+	w.current[key] = l
+	return l, nil
+}
+
+func newOptions(from []Option, previous map[wrapperKey]request.Limiter) (o *options, err error) {
+	o = &options{wrappers: newWrappers(previous)}
+	if err = o.apply(from); err != nil {
+		return nil, err
+	}
+	if err = o.finalize(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o *options) apply(from []Option) (err error) {
+	for _, option := range from {
+		if err = option(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finalize sets defaults and validates the options.
+func (o *options) finalize() (err error) {
+	if o.wrappers == nil {
+		o.wrappers = newWrappers(nil)
+	}
+	return o.apply([]Option{
+		WithDefaultEvaluationStrategy(),
+		WithDefaultErrorRenderer(),
+		func(o *options) (err error) { // apply penalty boxes
+			for name := range o.penalties {
+				if o.isAvailable(name) == nil {
+					return fmt.Errorf("cannot set penalty box of unknown rate limiter %q", name)
+				}
+			}
+			for i, name := range o.names {
+				withOptions, ok := o.penalties[name]
+				if !ok {
+					continue
+				}
+				l := o.requestLimiters[i]
+				if o.requestLimiters[i], err = o.wrappers.wrap(wrapperKey{name: name, limiter: l}, func() (request.Limiter, error) {
+					return penalty.New(l, withOptions...)
+				}); err != nil {
+					return fmt.Errorf("cannot set penalty box of rate limiter %q: %w", name, err)
+				}
+			}
+			return nil
+		},
+		func(o *options) (err error) { // apply failure policies
+			for name := range o.failures {
+				if o.isAvailable(name) == nil {
+					return fmt.Errorf("cannot set failure policy of unknown rate limiter %q", name)
+				}
+			}
+			for i, name := range o.names {
+				withOptions, ok := o.failures[name]
+				if !ok {
+					continue
+				}
+				l := o.requestLimiters[i]
+				if o.requestLimiters[i], err = o.wrappers.wrap(wrapperKey{name: name, limiter: l, breaking: true}, func() (request.Limiter, error) {
+					return breaker.New(l, withOptions...)
+				}); err != nil {
+					return fmt.Errorf("cannot set failure policy of rate limiter %q: %w", name, err)
+				}
+			}
+			return nil
+		},
+		func(o *options) error { // order by evaluation cost
+			for name := range o.costs {
+				if o.isAvailable(name) == nil {
+					return fmt.Errorf("cannot set evaluation cost of unknown rate limiter %q", name)
+				}
+			}
+			for i, name := range o.names {
+				if _, ok := o.responses[name]; !ok {
+					continue
+				}
+				if _, ok := o.requestLimiters[i].(request.Releaser); ok {
+					return fmt.Errorf("cannot set response policy of rate limiter %q that releases its tokens", name)
+				}
+			}
+			for name := range o.responses {
+				if o.isAvailable(name) == nil {
+					return fmt.Errorf("cannot set response policy of unknown rate limiter %q", name)
+				}
+			}
+			for name := range o.shadows {
+				if o.isAvailable(name) == nil {
+					return fmt.Errorf("cannot shadow unknown rate limiter %q", name)
+				}
+			}
+			if len(o.costs) == 0 {
+				return nil
+			}
+			sort.Stable(byEvaluationCost{o})
+			return nil
+		},
 		func(o *options) error { // default header writer
 			if o.headerWriter != nil {
 				return nil // already set
@@ -31,30 +185,103 @@ func newOptions(from []Option) (o *options, err error) {
 				return errors.New("at least one request limiter is required")
 			}
 			var least *rate.Rate
-			for _, l := range o.requestLimiters {
-				if least == nil {
-					least = l.Rate()
+			for i, l := range o.requestLimiters {
+				if _, ok := o.shadows[o.names[i]]; ok {
+					continue // shadow limiters are not reported
+				}
+				current := l.Rate()
+				if current == nil {
+					continue // concurrency limits have no rate
 				}
-				if current := l.Rate(); current.FasterThan(least) {
+				if least == nil || current.FasterThan(least) {
 					least = current
 				}
 			}
+			if least == nil {
+				return WithHeaderWriter(&SilentHeaderWriter{})(o)
+			}
 			return WithHeaderWriter(NewObfuscatingHeaderWriter(least))(o)
 		},
-	) {
-		if err = option(o); err != nil {
-			return nil, err
+	})
+}
+
+// merge copies request limiters and settings of a route, overriding the ones merged before. Routes must be merged in order of generality, so that a more specific route replaces request limiters of the same name and overrides settings. Request limiters are shared with the route they were copied from.
+func (o *options) merge(route *options) {
+	for i, name := range route.names {
+		if j := o.index(name); j >= 0 {
+			o.requestLimiters[j] = route.requestLimiters[i]
+			continue
 		}
+		o.names = append(o.names, name)
+		o.requestLimiters = append(o.requestLimiters, route.requestLimiters[i])
+	}
+	if route.headerWriter != nil {
+		o.headerWriter = route.headerWriter
+	}
+	if route.observer != nil {
+		o.observer = route.observer
+	}
+	if route.errorRenderer != nil {
+		o.errorRenderer = route.errorRenderer
+	}
+	if route.strategy != 0 {
+		o.strategy = route.strategy
+	}
+	if route.queueLength != 0 {
+		o.maxWait = route.maxWait
+		o.queueLength = route.queueLength
+	}
+	for name, cost := range route.costs {
+		if o.costs == nil {
+			o.costs = make(map[string]uint)
+		}
+		o.costs[name] = cost
+	}
+	for name, withOptions := range route.failures {
+		if o.failures == nil {
+			o.failures = make(map[string][]breaker.Option)
+		}
+		o.failures[name] = withOptions
+	}
+	for name, withOptions := range route.penalties {
+		if o.penalties == nil {
+			o.penalties = make(map[string][]penalty.Option)
+		}
+		o.penalties[name] = withOptions
+	}
+	for name := range route.shadows {
+		if o.shadows == nil {
+			o.shadows = make(map[string]struct{})
+		}
+		o.shadows[name] = struct{}{}
+	}
+	for name, policy := range route.responses {
+		if o.responses == nil {
+			o.responses = make(map[string]ResponsePolicy)
+		}
+		o.responses[name] = policy
+	}
+	for name := range route.deferred {
+		if o.deferred == nil {
+			o.deferred = make(map[string]struct{})
+		}
+		o.deferred[name] = struct{}{}
 	}
-	return o, nil
 }
 
-func (o *options) isAvailable(name string) error {
-	for _, existing := range o.names {
+func (o *options) index(name string) int {
+	for i, existing := range o.names {
 		if existing == name {
-			return fmt.Errorf("rate limiter %q is already set", name)
+			return i
 		}
 	}
+	return -1
+}
+
+func (o *options) isAvailable(name string) error {
+	if o.index(name) >= 0 {
+		return fmt.Errorf("rate limiter %q is already set", name)
+	}
 	return nil
 }
 
@@ -76,6 +303,72 @@ func (o *options) append(name string, l request.Limiter) error {
 	return nil
 }
 
+// newRequestHandler wraps the next [Handler] using configured options.
+func (o *options) newRequestHandler(next Handler) *RequestHandler {
+	rh := &RequestHandler{next: next, wrapped: o.wrappers.current}
+	rh.current.Store(o.newLimiterSet())
+	return rh
+}
+
+func (o *options) newLimiterSet() *limiterSet {
+	var responsePolicies []ResponsePolicy
+	if len(o.responses) > 0 {
+		responsePolicies = make([]ResponsePolicy, len(o.names))
+		for i, name := range o.names {
+			responsePolicies[i] = o.responses[name]
+		}
+	}
+	var deferred []bool
+	if len(o.deferred) > 0 {
+		deferred = make([]bool, len(o.names))
+		for i, name := range o.names {
+			_, deferred[i] = o.deferred[name]
+		}
+	}
+	var shadows []bool
+	if len(o.shadows) > 0 {
+		shadows = make([]bool, len(o.names))
+		for i, name := range o.names {
+			_, shadows[i] = o.shadows[name]
+		}
+	}
+	var queue chan struct{}
+	if o.queueLength > 0 {
+		queue = make(chan struct{}, o.queueLength)
+	}
+	return &limiterSet{
+		headerWriter:     o.headerWriter,
+		errorRenderer:    o.errorRenderer,
+		observer:         o.observer,
+		shortCircuit:     o.strategy == ShortCircuitEvaluation,
+		shadows:          shadows,
+		maxWait:          o.maxWait,
+		queue:            queue,
+		names:            o.names,
+		requestLimiters:  o.requestLimiters,
+		responsePolicies: responsePolicies,
+		deferred:         deferred,
+	}
+}
+
+// byEvaluationCost sorts request limiters from cheapest to most expensive. Limiters without a declared cost are considered free.
+type byEvaluationCost struct {
+	*options
+}
+
+func (b byEvaluationCost) Len() int {
+	return len(b.names)
+}
+
+func (b byEvaluationCost) Less(i, j int) bool {
+	return b.costs[b.names[i]] < b.costs[b.names[j]]
+}
+
+func (b byEvaluationCost) Swap(i, j int) {
+	b.names[i], b.names[j] = b.names[j], b.names[i]
+	b.requestLimiters[i], b.requestLimiters[j] = b.requestLimiters[j], b.requestLimiters[i]
+}
+
 // Option initializes an [OakRateLimiter] or [Middleware].
 type Option func(*options) error
 
@@ -93,6 +386,188 @@ func WithHeaderWriter(h HeaderWriter) Option {
 	}
 }
 
+// WithErrorRenderer sets an [ErrorRenderer] that [RequestHandler.ServeHTTP] uses to respond with errors. Use [NewNegotiatingErrorRenderer] to serve different media types, like an HTML page for browsers and [ProblemJSONErrorRenderer] for API clients.
+func WithErrorRenderer(r ErrorRenderer) Option {
+	return func(o *options) error {
+		if r == nil {
+			return errors.New("cannot use a <nil> error renderer")
+		}
+		if o.errorRenderer != nil {
+			return errors.New("error renderer is already set")
+		}
+		o.errorRenderer = r
+		return nil
+	}
+}
+
+// WithDefaultErrorRenderer sets the error renderer created by [NewDefaultErrorRenderer], if none was provided by another option.
+func WithDefaultErrorRenderer() Option {
+	return func(o *options) error {
+		if o.errorRenderer != nil {
+			return nil // already set
+		}
+		return WithErrorRenderer(NewDefaultErrorRenderer())(o)
+	}
+}
+
+// WithObserver sets an [Observer] that receives an [Event] for every decision of every request limiter. See the metrics package for a collector that exposes the events in Prometheus text format and through expvar.
+func WithObserver(observer Observer) Option {
+	return func(o *options) error {
+		if observer == nil {
+			return errors.New("cannot use a <nil> observer")
+		}
+		if o.observer != nil {
+			return errors.New("observer is already set")
+		}
+		o.observer = observer
+		return nil
+	}
+}
+
+// WithEvaluationStrategy determines how [RequestHandler] consults its request limiters.
+func WithEvaluationStrategy(s EvaluationStrategy) Option {
+	return func(o *options) error {
+		if s != FullEvaluation && s != ShortCircuitEvaluation {
+			return fmt.Errorf("unknown evaluation strategy %d", s)
+		}
+		if o.strategy != 0 {
+			return errors.New("evaluation strategy is already set")
+		}
+		o.strategy = s
+		return nil
+	}
+}
+
+// WithDefaultEvaluationStrategy sets [FullEvaluation] strategy, if none was provided by another option.
+func WithDefaultEvaluationStrategy() Option {
+	return func(o *options) error {
+		if o.strategy != 0 {
+			return nil // already set
+		}
+		return WithEvaluationStrategy(FullEvaluation)(o)
+	}
+}
+
+// WithEvaluationCost declares the relative cost of consulting a named request limiter. Request limiters are consulted from the cheapest to the most expensive, so that in-memory limiters run before database round-trips. Limiters without a declared cost are considered free. Limiters of equal cost keep the order in which they were added.
+func WithEvaluationCost(name string, cost uint) Option {
+	return func(o *options) error {
+		if name == "" {
+			return errors.New("cannot use an empty rate limiter name")
+		}
+		if o.costs == nil {
+			o.costs = make(map[string]uint)
+		}
+		if _, ok := o.costs[name]; ok {
+			return fmt.Errorf("evaluation cost of rate limiter %q is already set", name)
+		}
+		o.costs[name] = cost
+		return nil
+	}
+}
+
+// WithResponsePolicy applies a [ResponsePolicy] to a named request limiter. After the next [Handler] responds, the tokens taken by the request are returned, if the policy says so.
+func WithResponsePolicy(name string, p ResponsePolicy) Option {
+	return func(o *options) error {
+		if name == "" {
+			return errors.New("cannot use an empty rate limiter name")
+		}
+		if p == nil {
+			return errors.New("cannot use a <nil> response policy")
+		}
+		if o.responses == nil {
+			o.responses = make(map[string]ResponsePolicy)
+		}
+		if _, ok := o.responses[name]; ok {
+			return fmt.Errorf("response policy of rate limiter %q is already set", name)
+		}
+		o.responses[name] = p
+		return nil
+	}
+}
+
+// WithResponseCharge applies a [ResponsePolicy] to a named request limiter, which is only checked before the next [Handler] runs. Its tokens are taken after the response, unless the policy says they should be returned. Unlike [WithResponsePolicy], concurrent requests do not hold tokens while they are being served, so a burst of requests may pass the check before any of them is charged.
+func WithResponseCharge(name string, p ResponsePolicy) Option {
+	return func(o *options) error {
+		if err := WithResponsePolicy(name, p)(o); err != nil {
+			return err
+		}
+		if o.deferred == nil {
+			o.deferred = make(map[string]struct{})
+		}
+		o.deferred[name] = struct{}{}
+		return nil
+	}
+}
+
+// WithFailurePolicy protects a named request limiter with a circuit breaker. By default, driver errors still fail the request, but after five consecutive failures the driver is not called for ten seconds. Use [breaker.WithPolicy] with [breaker.FailOpen] to let requests through while the driver is unavailable, or [breaker.WithFallbackRate] to fall back to a degraded in-memory rate. The circuit breaker is built once for each request limiter instance, so [Router] routes that inherit the request limiter share its circuit, and [RequestHandler.Reconfigure] keeps it. Pass [breaker.WithCleanupContext] to stop the background clean up of the fallback.
+func WithFailurePolicy(name string, withOptions ...breaker.Option) Option {
+	return func(o *options) error {
+		if name == "" {
+			return errors.New("cannot use an empty rate limiter name")
+		}
+		if o.failures == nil {
+			o.failures = make(map[string][]breaker.Option)
+		}
+		if _, ok := o.failures[name]; ok {
+			return fmt.Errorf("failure policy of rate limiter %q is already set", name)
+		}
+		o.failures[name] = withOptions
+		return nil
+	}
+}
+
+// WithPenaltyBox bans the tags of a named request limiter after repeated rejections, so that clients who keep hammering it do not get a trickle of requests through as tokens refill. By default, a tag rejected more than ten times within a minute is banned for one minute, then ten minutes, then an hour. Banned requests are rejected without calling the request limiter, and the ban is reported by [TooManyRequestsError.Reasons]. Use [penalty.WithBanList] with [penalty.NewSQLBanList] to share bans between service instances. The penalty box is built once for each request limiter instance, so [Router] routes that inherit the request limiter share its bans, and [RequestHandler.Reconfigure] keeps them. Pass [penalty.WithCleanupContext] to stop the background clean up of its rejection counter. The request limiter must be a [request.TaggingLimiter].
+func WithPenaltyBox(name string, withOptions ...penalty.Option) Option {
+	return func(o *options) error {
+		if name == "" {
+			return errors.New("cannot use an empty rate limiter name")
+		}
+		if o.penalties == nil {
+			o.penalties = make(map[string][]penalty.Option)
+		}
+		if _, ok := o.penalties[name]; ok {
+			return fmt.Errorf("penalty box of rate limiter %q is already set", name)
+		}
+		o.penalties[name] = withOptions
+		return nil
+	}
+}
+
+// WithShadowMode runs a named request limiter as a dry run. It takes tokens as usual, but requests it would reject are let through and logged together with the limiter name and the request tag. Its failures are logged too. Shadow limiters never appear in [TooManyRequestsError] and are not reported by the [HeaderWriter]. Use it to compare a new policy against the enforcing ones before tightening limits in production.
+func WithShadowMode(name string) Option {
+	return func(o *options) error {
+		if name == "" {
+			return errors.New("cannot use an empty rate limiter name")
+		}
+		if o.shadows == nil {
+			o.shadows = make(map[string]struct{})
+		}
+		if _, ok := o.shadows[name]; ok {
+			return fmt.Errorf("rate limiter %q is already in shadow mode", name)
+		}
+		o.shadows[name] = struct{}{}
+		return nil
+	}
+}
+
+// WithWaitQueue holds rejected requests until the tokens become available instead of responding with [TooManyRequestsError] right away. A request waits no longer than the maximum duration or its [context.Context] deadline, whichever comes first. No more than the given number of requests wait at the same time, the rest are rejected immediately. This smooths out bursts of legitimate traffic without changing the long-term rate. Requests are rejected without waiting, if any rejecting [request.Limiter] is not a [request.Delayer].
+func WithWaitQueue(maxWait time.Duration, length int) Option {
+	return func(o *options) error {
+		if maxWait <= 0 {
+			return errors.New("maximum wait must be greater than zero")
+		}
+		if length < 1 {
+			return errors.New("wait queue length must be greater than zero")
+		}
+		if o.queueLength != 0 {
+			return errors.New("wait queue is already set")
+		}
+		o.maxWait = maxWait
+		o.queueLength = length
+		return nil
+	}
+}
+
 // WithGlobalRequestLimiter applies [mutexrlm.RequestLimiter] as the top request limiter named "global".
 func WithGlobalRequestLimiter(l request.Limiter) Option {
 	return func(o *options) (err error) {
@@ -135,6 +610,17 @@ func WithRequestLimiter(name string, rl request.Limiter) Option {
 	}
 }
 
+// WithConcurrencyLimiter adds an [inflight.ConcurrencyLimiter] that admits a request only if fewer than a certain number of requests are being served at the same time. The slot is released when the next [Handler] returns, even if it panics. Use [inflight.WithTagger] to count requests separately for each tag.
+func WithConcurrencyLimiter(name string, withOptions ...inflight.Option) Option {
+	return func(o *options) (err error) {
+		requestLimiter, err := inflight.New(withOptions...)
+		if err != nil {
+			return err
+		}
+		return WithRequestLimiter(name, requestLimiter)(o)
+	}
+}
+
 // WithIPAddressTagger configures rate limiter to track requests based on client IP addresses.
 func WithIPAddressTagger(
 	withOptions ...tagbyip.Option,
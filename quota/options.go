@@ -0,0 +1,144 @@
+package quota
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/request"
+)
+
+type options struct {
+	Limit    float64
+	Period   Period
+	Location *time.Location
+	Store    Store
+	Tagger   request.Tagger
+	Coster   request.Coster
+}
+
+// Option configures the quota.
+type Option func(*options) error
+
+// WithLimit sets the number of tokens each tag may consume per window. Unlike rates, the limit is not capped at 2^32 tokens.
+func WithLimit(tokens float64) Option {
+	return func(o *options) error {
+		if tokens <= 0 {
+			return errors.New("limit must be greater than zero")
+		}
+		if o.Limit != 0 {
+			return errors.New("limit is already set")
+		}
+		o.Limit = tokens
+		return nil
+	}
+}
+
+// WithPeriod sets the length of the quota window.
+func WithPeriod(p Period) Option {
+	return func(o *options) error {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+		if o.Period != 0 {
+			return errors.New("period is already set")
+		}
+		o.Period = p
+		return nil
+	}
+}
+
+// WithDefaultPeriod resets quotas monthly.
+func WithDefaultPeriod() Option {
+	return func(o *options) error {
+		if o.Period != 0 {
+			return nil // already set
+		}
+		return WithPeriod(Monthly)(o)
+	}
+}
+
+// WithLocation sets the time zone of window boundaries, so that a daily quota resets at midnight of the customer.
+func WithLocation(l *time.Location) Option {
+	return func(o *options) error {
+		if l == nil {
+			return errors.New("cannot use a <nil> location")
+		}
+		if o.Location != nil {
+			return errors.New("location is already set")
+		}
+		o.Location = l
+		return nil
+	}
+}
+
+// WithDefaultLocation aligns windows to UTC.
+func WithDefaultLocation() Option {
+	return func(o *options) error {
+		if o.Location != nil {
+			return nil // already set
+		}
+		return WithLocation(time.UTC)(o)
+	}
+}
+
+// WithStore sets the [Store] that keeps the usage of each tag. Use a database store to share quotas between service instances and to keep them across restarts.
+func WithStore(s Store) Option {
+	return func(o *options) error {
+		if s == nil {
+			return errors.New("cannot use a <nil> store")
+		}
+		if o.Store != nil {
+			return errors.New("store is already set")
+		}
+		o.Store = s
+		return nil
+	}
+}
+
+// WithDefaultStore keeps usage in a [MemoryStore].
+func WithDefaultStore() Option {
+	return func(o *options) error {
+		if o.Store != nil {
+			return nil // already set
+		}
+		return WithStore(NewMemoryStore())(o)
+	}
+}
+
+// WithTagger sets the [request.Tagger] that determines which quota a request consumes, like an API key.
+func WithTagger(t request.Tagger) Option {
+	return func(o *options) error {
+		if t == nil {
+			return errors.New("cannot use a <nil> tagger")
+		}
+		if o.Tagger != nil {
+			return errors.New("tagger is already set")
+		}
+		o.Tagger = t
+		return nil
+	}
+}
+
+// WithCoster determines how many tokens each request consumes.
+func WithCoster(c request.Coster) Option {
+	return func(o *options) error {
+		if c == nil {
+			return errors.New("cannot use a <nil> coster")
+		}
+		if o.Coster != nil {
+			return errors.New("coster is already set")
+		}
+		o.Coster = c
+		return nil
+	}
+}
+
+// WithDefaultCoster consumes one token per request.
+func WithDefaultCoster() Option {
+	return func(o *options) error {
+		if o.Coster != nil {
+			return nil // already set
+		}
+		return WithCoster(request.UnitCost)(o)
+	}
+}
@@ -0,0 +1,79 @@
+package quota
+
+import (
+	"fmt"
+	"time"
+)
+
+// Period is the length of a calendar-aligned quota window. Windows begin at midnight in the location of the [Limiter], so a daily quota resets at local midnight, not every 24 hours.
+type Period uint8
+
+const (
+	Daily Period = iota + 1
+	Weekly
+	Monthly
+	Yearly
+)
+
+// ParsePeriod reads a [Period] written as "daily", "weekly", "monthly", or "yearly".
+func ParsePeriod(s string) (Period, error) {
+	for p := Daily; p <= Yearly; p++ {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown quota period %q", s)
+}
+
+func (p Period) String() string {
+	switch p {
+	case Daily:
+		return "daily"
+	case Weekly:
+		return "weekly"
+	case Monthly:
+		return "monthly"
+	case Yearly:
+		return "yearly"
+	default:
+		return fmt.Sprintf("Period(%d)", p)
+	}
+}
+
+// Validate returns an error for unknown periods.
+func (p Period) Validate() error {
+	if p < Daily || p > Yearly {
+		return fmt.Errorf("unknown quota period %d", p)
+	}
+	return nil
+}
+
+// Start returns the beginning of the window that contains the given time, in its location. Weeks begin on Monday.
+func (p Period) Start(t time.Time) time.Time {
+	year, month, day := t.Date()
+	switch p {
+	case Weekly:
+		return time.Date(year, month, day-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location())
+	case Monthly:
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	case Yearly:
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// End returns the beginning of the window that follows the one that contains the given time.
+func (p Period) End(t time.Time) time.Time {
+	start := p.Start(t)
+	switch p {
+	case Weekly:
+		return start.AddDate(0, 0, 7)
+	case Monthly:
+		return start.AddDate(0, 1, 0)
+	case Yearly:
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
@@ -0,0 +1,191 @@
+/*
+Package quota implements [request.Limiter] for long-horizon quotas, like "100,000 requests per month", which do not fit a [rate.Rate]. Windows are aligned to the calendar in a given location and reset on their boundaries instead of refilling gradually.
+
+A quota works alongside short-term rate limits, each registered as a separate request limiter:
+
+	monthly, err := quota.New(
+		quota.WithTagger(apiKeyTagger),
+		quota.WithLimit(100_000),
+		quota.WithPeriod(quota.Monthly),
+		quota.WithStore(postgresQuotaStore),
+	)
+	handler, err := oakratelimiter.NewRequestHandler(
+		next,
+		oakratelimiter.WithRequestLimiter("burst", burstLimiter),
+		oakratelimiter.WithRequestLimiter("monthly", monthly),
+	)
+*/
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/rate"
+	"github.com/dkotik/oakratelimiter/request"
+)
+
+var ( // enforce interface compliance
+	_ request.Limiter        = (*Limiter)(nil)
+	_ request.Delayer        = (*Limiter)(nil)
+	_ request.TaggingLimiter = (*Limiter)(nil)
+	_ request.Explainer      = (*Limiter)(nil)
+	_ request.CostingLimiter = (*Limiter)(nil)
+)
+
+// Limiter allows each tag a limited number of tokens per calendar window.
+type Limiter struct {
+	limit    float64
+	period   Period
+	location *time.Location
+	store    Store
+	tagger   request.Tagger
+	coster   request.Coster
+}
+
+// New creates a [Limiter]. A limit is required. Without other options, the window is a month in UTC, usage is kept in memory, and all requests share the same quota.
+func New(withOptions ...Option) (_ *Limiter, err error) {
+	o := &options{}
+	for _, option := range append(
+		withOptions,
+		WithDefaultPeriod(),
+		WithDefaultLocation(),
+		WithDefaultStore(),
+		WithDefaultCoster(),
+		func(o *options) error { // validate
+			if o.Limit == 0 {
+				return errors.New("limit is required")
+			}
+			return nil
+		},
+	) {
+		if err = option(o); err != nil {
+			return nil, fmt.Errorf("cannot initialize quota: %w", err)
+		}
+	}
+	return &Limiter{
+		limit:    o.Limit,
+		period:   o.Period,
+		location: o.Location,
+		store:    o.Store,
+		tagger:   o.Tagger,
+		coster:   o.Coster,
+	}, nil
+}
+
+// Limit returns the number of tokens each tag may consume per window.
+func (l *Limiter) Limit() float64 {
+	return l.limit
+}
+
+// Period returns the length of the quota window.
+func (l *Limiter) Period() Period {
+	return l.period
+}
+
+// Rate returns <nil>, because quotas reset on calendar boundaries instead of refilling.
+func (l *Limiter) Rate() *rate.Rate {
+	return nil
+}
+
+func (l *Limiter) Cost(r *http.Request) (float64, error) {
+	return l.coster(r)
+}
+
+// Tag returns the tag a request is counted under. Without a [request.Tagger], all requests share an empty tag.
+func (l *Limiter) Tag(r *http.Request) (string, error) {
+	if l.tagger == nil {
+		return "", nil
+	}
+	return l.tagger(r)
+}
+
+// Window returns the beginning and the end of the window that contains the given time.
+func (l *Limiter) Window(at time.Time) (start, end time.Time) {
+	at = at.In(l.location)
+	return l.period.Start(at), l.period.End(at)
+}
+
+func (l *Limiter) Take(r *http.Request) (
+	remaining float64,
+	ok bool,
+	err error,
+) {
+	tag, err := l.Tag(r)
+	if err != nil {
+		return 0, false, err
+	}
+	tokens, err := l.coster(r)
+	if err != nil {
+		return 0, false, err
+	}
+	start, _ := l.Window(time.Now())
+	used, ok, err := l.store.Consume(r.Context(), tag, start, tokens, l.limit)
+	if err != nil {
+		return 0, false, fmt.Errorf("cannot consume quota: %w", err)
+	}
+	return max(l.limit-used, 0), ok, nil
+}
+
+func (l *Limiter) Put(r *http.Request) error {
+	tag, err := l.Tag(r)
+	if err != nil {
+		return err
+	}
+	tokens, err := l.coster(r)
+	if err != nil {
+		return err
+	}
+	start, _ := l.Window(time.Now())
+	return l.store.Release(r.Context(), tag, start, tokens)
+}
+
+// Delay returns the time until the window resets, if the quota of the request tag is exhausted.
+func (l *Limiter) Delay(r *http.Request) (time.Duration, error) {
+	tag, err := l.Tag(r)
+	if err != nil {
+		return 0, err
+	}
+	tokens, err := l.coster(r)
+	if err != nil {
+		return 0, err
+	}
+	t := time.Now()
+	start, end := l.Window(t)
+	used, err := l.store.Used(r.Context(), tag, start)
+	if err != nil {
+		return 0, err
+	}
+	if used+tokens <= l.limit {
+		return 0, nil
+	}
+	return end.Sub(t), nil
+}
+
+// Explain reports an exhausted quota, like "monthly quota of 100000 is exhausted", and the time until the window resets.
+func (l *Limiter) Explain(r *http.Request) (reason string, retryAfter time.Duration) {
+	retryAfter, err := l.Delay(r)
+	if err != nil || retryAfter == 0 {
+		return "", 0
+	}
+	return fmt.Sprintf("%s quota of %g is exhausted", l.period, l.limit), retryAfter
+}
+
+// Usage returns the tokens consumed by a tag in the current window.
+func (l *Limiter) Usage(ctx context.Context, tag string) (Usage, error) {
+	start, _ := l.Window(time.Now())
+	used, err := l.store.Used(ctx, tag, start)
+	if err != nil {
+		return Usage{}, err
+	}
+	return Usage{Tag: tag, Start: start, Used: used}, nil
+}
+
+// List returns the usage of every tag in the window that contains the given time. Pass an earlier time to see previous windows, as far as the [Store] keeps them.
+func (l *Limiter) List(ctx context.Context, at time.Time) ([]Usage, error) {
+	start, _ := l.Window(at)
+	return l.store.List(ctx, start)
+}
@@ -0,0 +1,123 @@
+package quota
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPeriod(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database is not available:", err)
+	}
+	at := time.Date(2024, time.March, 31, 15, 30, 0, 0, berlin) // Sunday of the daylight saving switch
+	cases := []struct {
+		Period Period
+		Start  time.Time
+		End    time.Time
+	}{
+		{Daily, time.Date(2024, time.March, 31, 0, 0, 0, 0, berlin), time.Date(2024, time.April, 1, 0, 0, 0, 0, berlin)},
+		{Weekly, time.Date(2024, time.March, 25, 0, 0, 0, 0, berlin), time.Date(2024, time.April, 1, 0, 0, 0, 0, berlin)},
+		{Monthly, time.Date(2024, time.March, 1, 0, 0, 0, 0, berlin), time.Date(2024, time.April, 1, 0, 0, 0, 0, berlin)},
+		{Yearly, time.Date(2024, time.January, 1, 0, 0, 0, 0, berlin), time.Date(2025, time.January, 1, 0, 0, 0, 0, berlin)},
+	}
+	for _, c := range cases {
+		if start := c.Period.Start(at); !start.Equal(c.Start) {
+			t.Errorf("%s window starts at %s instead of %s", c.Period, start, c.Start)
+		}
+		if end := c.Period.End(at); !end.Equal(c.End) {
+			t.Errorf("%s window ends at %s instead of %s", c.Period, end, c.End)
+		}
+		parsed, err := ParsePeriod(c.Period.String())
+		if err != nil || parsed != c.Period {
+			t.Errorf("cannot parse period %q: %v", c.Period, err)
+		}
+	}
+	if day := Daily.End(at).Sub(Daily.Start(at)); day != 23*time.Hour {
+		t.Errorf("day of the daylight saving switch lasts %s instead of 23 hours", day)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	ctx := context.Background()
+	l, err := New(
+		WithLimit(5_000_000_000), // beyond the token limit of rates
+		WithPeriod(Daily),
+		WithTagger(func(r *http.Request) (string, error) {
+			return r.Header.Get("X-API-Key"), nil
+		}),
+		WithCoster(func(r *http.Request) (float64, error) {
+			return 2_000_000_000, nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("X-API-Key", "acme")
+
+	for i := 0; i < 2; i++ {
+		if _, ok, err := l.Take(request); err != nil || !ok {
+			t.Fatal("request was limited:", err)
+		}
+	}
+	remaining, ok, err := l.Take(request)
+	if err != nil || ok {
+		t.Fatal("exhausted quota was not limited:", err)
+	}
+	if remaining != 1_000_000_000 {
+		t.Fatalf("quota has %f tokens remaining", remaining)
+	}
+	reason, retryAfter := l.Explain(request)
+	if reason != "daily quota of 5e+09 is exhausted" || retryAfter <= 0 || retryAfter > 24*time.Hour {
+		t.Fatal("unexpected explanation:", reason, retryAfter)
+	}
+
+	if err = l.Put(request); err != nil {
+		t.Fatal(err)
+	}
+	usage, err := l.Usage(ctx, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Used != 2_000_000_000 {
+		t.Fatalf("returned tokens are still used: %f", usage.Used)
+	}
+	list, err := l.List(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Tag != "acme" || !list[0].Start.Equal(usage.Start) {
+		t.Fatalf("unexpected usage list: %+v", list)
+	}
+	if list, err = l.List(ctx, time.Now().AddDate(0, 0, -1)); err != nil || len(list) != 0 {
+		t.Fatalf("previous window is not empty: %+v %v", list, err)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	windows := []time.Time{
+		time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for _, window := range windows {
+		if _, _, err := s.Consume(ctx, "acme", window, 1, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, expected := range []float64{0, 1, 1} {
+		used, err := s.Used(ctx, "acme", windows[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if used != expected {
+			t.Errorf("window %s has usage %f instead of %f", windows[i], used, expected)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package quota
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Usage reports the tokens consumed by a tag within a quota window.
+type Usage struct {
+	Tag   string
+	Start time.Time
+	Used  float64
+}
+
+// Store keeps the tokens consumed by each tag in each quota window. Windows are identified by the time they start.
+type Store interface {
+	// Consume adds tokens to the usage of a tag, unless the total would exceed the limit. Returns the usage after the attempt.
+	Consume(ctx context.Context, tag string, window time.Time, tokens, limit float64) (used float64, ok bool, err error)
+	// Release subtracts tokens from the usage of a tag, but not below zero.
+	Release(ctx context.Context, tag string, window time.Time, tokens float64) error
+	// Used returns the usage of a tag. Tags without usage return zero.
+	Used(ctx context.Context, tag string, window time.Time) (float64, error)
+	// List returns the usage of every tag in a window, sorted by tag.
+	List(ctx context.Context, window time.Time) ([]Usage, error)
+}
+
+type memoryKey struct {
+	tag    string
+	window int64
+}
+
+// MemoryStore is a [Store] for a single service instance. It keeps the current and the previous window, so that dashboards can compare them. Usage is lost when the process exits.
+type MemoryStore struct {
+	mu     sync.Mutex
+	latest int64
+	used   map[memoryKey]float64
+}
+
+// NewMemoryStore creates an empty [MemoryStore].
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{used: make(map[memoryKey]float64)}
+}
+
+func (m *MemoryStore) Consume(ctx context.Context, tag string, window time.Time, tokens, limit float64) (used float64, ok bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := memoryKey{tag: tag, window: window.Unix()}
+	if key.window > m.latest {
+		m.sweep(key.window)
+	}
+	used = m.used[key]
+	if used+tokens > limit {
+		return used, false, nil
+	}
+	used += tokens
+	m.used[key] = used
+	return used, true, nil
+}
+
+// sweep removes windows older than the one before the latest.
+func (m *MemoryStore) sweep(latest int64) {
+	previous := m.latest
+	m.latest = latest
+	for key := range m.used {
+		if key.window < previous {
+			delete(m.used, key)
+		}
+	}
+}
+
+func (m *MemoryStore) Release(ctx context.Context, tag string, window time.Time, tokens float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := memoryKey{tag: tag, window: window.Unix()}
+	used, ok := m.used[key]
+	if !ok {
+		return nil // nothing consumed
+	}
+	if used -= tokens; used > 0 {
+		m.used[key] = used
+	} else {
+		delete(m.used, key)
+	}
+	return nil
+}
+
+func (m *MemoryStore) Used(ctx context.Context, tag string, window time.Time) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.used[memoryKey{tag: tag, window: window.Unix()}], nil
+}
+
+func (m *MemoryStore) List(ctx context.Context, window time.Time) ([]Usage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	start := window.Unix()
+	usage := make([]Usage, 0)
+	for key, used := range m.used {
+		if key.window == start {
+			usage = append(usage, Usage{Tag: key.tag, Start: window, Used: used})
+		}
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Tag < usage[j].Tag
+	})
+	return usage, nil
+}
@@ -45,6 +45,29 @@ func (l *LeakyBucket) Take(tokens float64) (remaining float64, ok bool) {
 	return l.tokens, true
 }
 
+// Delay returns the time it takes for the bucket to hold a given amount of tokens at a [Rate]. Use only after running [LeakyBucket.Refill].
+func (l *LeakyBucket) Delay(r *Rate, tokens float64) time.Duration {
+	return r.ReplenishmentDuration(tokens - l.tokens)
+}
+
+// Put returns tokens to the bucket. Restored tokens will not exceed the burst limit. Use only after running [LeakyBucket.Refill].
+func (l *LeakyBucket) Put(tokens float64, burstLimit float64) (remaining float64) {
+	l.tokens += tokens
+	if l.tokens > burstLimit {
+		l.tokens = burstLimit
+	}
+	return l.tokens
+}
+
+// Rescale adjusts the tokens in the bucket to a new burst limit, keeping the same fill ratio. Use after running [LeakyBucket.Refill] with the old [Rate].
+func (l *LeakyBucket) Rescale(fromBurstLimit, toBurstLimit float64) {
+	if fromBurstLimit <= 0 {
+		l.tokens = toBurstLimit
+		return
+	}
+	l.tokens = l.tokens * toBurstLimit / fromBurstLimit
+}
+
 // // bucket tracks remaining tokens and limit expiration.
 // type bucket struct {
 // 	expires time.Time
@@ -3,6 +3,8 @@ package rate
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 )
 
 // TagFilter directs a [BypassLimiter] to drop tags that return false.
@@ -24,6 +26,90 @@ type Limiter interface {
 		ok bool,
 		err error,
 	)
+	Put(
+		ctx context.Context,
+		tag string,
+		tokens float64,
+	) error
+}
+
+// Reconfigurable is a [Limiter] whose [Rate] and burst limit can be replaced while it is in use. Tokens already taken are preserved, so that changing a limit does not reset it.
+type Reconfigurable interface {
+	SetRate(r *Rate, burstLimit float64) error
+}
+
+// TagRater is a [Limiter] that can tell the [Rate] and the burst limit applied to a tag, when they differ from [Limiter.Rate] and its [Rate.Burst], like a configured burst limit or a different rate for each tier of tags.
+type TagRater interface {
+	TagRate(ctx context.Context, tag string) (r *Rate, burstLimit float64, err error)
+}
+
+// TagRate returns the [Rate] and the burst limit applied to a tag. If the [Limiter] is not a [TagRater], returns [Limiter.Rate] and its [Rate.Burst].
+func TagRate(ctx context.Context, l Limiter, tag string) (*Rate, float64, error) {
+	if rater, ok := l.(TagRater); ok {
+		return rater.TagRate(ctx, tag)
+	}
+	r := l.Rate()
+	return r, r.Burst(), nil
+}
+
+// Bucket reports the state of the tokens of one tag.
+type Bucket struct {
+	Tag       string
+	Remaining float64
+	Touched   time.Time
+}
+
+// Inspector is a [Limiter] that can enumerate and adjust the tokens of its tags. It is used for administration, like unblocking a customer without restarting the process.
+type Inspector interface {
+	// Buckets lists the tags that used tokens recently. Tags that are absent have all of their tokens.
+	Buckets(ctx context.Context) ([]Bucket, error)
+	// Reset restores all tokens of a tag.
+	Reset(ctx context.Context, tag string) error
+	// Adjust grants tokens to a tag, or revokes them, if the amount is negative. The remaining tokens stay between zero and the burst limit.
+	Adjust(ctx context.Context, tag string, tokens float64) (remaining float64, err error)
+}
+
+// ValidateBurst checks the rate and burst limit passed to [Reconfigurable] and returns the burst limit to use. Zero burst limit is replaced by [Rate.Burst].
+func ValidateBurst(r *Rate, burstLimit float64) (float64, error) {
+	if r == nil {
+		return 0, errors.New("cannot use a <nil> rate")
+	}
+	if err := r.Validate(); err != nil {
+		return 0, fmt.Errorf("cannot use invalid rate %q: %w", r, err)
+	}
+	if burstLimit < 0 {
+		return 0, errors.New("burst limit must not be negative")
+	}
+	if burstLimit == 0 {
+		return r.Burst(), nil
+	}
+	return burstLimit, nil
+}
+
+// Delayer is a [Limiter] that can tell how long it takes until tokens become available for a tag.
+type Delayer interface {
+	Delay(
+		ctx context.Context,
+		tag string,
+		tokens float64,
+	) (time.Duration, error)
+}
+
+// Delay returns the time it takes until tokens become available for a tag. If the [Limiter] is not a [Delayer], the delay is estimated using the remaining tokens and the [Rate].
+func Delay(
+	ctx context.Context,
+	l Limiter,
+	tag string,
+	tokens float64,
+) (time.Duration, error) {
+	if delayer, ok := l.(Delayer); ok {
+		return delayer.Delay(ctx, tag, tokens)
+	}
+	remaining, err := l.Remaining(ctx, tag)
+	if err != nil {
+		return 0, err
+	}
+	return l.Rate().ReplenishmentDuration(tokens - remaining), nil
 }
 
 // BypassLimiter uses a [TagFilter] to selectively apply a [Limiter].
@@ -84,3 +170,32 @@ func (b *BypassLimiter) Take(
 	}
 	return b.Limiter.Take(ctx, tag, tokens)
 }
+
+// TagRate returns the [Rate] and the burst limit of the wrapped [Limiter].
+func (b *BypassLimiter) TagRate(ctx context.Context, tag string) (*Rate, float64, error) {
+	return TagRate(ctx, b.Limiter, tag)
+}
+
+// Delay returns the time it takes until tokens become available. Skipped tags are never delayed.
+func (b *BypassLimiter) Delay(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) (time.Duration, error) {
+	if !b.filter(tag) {
+		return 0, nil // skip tag
+	}
+	return Delay(ctx, b.Limiter, tag, tokens)
+}
+
+// Put returns tokens, unless the tag is skipped.
+func (b *BypassLimiter) Put(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) error {
+	if !b.filter(tag) {
+		return nil // skip tag
+	}
+	return b.Limiter.Put(ctx, tag, tokens)
+}
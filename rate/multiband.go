@@ -0,0 +1,144 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Band is one of several [Rate]s that limit the same tag, like a burst limit of 10 per second together with a sustained limit of 1,000 per hour. Zero burst limit is replaced by [Rate.Burst].
+type Band struct {
+	Rate  *Rate
+	Burst float64
+}
+
+// Validate checks the [Rate] and the burst limit.
+func (b Band) Validate() error {
+	_, err := ValidateBurst(b.Rate, b.Burst)
+	return err
+}
+
+// MultiBandLimiter takes tokens from several [Limiter]s for the same tag, so that each one acts as a band. It works with any driver. Drivers that keep bands together, like the mutex driver, can take from all bands atomically instead.
+type MultiBandLimiter struct {
+	bands []Limiter
+	rate  *Rate
+}
+
+// NewMultiBandLimiter combines at least two [Limiter]s. Tokens taken from earlier bands are returned, when a later band rejects, so a rejected request consumes nothing. Order the bands from the cheapest to check to the most expensive.
+func NewMultiBandLimiter(bands ...Limiter) (*MultiBandLimiter, error) {
+	if len(bands) < 2 {
+		return nil, errors.New("at least two bands are required")
+	}
+	m := &MultiBandLimiter{bands: bands}
+	for i, band := range bands {
+		if band == nil {
+			return nil, fmt.Errorf("cannot use a <nil> rate limiter for band %d", i+1)
+		}
+		r := band.Rate()
+		if err := r.Validate(); err != nil {
+			return nil, fmt.Errorf("cannot use invalid rate %q for band %d: %w", r, i+1, err)
+		}
+		if m.rate == nil || r.Burst() < m.rate.Burst() {
+			m.rate = r
+		}
+	}
+	return m, nil
+}
+
+// Rate returns the [Rate] with the lowest burst of all bands, which binds a tag that did not use any tokens yet. Use [MultiBandLimiter.TagRate] to find the band that binds a given tag.
+func (m *MultiBandLimiter) Rate() *Rate {
+	return m.rate
+}
+
+// TagRate returns the [Rate] and the burst limit of the band with the fewest remaining tokens for the tag, so that the reported limit matches the reported remaining tokens. Ties go to the band with the lower burst limit.
+func (m *MultiBandLimiter) TagRate(ctx context.Context, tag string) (r *Rate, burstLimit float64, err error) {
+	least := float64(0)
+	for i, band := range m.bands {
+		remaining, err := band.Remaining(ctx, tag)
+		if err != nil {
+			return nil, 0, err
+		}
+		bandRate, bandBurstLimit, err := TagRate(ctx, band, tag)
+		if err != nil {
+			return nil, 0, err
+		}
+		if i == 0 || remaining < least || (remaining == least && bandBurstLimit < burstLimit) {
+			r, burstLimit, least = bandRate, bandBurstLimit, remaining
+		}
+	}
+	return r, burstLimit, nil
+}
+
+// Remaining returns the fewest tokens left in any band.
+func (m *MultiBandLimiter) Remaining(ctx context.Context, tag string) (least float64, err error) {
+	for i, band := range m.bands {
+		remaining, err := band.Remaining(ctx, tag)
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 || remaining < least {
+			least = remaining
+		}
+	}
+	return least, nil
+}
+
+// Take consumes tokens from every band or from none. The remaining tokens are the fewest of all bands checked.
+func (m *MultiBandLimiter) Take(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) (
+	remaining float64,
+	ok bool,
+	err error,
+) {
+	for i, band := range m.bands {
+		left, taken, err := band.Take(ctx, tag, tokens)
+		if i == 0 || left < remaining {
+			remaining = left
+		}
+		if err == nil && taken {
+			continue
+		}
+		for _, previous := range m.bands[:i] {
+			if rollbackErr := previous.Put(ctx, tag, tokens); rollbackErr != nil {
+				err = errors.Join(err, fmt.Errorf("cannot return tokens: %w", rollbackErr))
+			}
+		}
+		if err != nil {
+			return 0, false, err
+		}
+		return remaining, false, nil
+	}
+	return remaining, true, nil
+}
+
+// Delay returns the longest time it takes for the tokens to become available in every band.
+func (m *MultiBandLimiter) Delay(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) (longest time.Duration, err error) {
+	for _, band := range m.bands {
+		delay, err := Delay(ctx, band, tag, tokens)
+		if err != nil {
+			return 0, err
+		}
+		longest = max(longest, delay)
+	}
+	return longest, nil
+}
+
+// Put returns tokens to every band.
+func (m *MultiBandLimiter) Put(
+	ctx context.Context,
+	tag string,
+	tokens float64,
+) (err error) {
+	for _, band := range m.bands {
+		err = errors.Join(err, band.Put(ctx, tag, tokens))
+	}
+	return err
+}
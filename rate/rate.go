@@ -7,6 +7,8 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"log/slog"
@@ -32,6 +34,39 @@ func New(limit float64, interval time.Duration) (*Rate, error) {
 	return rate, nil
 }
 
+// Parse reads a [Rate] written as tokens per interval, like "10/s", "100/5m", or "1000 per hour". The interval is either a [time.ParseDuration] string or a unit, like "s", "minute", or "day".
+func Parse(s string) (*Rate, error) {
+	tokens, interval, ok := strings.Cut(s, "/")
+	if !ok {
+		if tokens, interval, ok = strings.Cut(s, " per "); !ok {
+			return nil, fmt.Errorf("rate %q must be written as tokens per interval, like \"10/s\"", s)
+		}
+	}
+	limit, err := strconv.ParseFloat(strings.TrimSpace(tokens), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number of tokens in rate %q: %w", s, err)
+	}
+	duration, err := parseInterval(strings.TrimSpace(interval))
+	if err != nil {
+		return nil, fmt.Errorf("invalid interval in rate %q: %w", s, err)
+	}
+	return New(limit, duration)
+}
+
+func parseInterval(s string) (time.Duration, error) {
+	switch s {
+	case "s", "second", "seconds":
+		return time.Second, nil
+	case "m", "minute", "minutes":
+		return time.Minute, nil
+	case "h", "hour", "hours":
+		return time.Hour, nil
+	case "d", "day", "days":
+		return time.Hour * 24, nil
+	}
+	return time.ParseDuration(s)
+}
+
 // Interval returns the duration of expected replenishment time.
 func (r *Rate) Interval() time.Duration {
 	return r.interval
@@ -78,6 +113,14 @@ func (r *Rate) ReplenishedTokens(from, to time.Time) float64 {
 	return float64(to.Sub(from).Nanoseconds()) * r.tokensPerNanosecond
 }
 
+// ReplenishmentDuration returns the time it takes to replenish a given amount of tokens.
+func (r *Rate) ReplenishmentDuration(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(tokens / r.tokensPerNanosecond))
+}
+
 // FasterThan returns true if this [Rate] replenishes more tokens per nanosecond than the other.
 func (r *Rate) FasterThan(a *Rate) bool {
 	return r.tokensPerNanosecond > a.tokensPerNanosecond
@@ -0,0 +1,34 @@
+package rate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		Text     string
+		Tokens   float64
+		Interval time.Duration
+	}{
+		{Text: "10/s", Tokens: 10, Interval: time.Second},
+		{Text: "100/5m", Tokens: 100, Interval: time.Minute * 5},
+		{Text: "1000 per hour", Tokens: 1000, Interval: time.Hour},
+		{Text: " 2.5 / day ", Tokens: 2.5, Interval: time.Hour * 24},
+	}
+	for _, c := range cases {
+		r, err := Parse(c.Text)
+		if err != nil {
+			t.Fatalf("cannot parse rate %q: %v", c.Text, err)
+		}
+		if r.Burst() != c.Tokens || r.Interval() != c.Interval {
+			t.Errorf("rate %q was parsed as %s", c.Text, r)
+		}
+	}
+
+	for _, invalid := range []string{"", "10", "ten/s", "10/fortnight", "10/ms", "0/s", "10/48h"} {
+		if _, err := Parse(invalid); err == nil {
+			t.Errorf("invalid rate %q was accepted", invalid)
+		}
+	}
+}
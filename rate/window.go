@@ -0,0 +1,79 @@
+package rate
+
+import (
+	"math"
+	"time"
+)
+
+/*
+WindowCounter approximates a sliding window by weighting the tokens of the previous fixed window with the part of it that still overlaps the sliding window. Fixed windows are aligned to the Unix epoch and last one [Rate] interval. It keeps two numbers per tag, so it is cheaper than a log of timestamps and smoother than a fixed window.
+
+Error bound: the estimate assumes that the tokens of the previous window were taken evenly. It is exact for steady traffic and never allows more than the limit within one fixed window. Within a sliding window of the [Rate] interval, it allows at most limit × (1 + f), where f is the elapsed fraction of the current fixed window, so always less than twice the limit. That happens only when the previous window was used up at its very end. When the previous window was used up at its beginning instead, the estimate rejects takes that an exact log would allow. Use an exact sliding window log where the limit must never be exceeded.
+
+The fields are exported for drivers that persist the counter.
+*/
+type WindowCounter struct {
+	Window   int64   // index of the current fixed window since the Unix epoch
+	Previous float64 // tokens taken within the previous fixed window
+	Current  float64 // tokens taken within the current fixed window
+}
+
+// Advance moves the counter into the fixed window that contains given time and returns the elapsed fraction of that window. A counter from an unrelated window, including one created with a different [Rate] interval, is cleared.
+func (c *WindowCounter) Advance(at time.Time, r *Rate) (elapsed float64) {
+	interval := r.Interval().Nanoseconds()
+	nanoseconds := at.UnixNano()
+	index := nanoseconds / interval
+	switch index {
+	case c.Window:
+		// still current
+	case c.Window + 1:
+		c.Previous, c.Current = c.Current, 0
+	default:
+		c.Previous, c.Current = 0, 0
+	}
+	c.Window = index
+	return float64(nanoseconds-index*interval) / float64(interval)
+}
+
+// Taken returns the estimated number of tokens taken within the sliding window that ends at given time.
+func (c *WindowCounter) Taken(at time.Time, r *Rate) float64 {
+	elapsed := c.Advance(at, r)
+	return c.Previous*(1-elapsed) + c.Current
+}
+
+// Take adds tokens to the current fixed window, if the estimate stays within the limit.
+func (c *WindowCounter) Take(at time.Time, r *Rate, limit, tokens float64) (remaining float64, ok bool) {
+	remaining = limit - c.Taken(at, r)
+	if tokens > remaining {
+		return remaining, false
+	}
+	c.Current += tokens
+	return remaining - tokens, true
+}
+
+// Put returns tokens to the current fixed window. When a take crossed into a new window, the rest is returned to the previous one. Use only after running [WindowCounter.Advance].
+func (c *WindowCounter) Put(tokens float64) {
+	returned := math.Min(tokens, c.Current)
+	c.Current -= returned
+	c.Previous = math.Max(c.Previous-(tokens-returned), 0)
+}
+
+// Delay returns the time it takes for the estimate to allow given tokens.
+func (c *WindowCounter) Delay(at time.Time, r *Rate, limit, tokens float64) time.Duration {
+	elapsed := c.Advance(at, r)
+	remaining := limit - c.Previous*(1-elapsed) - c.Current
+	if tokens <= remaining {
+		return 0
+	}
+	if tokens > limit {
+		return r.ReplenishmentDuration(tokens - remaining) // never enough
+	}
+	interval := float64(r.Interval().Nanoseconds())
+	if c.Current+tokens <= limit { // the previous window fades out enough
+		fraction := 1 - (limit-tokens-c.Current)/c.Previous
+		return time.Duration(math.Ceil((fraction - elapsed) * interval))
+	}
+	// the current window becomes the previous one and must fade out
+	fraction := 1 - (limit-tokens)/c.Current
+	return time.Duration(math.Ceil((1 - elapsed + fraction) * interval))
+}
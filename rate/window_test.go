@@ -0,0 +1,44 @@
+package rate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowCounter(t *testing.T) {
+	r, err := New(10, time.Minute)
+	if err != nil {
+		t.Fatal("cannot initiate rate:", err)
+	}
+	start := time.Unix(600, 0) // aligned to a fixed window
+	c := &WindowCounter{}
+	if _, ok := c.Take(start, r, 10, 10); !ok {
+		t.Fatal("full window rejected a take")
+	}
+	if _, ok := c.Take(start.Add(time.Second*59), r, 10, 1); ok {
+		t.Fatal("empty window allowed a take")
+	}
+
+	at := start.Add(time.Minute + time.Second*15)
+	remaining, ok := c.Take(at, r, 10, 2)
+	if !ok {
+		t.Fatal("faded previous window rejected a take")
+	}
+	if remaining != 0.5 {
+		t.Fatal("three quarters of the previous window were not counted:", remaining)
+	}
+	if delay := c.Delay(at, r, 10, 3); delay != time.Second*15 {
+		t.Fatal("unexpected delay:", delay)
+	}
+	if delay := c.Delay(at, r, 10, 9); delay != time.Minute+time.Second*15 {
+		t.Fatal("unexpected delay into the next window:", delay)
+	}
+
+	c.Put(3)
+	if c.Current != 0 || c.Previous != 9 {
+		t.Fatalf("refund did not spill into the previous window: %+v", c)
+	}
+	if taken := c.Taken(at.Add(time.Hour), r); taken != 0 {
+		t.Fatal("stale windows were not cleared:", taken)
+	}
+}
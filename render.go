@@ -0,0 +1,185 @@
+package oakratelimiter
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var ( // enforce interface compliance
+	_ ErrorRenderer = (*TextErrorRenderer)(nil)
+	_ ErrorRenderer = (*ProblemJSONErrorRenderer)(nil)
+	_ ErrorRenderer = (*NegotiatingErrorRenderer)(nil)
+)
+
+// ErrorRenderer writes the response body for an error returned by [RequestHandler.ServeHyperText]. The status code comes from [Error] or is [http.StatusInternalServerError].
+type ErrorRenderer interface {
+	MediaType() string
+	RenderError(w http.ResponseWriter, r *http.Request, err error)
+}
+
+func errorStatusCode(err error) int {
+	var httpError Error
+	if errors.As(err, &httpError) {
+		return httpError.HyperTextStatusCode()
+	}
+	return http.StatusInternalServerError
+}
+
+// TextErrorRenderer writes the error message as plain text using [http.Error].
+type TextErrorRenderer struct{}
+
+func (t *TextErrorRenderer) MediaType() string {
+	return "text/plain"
+}
+
+func (t *TextErrorRenderer) RenderError(w http.ResponseWriter, r *http.Request, err error) {
+	http.Error(w, err.Error(), errorStatusCode(err))
+}
+
+// Problem is the RFC 9457 problem details object written by [ProblemJSONErrorRenderer]. RetryAfter and RejectedBy are extension members present only for [TooManyRequestsError].
+type Problem struct {
+	Type       string   `json:"type"`
+	Title      string   `json:"title"`
+	Status     int      `json:"status"`
+	Detail     string   `json:"detail,omitempty"`
+	Instance   string   `json:"instance,omitempty"`
+	RetryAfter int64    `json:"retry-after,omitempty"`
+	RejectedBy []string `json:"rejected-by,omitempty"`
+}
+
+// ProblemJSONErrorRenderer writes "application/problem+json" responses according to [RFC 9457]. Rejections include the number of seconds to wait before retrying and the names of the rejecting request limiters.
+//
+// [RFC 9457]: https://www.rfc-editor.org/rfc/rfc9457
+type ProblemJSONErrorRenderer struct {
+	typeURI string
+}
+
+// NewProblemJSONErrorRenderer creates a [ProblemJSONErrorRenderer]. The type URI identifies the rate limiting problem in [TooManyRequestsError] responses. If empty, "about:blank" is used.
+func NewProblemJSONErrorRenderer(typeURI string) *ProblemJSONErrorRenderer {
+	if typeURI == "" {
+		typeURI = "about:blank"
+	}
+	return &ProblemJSONErrorRenderer{typeURI: typeURI}
+}
+
+func (p *ProblemJSONErrorRenderer) MediaType() string {
+	return "application/problem+json"
+}
+
+func (p *ProblemJSONErrorRenderer) RenderError(w http.ResponseWriter, r *http.Request, err error) {
+	status := errorStatusCode(err)
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   err.Error(),
+		Instance: r.URL.Path,
+	}
+	var tooMany *TooManyRequestsError
+	if errors.As(err, &tooMany) {
+		problem.Type = p.typeURI
+		problem.RetryAfter = int64(math.Ceil(tooMany.RetryAfter().Seconds()))
+		problem.RejectedBy = tooMany.RejectedBy()
+	}
+
+	header := w.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Type", "application/problem+json")
+	header.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+// NewDefaultErrorRenderer creates a [NegotiatingErrorRenderer] that writes plain text, unless the client prefers "application/problem+json".
+func NewDefaultErrorRenderer() *NegotiatingErrorRenderer {
+	return &NegotiatingErrorRenderer{renderers: []ErrorRenderer{
+		&TextErrorRenderer{},
+		NewProblemJSONErrorRenderer(""),
+	}}
+}
+
+// NegotiatingErrorRenderer picks an [ErrorRenderer] by matching its media type against the "Accept" request header. The first renderer is used when the header is missing or nothing matches.
+type NegotiatingErrorRenderer struct {
+	renderers []ErrorRenderer
+}
+
+// NewNegotiatingErrorRenderer creates a [NegotiatingErrorRenderer]. The first renderer is the default.
+func NewNegotiatingErrorRenderer(renderers ...ErrorRenderer) (*NegotiatingErrorRenderer, error) {
+	if len(renderers) == 0 {
+		return nil, errors.New("cannot use an empty list of error renderers")
+	}
+	for _, renderer := range renderers {
+		if renderer == nil {
+			return nil, errors.New("cannot use a <nil> error renderer")
+		}
+	}
+	return &NegotiatingErrorRenderer{renderers: renderers}, nil
+}
+
+// MediaType returns the media type of the default renderer.
+func (n *NegotiatingErrorRenderer) MediaType() string {
+	return n.renderers[0].MediaType()
+}
+
+func (n *NegotiatingErrorRenderer) RenderError(w http.ResponseWriter, r *http.Request, err error) {
+	n.Negotiate(r).RenderError(w, r, err)
+}
+
+// Negotiate returns the [ErrorRenderer] whose media type has the highest quality in the "Accept" request header. Exact media types take precedence over wildcards. Renderers of equal quality keep their order.
+func (n *NegotiatingErrorRenderer) Negotiate(r *http.Request) ErrorRenderer {
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return n.renderers[0]
+	}
+	best, bestQuality, bestSpecificity := n.renderers[0], 0.0, -1
+	for _, renderer := range n.renderers {
+		quality, specificity := acceptQuality(accept, renderer.MediaType())
+		if quality > bestQuality || (quality == bestQuality && quality > 0 && specificity > bestSpecificity) {
+			best, bestQuality, bestSpecificity = renderer, quality, specificity
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality of the most specific "Accept" media range that covers the media type. Specificity is 2 for an exact match, 1 for "type/*" or the structured syntax suffix, like "application/json" for "application/problem+json", and 0 for "*/*".
+func acceptQuality(accept []string, mediaType string) (quality float64, specificity int) {
+	kind, _, _ := strings.Cut(mediaType, "/")
+	suffix := ""
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		suffix = kind + "/" + mediaType[i+1:]
+	}
+	specificity = -1
+	for _, value := range accept {
+		for _, mediaRange := range strings.Split(value, ",") {
+			accepted, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+			if err != nil {
+				continue
+			}
+			current := -1
+			switch {
+			case accepted == mediaType:
+				current = 2
+			case accepted == kind+"/*", accepted == suffix:
+				current = 1
+			case accepted == "*/*":
+				current = 0
+			}
+			if current <= specificity {
+				continue
+			}
+			specificity = current
+			quality = 1
+			if q, ok := params["q"]; ok {
+				if quality, err = strconv.ParseFloat(q, 64); err != nil {
+					quality = 0
+				}
+			}
+		}
+	}
+	return quality, specificity
+}
@@ -0,0 +1,76 @@
+package oakratelimiter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNegotiatingErrorRenderer(t *testing.T) {
+	text := &TextErrorRenderer{}
+	problem := NewProblemJSONErrorRenderer("")
+	n, err := NewNegotiatingErrorRenderer(text, problem)
+	if err != nil {
+		t.Fatal("cannot initialize error renderer:", err)
+	}
+	if _, err = NewNegotiatingErrorRenderer(); err == nil {
+		t.Fatal("empty list of error renderers was accepted")
+	}
+	if _, err = NewNegotiatingErrorRenderer(text, nil); err == nil {
+		t.Fatal("<nil> error renderer was accepted")
+	}
+
+	cases := []struct {
+		Accept   string
+		Expected ErrorRenderer
+	}{
+		{Accept: "", Expected: text},
+		{Accept: "*/*", Expected: text},
+		{Accept: "text/html", Expected: text},
+		{Accept: "application/problem+json", Expected: problem},
+		{Accept: "application/json", Expected: problem},
+		{Accept: "text/plain;q=0.5, application/*", Expected: problem},
+		{Accept: "application/problem+json;q=0.2, */*;q=0.5", Expected: text},
+		{Accept: "text/plain;q=0, application/problem+json;q=0", Expected: text},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if c.Accept != "" {
+			r.Header.Set("Accept", c.Accept)
+		}
+		if selected := n.Negotiate(r); selected != c.Expected {
+			t.Errorf("%q negotiated %q instead of %q", c.Accept, selected.MediaType(), c.Expected.MediaType())
+		}
+	}
+}
+
+func TestProblemJSONErrorRenderer(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/export", nil)
+	NewProblemJSONErrorRenderer("https://example.com/problems/rate-limit").RenderError(w, r, &TooManyRequestsError{
+		rejectedEndpointAccessControlNames: []string{"global", "internetProtocolAddress"},
+		retryAfter:                         time.Millisecond * 1500,
+	})
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatal("unexpected status code:", w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+		t.Fatal("unexpected content type:", contentType)
+	}
+	var p Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatal("cannot decode problem:", err)
+	}
+	if p.Type != "https://example.com/problems/rate-limit" || p.Status != http.StatusTooManyRequests {
+		t.Fatalf("unexpected problem: %+v", p)
+	}
+	if p.RetryAfter != 2 {
+		t.Fatal("retry after was not rounded up to seconds:", p.RetryAfter)
+	}
+	if len(p.RejectedBy) != 2 || p.RejectedBy[1] != "internetProtocolAddress" {
+		t.Fatal("unexpected rejecting limiters:", p.RejectedBy)
+	}
+}
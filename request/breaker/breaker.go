@@ -0,0 +1,294 @@
+/*
+Package breaker protects [request.Limiter]s backed by remote drivers from taking the whole service down when the driver fails.
+
+A [Limiter] applies a [Policy] whenever the wrapped limiter returns an error: fail closed, fail open, or fall back to a degraded in-memory rate. After a number of consecutive failures, the circuit opens and the wrapped limiter is not called at all until the cooldown period passes. Then a single request probes the wrapped limiter: success closes the circuit, failure opens it for another cooldown period.
+*/
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/driver/mutexrlm"
+	"github.com/dkotik/oakratelimiter/rate"
+	"github.com/dkotik/oakratelimiter/request"
+)
+
+var ( // enforce interface compliance
+	_ request.Limiter        = (*Limiter)(nil)
+	_ request.Delayer        = (*Limiter)(nil)
+	_ request.TaggingLimiter = (*Limiter)(nil)
+	_ request.Explainer      = (*Limiter)(nil)
+	_ request.CostingLimiter = (*Limiter)(nil)
+	_ request.Rater          = (*Limiter)(nil)
+	_ request.Releaser       = (*releasingLimiter)(nil)
+)
+
+// ErrCircuitOpen indicates that the wrapped [request.Limiter] was not called, because it failed too many times in a row.
+var ErrCircuitOpen = errors.New("rate limiter circuit is open")
+
+// Policy determines what happens to a request when the wrapped [request.Limiter] fails.
+type Policy uint8
+
+const (
+	// FailClosed returns the error, which rejects the request.
+	FailClosed Policy = iota + 1
+
+	// FailOpen lets the request through.
+	FailOpen
+
+	// FailDegraded takes tokens from the fallback [request.Limiter] instead.
+	FailDegraded
+)
+
+// Limiter applies a failure [Policy] and a circuit breaker to a [request.Limiter].
+type Limiter struct {
+	limiter   request.Limiter
+	policy    Policy
+	fallback  request.Limiter
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// New wraps a [request.Limiter]. Without options, it fails closed and opens the circuit for ten seconds after five consecutive failures. Providing a fallback implies [FailDegraded] policy. A fallback rate creates an in-memory limiter that tracks the same tags as the wrapped limiter, if it is a [request.TaggingLimiter]. Its tags are cleaned up in the background until the context given to [WithCleanupContext] is cancelled.
+func New(l request.Limiter, withOptions ...Option) (_ request.Limiter, err error) {
+	if l == nil {
+		return nil, errors.New("cannot use a <nil> request limiter")
+	}
+	o := &options{}
+	for _, option := range append(
+		withOptions,
+		WithDefaultPolicy(),
+		WithDefaultThreshold(),
+		WithDefaultCooldown(),
+		WithDefaultCleanupContext(),
+		func(o *options) error { // fallback limiter
+			if o.FallbackRate != nil {
+				if o.Fallback, err = newFallback(o.CleanupContext, l, o.FallbackRate); err != nil {
+					return err
+				}
+			}
+			if o.Policy == FailDegraded && o.Fallback == nil {
+				return errors.New("degraded failure policy requires a fallback limiter")
+			}
+			if o.Policy != FailDegraded && o.Fallback != nil {
+				return errors.New("fallback limiter requires degraded failure policy")
+			}
+			return nil
+		},
+	) {
+		if err = option(o); err != nil {
+			return nil, fmt.Errorf("cannot initialize circuit breaker: %w", err)
+		}
+	}
+
+	b := &Limiter{
+		limiter:   l,
+		policy:    o.Policy,
+		fallback:  o.Fallback,
+		threshold: o.Threshold,
+		cooldown:  o.Cooldown,
+	}
+	if _, ok := l.(request.Releaser); ok {
+		return &releasingLimiter{b}, nil
+	}
+	return b, nil
+}
+
+func newFallback(ctx context.Context, l request.Limiter, r *rate.Rate) (request.Limiter, error) {
+	if _, ok := l.(request.TaggingLimiter); !ok {
+		return mutexrlm.NewRequestLimiter(mutexrlm.WithRate(r))
+	}
+	tagged, err := mutexrlm.New(
+		mutexrlm.WithRate(r),
+		mutexrlm.WithCleanupContext(ctx),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return request.NewLimiter(func(r *http.Request) (string, error) {
+		return request.Tag(l, r)
+	}, tagged)
+}
+
+// Open returns true while the wrapped [request.Limiter] is not being called.
+func (b *Limiter) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= b.threshold && time.Now().Before(b.openUntil)
+}
+
+// allow decides whether the wrapped limiter should be called. Once the cooldown passes, only one request at a time probes it.
+func (b *Limiter) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *Limiter) record(r *http.Request, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wasOpen := b.failures >= b.threshold
+	b.probing = false
+	if err == nil {
+		b.failures = 0
+		if wasOpen {
+			slog.Log(r.Context(), slog.LevelInfo, "rate limiter circuit closed")
+		}
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+		slog.Log(
+			r.Context(),
+			slog.LevelWarn,
+			"rate limiter circuit opened",
+			slog.Int("failures", b.failures),
+			slog.Duration("cooldown", b.cooldown),
+			slog.Any("error", err),
+		)
+	}
+}
+
+func (b *Limiter) Rate() *rate.Rate {
+	return b.limiter.Rate()
+}
+
+func (b *Limiter) Tag(r *http.Request) (string, error) {
+	return request.Tag(b.limiter, r)
+}
+
+func (b *Limiter) RequestRate(r *http.Request) (*rate.Rate, float64, error) {
+	return request.RequestRate(b.limiter, r)
+}
+
+func (b *Limiter) Cost(r *http.Request) (float64, error) {
+	return request.Cost(b.limiter, r)
+}
+
+func (b *Limiter) Explain(r *http.Request) (string, time.Duration) {
+	return request.Explain(b.limiter, r)
+}
+
+// Take remembers which limiter served the request, so that [Limiter.Put] returns the tokens to the same limiter, even if the circuit changes state in the meantime.
+func (b *Limiter) Take(r *http.Request) (
+	remaining float64,
+	ok bool,
+	err error,
+) {
+	if !b.allow() {
+		return b.fail(r, ErrCircuitOpen)
+	}
+	remaining, ok, err = b.limiter.Take(r)
+	b.record(r, err)
+	if err != nil {
+		return b.fail(r, err)
+	}
+	request.Remember(r.Context(), b, servedByLimiter)
+	return remaining, ok, nil
+}
+
+// fail applies the failure [Policy].
+func (b *Limiter) fail(r *http.Request, cause error) (float64, bool, error) {
+	switch b.policy {
+	case FailOpen:
+		request.Remember(r.Context(), b, servedByNone)
+		if limiterRate := b.limiter.Rate(); limiterRate != nil {
+			return limiterRate.Burst(), true, nil
+		}
+		return 0, true, nil
+	case FailDegraded:
+		request.Remember(r.Context(), b, servedByFallback)
+		return b.fallback.Take(r)
+	default:
+		request.Remember(r.Context(), b, servedByNone)
+		return 0, false, cause
+	}
+}
+
+// served tells which limiter took tokens for the request.
+type served uint8
+
+const (
+	servedByNone served = iota
+	servedByLimiter
+	servedByFallback
+)
+
+// server returns the limiter that served the request or <nil>, if the request was let through or rejected by the failure [Policy]. Requests that were not given a memo by [request.NewMemoContext] are attributed by the current state of the circuit.
+func (b *Limiter) server(r *http.Request) request.Limiter {
+	value, ok := request.Recall(r.Context(), b)
+	s, _ := value.(served)
+	if !ok {
+		switch {
+		case !b.Open():
+			s = servedByLimiter
+		case b.policy == FailDegraded:
+			s = servedByFallback
+		default:
+			s = servedByNone
+		}
+	}
+	switch s {
+	case servedByLimiter:
+		return b.limiter
+	case servedByFallback:
+		return b.fallback
+	default:
+		return nil
+	}
+}
+
+// Delay reports the delay of the fallback limiter while the circuit is open.
+func (b *Limiter) Delay(r *http.Request) (time.Duration, error) {
+	if !b.Open() {
+		return request.Delay(b.limiter, r)
+	}
+	switch b.policy {
+	case FailOpen:
+		return 0, nil
+	case FailDegraded:
+		return request.Delay(b.fallback, r)
+	default:
+		return 0, request.ErrUnknownDelay
+	}
+}
+
+// Put returns tokens to the limiter that served the request.
+func (b *Limiter) Put(r *http.Request) error {
+	if l := b.server(r); l != nil {
+		return l.Put(r)
+	}
+	return nil
+}
+
+// releasingLimiter forwards [request.Releaser] to the wrapped limiter.
+type releasingLimiter struct {
+	*Limiter
+}
+
+// Release frees the tokens held by the limiter that served the request.
+func (b *releasingLimiter) Release(r *http.Request) error {
+	if releaser, ok := b.server(r).(request.Releaser); ok {
+		return releaser.Release(r)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package breaker
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/rate"
+	"github.com/dkotik/oakratelimiter/request"
+)
+
+// flakyLimiter fails while broken is true and counts calls.
+type flakyLimiter struct {
+	broken bool
+	calls  int
+	puts   int
+}
+
+func (l *flakyLimiter) Rate() *rate.Rate {
+	r, _ := rate.New(10, time.Minute)
+	return r
+}
+
+func (l *flakyLimiter) Take(*http.Request) (float64, bool, error) {
+	l.calls++
+	if l.broken {
+		return 0, false, errors.New("database is unreachable")
+	}
+	return 1, true, nil
+}
+
+func (l *flakyLimiter) Put(*http.Request) error {
+	l.puts++
+	return nil
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	primary := &flakyLimiter{broken: true}
+	l, err := New(primary, WithThreshold(2), WithCooldown(time.Millisecond*50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for i := 0; i < 2; i++ {
+		if _, _, err = l.Take(r); err == nil {
+			t.Fatal("closed failure policy did not return the error")
+		}
+	}
+	if _, _, err = l.Take(r); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("circuit did not open:", err)
+	}
+	if primary.calls != 2 {
+		t.Fatal("open circuit called the primary limiter:", primary.calls)
+	}
+
+	time.Sleep(time.Millisecond * 60)
+	primary.broken = false
+	if _, ok, err := l.Take(r); err != nil || !ok {
+		t.Fatal("probe did not close the circuit:", err)
+	}
+	if _, ok, err := l.Take(r); err != nil || !ok || primary.calls != 4 {
+		t.Fatal("closed circuit did not call the primary limiter:", err)
+	}
+}
+
+func TestFailurePolicies(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	open, err := New(&flakyLimiter{broken: true}, WithPolicy(FailOpen))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := open.Take(r); err != nil || !ok {
+		t.Fatal("open failure policy did not let the request through:", err)
+	}
+
+	degraded, err := New(&flakyLimiter{broken: true}, WithNewFallbackRate(1, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := degraded.Take(r); err != nil || !ok {
+		t.Fatal("degraded failure policy rejected the first request:", err)
+	}
+	if _, ok, err := degraded.Take(r); err != nil || ok {
+		t.Fatal("degraded failure policy did not apply the fallback rate:", err)
+	}
+
+	if _, err = New(&flakyLimiter{}, WithPolicy(FailDegraded)); err == nil {
+		t.Fatal("degraded failure policy without a fallback was accepted")
+	}
+}
+
+func TestRefundAfterCircuitOpened(t *testing.T) {
+	primary := &flakyLimiter{}
+	l, err := New(primary, WithThreshold(1), WithNewFallbackRate(1, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := httptest.NewRequest(http.MethodGet, "/", nil)
+	served = served.WithContext(request.NewMemoContext(served.Context()))
+	if _, ok, err := l.Take(served); err != nil || !ok {
+		t.Fatal("closed circuit rejected the request:", err)
+	}
+
+	primary.broken = true
+	failing := httptest.NewRequest(http.MethodGet, "/", nil)
+	failing = failing.WithContext(request.NewMemoContext(failing.Context()))
+	if _, ok, err := l.Take(failing); err != nil || !ok {
+		t.Fatal("fallback rejected the request:", err)
+	}
+	if !l.(*Limiter).Open() {
+		t.Fatal("circuit did not open")
+	}
+
+	if err = l.Put(served); err != nil {
+		t.Fatal(err)
+	}
+	if primary.puts != 1 {
+		t.Fatal("tokens were not returned to the limiter that served the request")
+	}
+	if err = l.Put(failing); err != nil {
+		t.Fatal(err)
+	}
+	if primary.puts != 1 {
+		t.Fatal("fallback tokens were returned to the wrapped limiter")
+	}
+}
@@ -0,0 +1,161 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/rate"
+	"github.com/dkotik/oakratelimiter/request"
+)
+
+type options struct {
+	Policy         Policy
+	Fallback       request.Limiter
+	FallbackRate   *rate.Rate
+	Threshold      int
+	Cooldown       time.Duration
+	CleanupContext context.Context
+}
+
+// Option configures the circuit breaker.
+type Option func(*options) error
+
+// WithPolicy sets how requests are handled while the circuit is open.
+func WithPolicy(p Policy) Option {
+	return func(o *options) error {
+		if p != FailClosed && p != FailOpen && p != FailDegraded {
+			return fmt.Errorf("unknown failure policy %d", p)
+		}
+		if o.Policy != 0 {
+			return errors.New("failure policy is already set")
+		}
+		o.Policy = p
+		return nil
+	}
+}
+
+// WithDefaultPolicy picks [FailDegraded], if a fallback was set, and [FailClosed] otherwise.
+func WithDefaultPolicy() Option {
+	return func(o *options) error {
+		if o.Policy != 0 {
+			return nil // already set
+		}
+		if o.Fallback != nil || o.FallbackRate != nil {
+			return WithPolicy(FailDegraded)(o)
+		}
+		return WithPolicy(FailClosed)(o)
+	}
+}
+
+// WithFallback sets the [request.Limiter] that serves requests while the circuit is open under [FailDegraded] policy.
+func WithFallback(l request.Limiter) Option {
+	return func(o *options) error {
+		if l == nil {
+			return errors.New("cannot use a <nil> fallback limiter")
+		}
+		if o.Fallback != nil || o.FallbackRate != nil {
+			return errors.New("fallback limiter is already set")
+		}
+		o.Fallback = l
+		return nil
+	}
+}
+
+// WithFallbackRate creates an in-memory fallback [request.Limiter] with the given [rate.Rate].
+func WithFallbackRate(r *rate.Rate) Option {
+	return func(o *options) error {
+		if r == nil {
+			return errors.New("cannot use a <nil> fallback rate")
+		}
+		if o.Fallback != nil || o.FallbackRate != nil {
+			return errors.New("fallback limiter is already set")
+		}
+		o.FallbackRate = r
+		return nil
+	}
+}
+
+// WithNewFallbackRate creates a [rate.Rate] to pass to [WithFallbackRate] option.
+func WithNewFallbackRate(tokens float64, interval time.Duration) Option {
+	return func(o *options) error {
+		r, err := rate.New(tokens, interval)
+		if err != nil {
+			return fmt.Errorf("cannot initialize fallback rate: %w", err)
+		}
+		return WithFallbackRate(r)(o)
+	}
+}
+
+// WithThreshold sets the number of consecutive failures that open the circuit.
+func WithThreshold(failures int) Option {
+	return func(o *options) error {
+		if failures < 1 {
+			return errors.New("failure threshold must be greater than zero")
+		}
+		if o.Threshold != 0 {
+			return errors.New("failure threshold is already set")
+		}
+		o.Threshold = failures
+		return nil
+	}
+}
+
+// WithDefaultThreshold opens the circuit after five consecutive failures.
+func WithDefaultThreshold() Option {
+	return func(o *options) error {
+		if o.Threshold != 0 {
+			return nil // already set
+		}
+		return WithThreshold(5)(o)
+	}
+}
+
+// WithCooldown sets how long the circuit stays open before the wrapped limiter is tried again.
+func WithCooldown(d time.Duration) Option {
+	return func(o *options) error {
+		if d <= 0 {
+			return errors.New("cooldown must be greater than zero")
+		}
+		if o.Cooldown != 0 {
+			return errors.New("cooldown is already set")
+		}
+		o.Cooldown = d
+		return nil
+	}
+}
+
+// WithDefaultCooldown keeps the circuit open for ten seconds.
+func WithDefaultCooldown() Option {
+	return func(o *options) error {
+		if o.Cooldown != 0 {
+			return nil // already set
+		}
+		return WithCooldown(time.Second * 10)(o)
+	}
+}
+
+// WithCleanupContext provides the [context.Context] for garbage collection of the in-memory fallback created by [WithFallbackRate]. When the context is cancelled, garbage collection stops.
+func WithCleanupContext(ctx context.Context) Option {
+	return func(o *options) error {
+		if ctx == nil {
+			return errors.New("cannot use a <nil> clean up context")
+		}
+		if o.CleanupContext != nil {
+			return errors.New("clean up context is already set")
+		}
+		o.CleanupContext = ctx
+		return nil
+	}
+}
+
+// WithDefaultCleanupContext passes [context.Background] to [WithCleanupContext] option.
+func WithDefaultCleanupContext() Option {
+	return func(o *options) error {
+		if o.CleanupContext != nil {
+			return nil // already set
+		}
+		return WithCleanupContext(context.Background())(o)
+	}
+}
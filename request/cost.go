@@ -0,0 +1,117 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// Coster determines how many tokens an [http.Request] consumes. Use it to make expensive endpoints drain rate limits faster than cheap ones. A [Coster] must return the same cost for the same request, because the cost is calculated again when tokens are returned.
+type Coster func(*http.Request) (float64, error)
+
+// UnitCost charges one token for every request.
+func UnitCost(*http.Request) (float64, error) {
+	return 1.0, nil
+}
+
+// NewFixedCoster charges the same number of tokens for every request.
+func NewFixedCoster(tokens float64) (Coster, error) {
+	if err := validateCost(tokens); err != nil {
+		return nil, err
+	}
+	return func(*http.Request) (float64, error) {
+		return tokens, nil
+	}, nil
+}
+
+// NewMethodCoster charges tokens by [http.Request] method. Methods are not case sensitive, so the cost table must not list the same method twice in different case. Methods missing from the cost table are charged the fallback cost.
+func NewMethodCoster(costs map[string]float64, fallback float64) (Coster, error) {
+	if len(costs) == 0 {
+		return nil, errors.New("cannot use an empty method cost table")
+	}
+	if err := validateCost(fallback); err != nil {
+		return nil, fmt.Errorf("invalid fallback cost: %w", err)
+	}
+	table := make(map[string]float64, len(costs))
+	for method, cost := range costs {
+		if method == "" {
+			return nil, errors.New("cannot use an empty method")
+		}
+		if err := validateCost(cost); err != nil {
+			return nil, fmt.Errorf("invalid cost for method %q: %w", method, err)
+		}
+		normalized := strings.ToUpper(method)
+		if _, ok := table[normalized]; ok {
+			return nil, fmt.Errorf("cost for method %q is already set", normalized)
+		}
+		table[normalized] = cost
+	}
+	return func(r *http.Request) (float64, error) {
+		if cost, ok := table[r.Method]; ok {
+			return cost, nil
+		}
+		return fallback, nil
+	}, nil
+}
+
+// NewPathCoster charges tokens by the longest [url.URL] path prefix found in the cost table. Paths matching none of the prefixes are charged the fallback cost.
+func NewPathCoster(costs map[string]float64, fallback float64) (Coster, error) {
+	if len(costs) == 0 {
+		return nil, errors.New("cannot use an empty path cost table")
+	}
+	if err := validateCost(fallback); err != nil {
+		return nil, fmt.Errorf("invalid fallback cost: %w", err)
+	}
+	table := make(map[string]float64, len(costs))
+	for prefix, cost := range costs {
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("path prefix %q must begin with a slash", prefix)
+		}
+		if err := validateCost(cost); err != nil {
+			return nil, fmt.Errorf("invalid cost for path prefix %q: %w", prefix, err)
+		}
+		table[prefix] = cost
+	}
+	return func(r *http.Request) (float64, error) {
+		longest := -1
+		cost := fallback
+		for prefix, prefixCost := range table {
+			if len(prefix) > longest && strings.HasPrefix(r.URL.Path, prefix) {
+				longest = len(prefix)
+				cost = prefixCost
+			}
+		}
+		return cost, nil
+	}, nil
+}
+
+// NewContentLengthCoster charges the base cost plus one token for every started chunk of request body of given size in bytes. Requests with unknown content length, like chunked uploads, are charged the fallback cost, which should cover the largest body the server accepts.
+func NewContentLengthCoster(base float64, bytesPerToken int64, fallback float64) (Coster, error) {
+	if err := validateCost(base); err != nil {
+		return nil, fmt.Errorf("invalid base cost: %w", err)
+	}
+	if bytesPerToken < 1 {
+		return nil, errors.New("bytes per token must be greater than zero")
+	}
+	if err := validateCost(fallback); err != nil {
+		return nil, fmt.Errorf("invalid fallback cost: %w", err)
+	}
+	return func(r *http.Request) (float64, error) {
+		if r.ContentLength < 0 {
+			return fallback, nil
+		}
+		return base + math.Ceil(float64(r.ContentLength)/float64(bytesPerToken)), nil
+	}, nil
+}
+
+func validateCost(tokens float64) error {
+	if math.IsNaN(tokens) || math.IsInf(tokens, 0) {
+		return errors.New("cost must be a finite number")
+	}
+	if tokens < 0 {
+		return errors.New("cost must not be negative")
+	}
+	return nil
+}
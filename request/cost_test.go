@@ -0,0 +1,62 @@
+package request
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCosters(t *testing.T) {
+	byMethod, err := NewMethodCoster(map[string]float64{"post": 5}, 1)
+	if err != nil {
+		t.Fatal("cannot initialize method coster:", err)
+	}
+	byPath, err := NewPathCoster(map[string]float64{
+		"/api/":        2,
+		"/api/export/": 20,
+	}, 1)
+	if err != nil {
+		t.Fatal("cannot initialize path coster:", err)
+	}
+	byContentLength, err := NewContentLengthCoster(1, 1024, 100)
+	if err != nil {
+		t.Fatal("cannot initialize content length coster:", err)
+	}
+
+	chunked := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("unknown"))
+	chunked.ContentLength = -1
+
+	cases := []struct {
+		Coster   Coster
+		Request  *http.Request
+		Expected float64
+	}{
+		{Coster: UnitCost, Request: httptest.NewRequest(http.MethodGet, "/", nil), Expected: 1},
+		{Coster: byMethod, Request: httptest.NewRequest(http.MethodGet, "/", nil), Expected: 1},
+		{Coster: byMethod, Request: httptest.NewRequest(http.MethodPost, "/", nil), Expected: 5},
+		{Coster: byPath, Request: httptest.NewRequest(http.MethodGet, "/", nil), Expected: 1},
+		{Coster: byPath, Request: httptest.NewRequest(http.MethodGet, "/api/users", nil), Expected: 2},
+		{Coster: byPath, Request: httptest.NewRequest(http.MethodGet, "/api/export/all", nil), Expected: 20},
+		{Coster: byContentLength, Request: httptest.NewRequest(http.MethodPost, "/", nil), Expected: 1},
+		{Coster: byContentLength, Request: httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("a", 1025))), Expected: 3},
+		{Coster: byContentLength, Request: chunked, Expected: 100},
+	}
+
+	for i, c := range cases {
+		cost, err := c.Coster(c.Request)
+		if err != nil {
+			t.Fatal(i+1, "coster failed:", err)
+		}
+		if cost != c.Expected {
+			t.Fatal(i+1, "cost does not match:", cost, c.Expected)
+		}
+	}
+
+	if _, err = NewMethodCoster(map[string]float64{"GET": -1}, 1); err == nil {
+		t.Fatal("negative cost was accepted")
+	}
+	if _, err = NewMethodCoster(map[string]float64{"get": 1, "GET": 5}, 1); err == nil {
+		t.Fatal("method listed twice in different case was accepted")
+	}
+}
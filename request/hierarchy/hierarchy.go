@@ -0,0 +1,289 @@
+/*
+Package hierarchy implements [request.Limiter] for nested quotas, like a user within an organization within a global limit. Each request takes tokens from every level, so that a busy user cannot exhaust the quota of the whole organization, and a busy organization cannot exhaust the global one.
+
+Only the tag of the first level comes from a [request.Tagger]. The tags of the other levels are resolved from the tag below using a [ParentResolver]:
+
+	limiter, err := hierarchy.New(
+		hierarchy.WithTagger(userTagger),
+		hierarchy.WithLevel("user", userRateLimiter),
+		hierarchy.WithParentLevel("organization", organizationRateLimiter, organizationOfUser),
+		hierarchy.WithGlobalLevel("global", globalRateLimiter),
+	)
+*/
+package hierarchy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/rate"
+	"github.com/dkotik/oakratelimiter/request"
+)
+
+var ( // enforce interface compliance
+	_ request.Limiter        = (*Limiter)(nil)
+	_ request.Delayer        = (*Limiter)(nil)
+	_ request.TaggingLimiter = (*Limiter)(nil)
+	_ request.Explainer      = (*Limiter)(nil)
+	_ request.CostingLimiter = (*Limiter)(nil)
+	_ request.Rater          = (*Limiter)(nil)
+)
+
+// ParentResolver returns the tag of the level above for a given tag, like the organization of a user. An empty parent skips the level, and the level after it is resolved from the same child tag.
+type ParentResolver func(ctx context.Context, tag string) (parent string, err error)
+
+// NewMapParentResolver creates a [ParentResolver] from a map of child tags to parent tags. Tags absent from the map skip the level.
+func NewMapParentResolver(parents map[string]string) ParentResolver {
+	return func(ctx context.Context, tag string) (string, error) {
+		return parents[tag], nil
+	}
+}
+
+type level struct {
+	name    string
+	limiter rate.Limiter
+	parent  ParentResolver
+}
+
+// Limiter takes tokens from every level of a hierarchy. Either all levels give up the tokens or none do: when a level rejects the request, tokens taken from the levels below are returned.
+type Limiter struct {
+	tagger request.Tagger
+	coster request.Coster
+	levels []level
+}
+
+// New creates a [Limiter]. A [request.Tagger] and at least two levels are required.
+func New(withOptions ...Option) (_ *Limiter, err error) {
+	o := &options{}
+	for _, option := range append(
+		withOptions,
+		WithDefaultCoster(),
+		func(o *options) error { // validate
+			if o.Tagger == nil {
+				return errors.New("tagger is required")
+			}
+			if len(o.Levels) < 2 {
+				return errors.New("at least two levels are required")
+			}
+			return nil
+		},
+	) {
+		if err = option(o); err != nil {
+			return nil, fmt.Errorf("cannot initialize hierarchical request limiter: %w", err)
+		}
+	}
+	return &Limiter{
+		tagger: o.Tagger,
+		coster: o.Coster,
+		levels: o.Levels,
+	}, nil
+}
+
+// Rate returns the rate of the first level.
+func (l *Limiter) Rate() *rate.Rate {
+	return l.levels[0].limiter.Rate()
+}
+
+// RequestRate returns the rate and the burst limit of the first level.
+func (l *Limiter) RequestRate(r *http.Request) (*rate.Rate, float64, error) {
+	tag, err := l.tagger(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rate.TagRate(r.Context(), l.levels[0].limiter, tag)
+}
+
+func (l *Limiter) Cost(r *http.Request) (float64, error) {
+	return l.coster(r)
+}
+
+// Tag returns the tag of the first level.
+func (l *Limiter) Tag(r *http.Request) (string, error) {
+	return l.tagger(r)
+}
+
+// Tags resolves the tag of each level for a request. Skipped levels have empty tags.
+func (l *Limiter) Tags(r *http.Request) ([]string, error) {
+	tag, err := l.tagger(r)
+	if err != nil {
+		return nil, err
+	}
+	return l.resolve(r.Context(), tag)
+}
+
+func (l *Limiter) resolve(ctx context.Context, tag string) ([]string, error) {
+	tags := make([]string, len(l.levels))
+	tags[0] = tag
+	for i := 1; i < len(l.levels); i++ {
+		parent, err := l.levels[i].parent(ctx, tag)
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve %s of %q: %w", l.levels[i].name, tag, err)
+		}
+		if parent != "" {
+			tags[i] = parent
+			tag = parent
+		}
+	}
+	return tags, nil
+}
+
+// Take takes tokens from each level, starting with the first. The remaining tokens are the fewest of all levels.
+func (l *Limiter) Take(r *http.Request) (
+	remaining float64,
+	ok bool,
+	err error,
+) {
+	tags, err := l.Tags(r)
+	if err != nil {
+		return 0, false, err
+	}
+	tokens, err := l.coster(r)
+	if err != nil {
+		return 0, false, err
+	}
+	ctx := r.Context()
+	for i, tag := range tags {
+		if tag == "" {
+			continue // skipped
+		}
+		left, taken, err := l.levels[i].limiter.Take(ctx, tag, tokens)
+		if i == 0 || left < remaining {
+			remaining = left
+		}
+		if err == nil && taken {
+			continue
+		}
+		if rollbackErr := l.put(ctx, tags[:i], tokens); rollbackErr != nil {
+			err = errors.Join(err, fmt.Errorf("cannot return tokens: %w", rollbackErr))
+		}
+		if err != nil {
+			return 0, false, fmt.Errorf("%s %q: %w", l.levels[i].name, tag, err)
+		}
+		return remaining, false, nil
+	}
+	return remaining, true, nil
+}
+
+func (l *Limiter) put(ctx context.Context, tags []string, tokens float64) (err error) {
+	for i, tag := range tags {
+		if tag == "" {
+			continue // skipped
+		}
+		if levelErr := l.levels[i].limiter.Put(ctx, tag, tokens); levelErr != nil {
+			err = errors.Join(err, fmt.Errorf("%s %q: %w", l.levels[i].name, tag, levelErr))
+		}
+	}
+	return err
+}
+
+// Put returns tokens to every level.
+func (l *Limiter) Put(r *http.Request) error {
+	tags, err := l.Tags(r)
+	if err != nil {
+		return err
+	}
+	tokens, err := l.coster(r)
+	if err != nil {
+		return err
+	}
+	return l.put(r.Context(), tags, tokens)
+}
+
+// Delay returns the longest time it takes for the tokens to become available at every level.
+func (l *Limiter) Delay(r *http.Request) (delay time.Duration, err error) {
+	tags, err := l.Tags(r)
+	if err != nil {
+		return 0, err
+	}
+	tokens, err := l.coster(r)
+	if err != nil {
+		return 0, err
+	}
+	for i, tag := range tags {
+		if tag == "" {
+			continue // skipped
+		}
+		levelDelay, err := rate.Delay(r.Context(), l.levels[i].limiter, tag, tokens)
+		if err != nil {
+			return 0, err
+		}
+		delay = max(delay, levelDelay)
+	}
+	return delay, nil
+}
+
+// Explain reports the first level that does not have enough tokens for the request, like `organization "acme" ran out of tokens`.
+func (l *Limiter) Explain(r *http.Request) (reason string, retryAfter time.Duration) {
+	tags, err := l.Tags(r)
+	if err != nil {
+		return "", 0
+	}
+	tokens, err := l.coster(r)
+	if err != nil {
+		return "", 0
+	}
+	ctx := r.Context()
+	for i, tag := range tags {
+		if tag == "" {
+			continue // skipped
+		}
+		remaining, err := l.levels[i].limiter.Remaining(ctx, tag)
+		if err != nil || remaining >= tokens {
+			continue
+		}
+		retryAfter, _ = rate.Delay(ctx, l.levels[i].limiter, tag, tokens)
+		return fmt.Sprintf("%s %q ran out of tokens", l.levels[i].name, tag), retryAfter
+	}
+	return "", 0
+}
+
+// Remaining returns the tokens left at a level for a tag, like the quota shared by all users of an organization.
+func (l *Limiter) Remaining(ctx context.Context, levelName, tag string) (float64, error) {
+	i, err := l.level(levelName)
+	if err != nil {
+		return 0, err
+	}
+	return l.levels[i].limiter.Remaining(ctx, tag)
+}
+
+// Children lists the buckets of the level below whose parent is the given tag, like the consumption of each user of an organization. The level below must be a [rate.Inspector].
+func (l *Limiter) Children(ctx context.Context, levelName, tag string) ([]rate.Bucket, error) {
+	i, err := l.level(levelName)
+	if err != nil {
+		return nil, err
+	}
+	if i == 0 {
+		return nil, fmt.Errorf("level %q has no children", levelName)
+	}
+	inspector, ok := l.levels[i-1].limiter.(rate.Inspector)
+	if !ok {
+		return nil, fmt.Errorf("level %q cannot be inspected", l.levels[i-1].name)
+	}
+	buckets, err := inspector.Buckets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	children := make([]rate.Bucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		parent, err := l.levels[i].parent(ctx, bucket.Tag)
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve %s of %q: %w", levelName, bucket.Tag, err)
+		}
+		if parent == tag {
+			children = append(children, bucket)
+		}
+	}
+	return children, nil
+}
+
+func (l *Limiter) level(name string) (int, error) {
+	for i, level := range l.levels {
+		if level.name == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("level %q does not exist", name)
+}
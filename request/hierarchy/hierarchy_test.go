@@ -0,0 +1,121 @@
+package hierarchy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/driver/mutexrlm"
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+func newTestRateLimiter(t *testing.T, limit float64) rate.Limiter {
+	t.Helper()
+	l, err := mutexrlm.New(mutexrlm.WithNewRate(limit, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func newTestRequest(user string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-User", user)
+	return r
+}
+
+func TestHierarchy(t *testing.T) {
+	ctx := context.Background()
+	l, err := New(
+		WithTagger(func(r *http.Request) (string, error) {
+			return r.Header.Get("X-User"), nil
+		}),
+		WithLevel("user", newTestRateLimiter(t, 3)),
+		WithParentLevel("organization", newTestRateLimiter(t, 4), NewMapParentResolver(map[string]string{
+			"alice": "acme",
+			"bob":   "acme",
+		})),
+		WithGlobalLevel("global", newTestRateLimiter(t, 100)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, ok, err := l.Take(newTestRequest("alice")); err != nil || !ok {
+			t.Fatal("request was limited:", err)
+		}
+	}
+	if _, ok, err := l.Take(newTestRequest("alice")); err != nil || ok {
+		t.Fatal("user level did not reject:", err)
+	}
+	if reason, _ := l.Explain(newTestRequest("alice")); !strings.HasPrefix(reason, `user "alice"`) {
+		t.Fatal("unexpected reason:", reason)
+	}
+
+	if _, ok, err := l.Take(newTestRequest("bob")); err != nil || !ok {
+		t.Fatal("request was limited:", err)
+	}
+	if _, ok, err := l.Take(newTestRequest("bob")); err != nil || ok {
+		t.Fatal("organization level did not reject:", err)
+	}
+	reason, retryAfter := l.Explain(newTestRequest("bob"))
+	if reason != `organization "acme" ran out of tokens` || retryAfter <= 0 {
+		t.Fatal("unexpected reason:", reason, retryAfter)
+	}
+	remaining, err := l.Remaining(ctx, "user", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining < 1.9 {
+		t.Fatalf("rejected request took tokens from the user level: %f remaining", remaining)
+	}
+	if remaining, err = l.Remaining(ctx, "global", "global"); err != nil {
+		t.Fatal(err)
+	}
+	if remaining > 96.1 {
+		t.Fatalf("global level has %f tokens instead of 96", remaining)
+	}
+
+	children, err := l.Children(ctx, "organization", "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(children) != 2 || children[0].Tag != "alice" || children[1].Tag != "bob" {
+		t.Fatalf("unexpected organization members: %+v", children)
+	}
+
+	tags, err := l.Tags(newTestRequest("dave"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(tags, ",") != "dave,,global" {
+		t.Fatalf("user without organization resolved to %q", tags)
+	}
+	if _, ok, err := l.Take(newTestRequest("dave")); err != nil || !ok {
+		t.Fatal("request was limited:", err)
+	}
+}
+
+func TestHierarchyOptions(t *testing.T) {
+	l := newTestRateLimiter(t, 1)
+	if _, err := New(WithParentLevel("organization", l, NewMapParentResolver(nil))); err == nil {
+		t.Fatal("parent level was accepted before the first level")
+	}
+	if _, err := New(
+		WithTagger(func(r *http.Request) (string, error) { return "", nil }),
+		WithLevel("user", l),
+	); err == nil {
+		t.Fatal("single level was accepted")
+	}
+	if _, err := New(
+		WithTagger(func(r *http.Request) (string, error) { return "", nil }),
+		WithLevel("user", l),
+		WithGlobalLevel("user", l),
+	); err == nil {
+		t.Fatal("duplicate level was accepted")
+	}
+}
@@ -0,0 +1,109 @@
+package hierarchy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dkotik/oakratelimiter/rate"
+	"github.com/dkotik/oakratelimiter/request"
+)
+
+type options struct {
+	Tagger request.Tagger
+	Coster request.Coster
+	Levels []level
+}
+
+// Option configures the hierarchical request limiter.
+type Option func(*options) error
+
+// WithTagger sets the [request.Tagger] that determines the tag of the first level.
+func WithTagger(t request.Tagger) Option {
+	return func(o *options) error {
+		if t == nil {
+			return errors.New("cannot use a <nil> tagger")
+		}
+		if o.Tagger != nil {
+			return errors.New("tagger is already set")
+		}
+		o.Tagger = t
+		return nil
+	}
+}
+
+// WithCoster determines how many tokens each request takes from every level.
+func WithCoster(c request.Coster) Option {
+	return func(o *options) error {
+		if c == nil {
+			return errors.New("cannot use a <nil> coster")
+		}
+		if o.Coster != nil {
+			return errors.New("coster is already set")
+		}
+		o.Coster = c
+		return nil
+	}
+}
+
+// WithDefaultCoster takes one token per request.
+func WithDefaultCoster() Option {
+	return func(o *options) error {
+		if o.Coster != nil {
+			return nil // already set
+		}
+		return WithCoster(request.UnitCost)(o)
+	}
+}
+
+func withLevel(name string, l rate.Limiter, parent ParentResolver) Option {
+	return func(o *options) error {
+		if name == "" {
+			return errors.New("level name is required")
+		}
+		if l == nil {
+			return fmt.Errorf("cannot use a <nil> rate limiter for level %q", name)
+		}
+		for _, existing := range o.Levels {
+			if existing.name == name {
+				return fmt.Errorf("level %q is already set", name)
+			}
+		}
+		o.Levels = append(o.Levels, level{
+			name:    name,
+			limiter: l,
+			parent:  parent,
+		})
+		return nil
+	}
+}
+
+// WithLevel sets the first level, which takes tokens from the tag of the [request.Tagger], like a user.
+func WithLevel(name string, l rate.Limiter) Option {
+	return func(o *options) error {
+		if len(o.Levels) > 0 {
+			return errors.New("first level is already set")
+		}
+		return withLevel(name, l, nil)(o)
+	}
+}
+
+// WithParentLevel adds a level above the previous one, like an organization above its users. The [ParentResolver] receives the tag of the level below.
+func WithParentLevel(name string, l rate.Limiter, parent ParentResolver) Option {
+	return func(o *options) error {
+		if parent == nil {
+			return errors.New("cannot use a <nil> parent resolver")
+		}
+		if len(o.Levels) == 0 {
+			return errors.New("first level is required before parent levels")
+		}
+		return withLevel(name, l, parent)(o)
+	}
+}
+
+// WithGlobalLevel adds a level above the previous one that every request shares. Its tag is the level name.
+func WithGlobalLevel(name string, l rate.Limiter) Option {
+	return WithParentLevel(name, l, func(context.Context, string) (string, error) {
+		return name, nil
+	})
+}
@@ -0,0 +1,129 @@
+/*
+Package inflight implements [request.Limiter] that admits a request only if fewer than a certain number of requests are being served at the same time.
+
+Unlike rate limits, concurrency limits protect slow endpoints from exhausting server resources. The slot taken by a request is held until [oakratelimiter.RequestHandler] releases it after the next handler returns.
+*/
+package inflight
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/dkotik/oakratelimiter/rate"
+	"github.com/dkotik/oakratelimiter/request"
+)
+
+var ( // enforce interface compliance
+	_ request.Limiter        = (*ConcurrencyLimiter)(nil)
+	_ request.Releaser       = (*ConcurrencyLimiter)(nil)
+	_ request.TaggingLimiter = (*ConcurrencyLimiter)(nil)
+)
+
+// ConcurrencyLimiter counts the requests in flight for each tag. Without a [request.Tagger], all requests share the same count.
+type ConcurrencyLimiter struct {
+	limit  int
+	tagger request.Tagger
+
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+// New creates a [ConcurrencyLimiter] using [Option]s. [WithLimit] is required.
+func New(withOptions ...Option) (_ *ConcurrencyLimiter, err error) {
+	o := &options{}
+	for _, option := range append(
+		withOptions,
+		func(o *options) error {
+			if o.Limit == 0 {
+				return errors.New("concurrency limit is required")
+			}
+			return nil
+		},
+	) {
+		if err = option(o); err != nil {
+			return nil, fmt.Errorf("cannot initialize concurrency limiter: %w", err)
+		}
+	}
+
+	return &ConcurrencyLimiter{
+		limit:    o.Limit,
+		tagger:   o.Tagger,
+		inFlight: make(map[string]int),
+	}, nil
+}
+
+// Limit returns the maximum number of requests in flight for each tag.
+func (c *ConcurrencyLimiter) Limit() int {
+	return c.limit
+}
+
+// Rate returns <nil>, because concurrency is not limited over time.
+func (c *ConcurrencyLimiter) Rate() *rate.Rate {
+	return nil
+}
+
+// InFlight returns the number of requests being served for the tag of a given request.
+func (c *ConcurrencyLimiter) InFlight(r *http.Request) (int, error) {
+	tag, err := c.Tag(r)
+	if err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.inFlight[tag], nil
+}
+
+// Tag returns the tag a request is counted under. Without a [request.Tagger], all requests share an empty tag.
+func (c *ConcurrencyLimiter) Tag(r *http.Request) (string, error) {
+	if c.tagger == nil {
+		return "", nil
+	}
+	return c.tagger(r)
+}
+
+// Take occupies a slot, if one is available. Remaining tokens are the number of free slots.
+func (c *ConcurrencyLimiter) Take(r *http.Request) (
+	remaining float64,
+	ok bool,
+	err error,
+) {
+	tag, err := c.Tag(r)
+	if err != nil {
+		return 0, false, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := c.inFlight[tag]
+	if current >= c.limit {
+		return 0, false, nil
+	}
+	current++
+	c.inFlight[tag] = current
+	return float64(c.limit - current), true, nil
+}
+
+// Put frees the slot occupied by a request.
+func (c *ConcurrencyLimiter) Put(r *http.Request) error {
+	tag, err := c.Tag(r)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := c.inFlight[tag]
+	if current <= 1 {
+		delete(c.inFlight, tag)
+		return nil
+	}
+	c.inFlight[tag] = current - 1
+	return nil
+}
+
+// Release frees the slot occupied by a request after it was served.
+func (c *ConcurrencyLimiter) Release(r *http.Request) error {
+	return c.Put(r)
+}
@@ -0,0 +1,60 @@
+package inflight
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	l, err := New(
+		WithLimit(2),
+		WithTagger(func(r *http.Request) (string, error) {
+			return r.Header.Get("Authorization"), nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := func(tag string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/report", nil)
+		r.Header.Set("Authorization", tag)
+		return r
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, ok, err := l.Take(request("first")); err != nil || !ok {
+			t.Fatal("concurrency limiter blocked unexpectedly:", err)
+		}
+	}
+	remaining, ok, err := l.Take(request("first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok || remaining != 0 {
+		t.Fatal("concurrency limiter admitted too many requests")
+	}
+	if _, ok, err = l.Take(request("second")); err != nil || !ok {
+		t.Fatal("concurrency limiter blocked a different tag:", err)
+	}
+
+	if err = l.Release(request("first")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err = l.Take(request("first")); err != nil || !ok {
+		t.Fatal("released slot was not reused:", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err = l.Put(request("first")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, _ := l.InFlight(request("first")); n != 0 {
+		t.Fatal("slots went negative:", n)
+	}
+
+	if _, err = New(); err == nil {
+		t.Fatal("concurrency limiter without a limit was accepted")
+	}
+}
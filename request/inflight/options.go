@@ -0,0 +1,43 @@
+package inflight
+
+import (
+	"errors"
+
+	"github.com/dkotik/oakratelimiter/request"
+)
+
+type options struct {
+	Limit  int
+	Tagger request.Tagger
+}
+
+// Option configures the concurrency limiter.
+type Option func(*options) error
+
+// WithLimit sets the number of requests that may be in flight for each tag.
+func WithLimit(n int) Option {
+	return func(o *options) error {
+		if n < 1 {
+			return errors.New("concurrency limit must be greater than zero")
+		}
+		if o.Limit != 0 {
+			return errors.New("concurrency limit is already set")
+		}
+		o.Limit = n
+		return nil
+	}
+}
+
+// WithTagger counts requests in flight separately for each tag given by the [request.Tagger].
+func WithTagger(t request.Tagger) Option {
+	return func(o *options) error {
+		if t == nil {
+			return errors.New("cannot use a <nil> tagger")
+		}
+		if o.Tagger != nil {
+			return errors.New("tagger is already set")
+		}
+		o.Tagger = t
+		return nil
+	}
+}
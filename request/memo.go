@@ -0,0 +1,49 @@
+package request
+
+import (
+	"context"
+	"sync"
+)
+
+type memoContextKey struct{}
+
+// memo holds the values remembered by [Limiter]s while a single request is being served.
+type memo struct {
+	mu     sync.Mutex
+	values map[any]any
+}
+
+// NewMemoContext returns a [context.Context] that lets [Limiter]s remember what happened during Take until Put, Release, or Explain is called for the same request, like which of several rate limiters served it. [oakratelimiter.RequestHandler] attaches a memo to every request before consulting its limiters. If the context already carries a memo, it is returned unchanged.
+func NewMemoContext(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(memoContextKey{}).(*memo); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, memoContextKey{}, &memo{})
+}
+
+// Remember stores a value under a comparable key until the request is served. Use the [Limiter] itself or a struct containing it as the key to avoid collisions with other limiters. Returns false, if the context does not carry a memo created by [NewMemoContext].
+func Remember(ctx context.Context, key, value any) bool {
+	m, ok := ctx.Value(memoContextKey{}).(*memo)
+	if !ok {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.values == nil {
+		m.values = make(map[any]any)
+	}
+	m.values[key] = value
+	return true
+}
+
+// Recall returns the value stored by [Remember] under the key.
+func Recall(ctx context.Context, key any) (value any, ok bool) {
+	m, ok := ctx.Value(memoContextKey{}).(*memo)
+	if !ok {
+		return nil, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok = m.values[key]
+	return value, ok
+}
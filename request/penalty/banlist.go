@@ -0,0 +1,135 @@
+package penalty
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var ( // enforce interface compliance
+	_ BanList = (*MemoryBanList)(nil)
+	_ BanList = (*SQLBanList)(nil)
+)
+
+// Ban records the offenses of a tag. The tag is banned until the expiration time. The offenses are kept after the ban expires in order to escalate the next ban.
+type Ban struct {
+	Tag      string
+	Expires  time.Time
+	Offenses int
+}
+
+// Active returns true, if the tag is still banned at the given time.
+func (b Ban) Active(at time.Time) bool {
+	return at.Before(b.Expires)
+}
+
+// BanList keeps [Ban]s by tag.
+type BanList interface {
+	// Lookup returns the ban of a tag. A tag without a record has a zero [Ban].
+	Lookup(ctx context.Context, tag string) (Ban, error)
+	// Ban creates or replaces the ban of a tag.
+	Ban(context.Context, Ban) error
+}
+
+// MemoryBanList is a [BanList] for a single service instance. Records are kept until the retention period passes after their ban expires.
+type MemoryBanList struct {
+	retention time.Duration
+
+	mu    sync.Mutex
+	bans  map[string]Ban
+	swept time.Time
+}
+
+// NewMemoryBanList creates a [MemoryBanList]. The retention should not be shorter than the forgiveness period of the [Limiter], otherwise offenses are forgotten early.
+func NewMemoryBanList(retention time.Duration) *MemoryBanList {
+	return &MemoryBanList{
+		retention: retention,
+		bans:      make(map[string]Ban),
+		swept:     time.Now(),
+	}
+}
+
+func (m *MemoryBanList) Lookup(ctx context.Context, tag string) (Ban, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bans[tag], nil
+}
+
+// Ban stores the ban and occasionally removes records past retention.
+func (m *MemoryBanList) Ban(ctx context.Context, b Ban) error {
+	t := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bans[b.Tag] = b
+	if t.Sub(m.swept) < time.Minute {
+		return nil
+	}
+	m.swept = t
+	for tag, existing := range m.bans {
+		if existing.Expires.Add(m.retention).Before(t) {
+			delete(m.bans, tag)
+		}
+	}
+	return nil
+}
+
+// SQLBanList is a [BanList] stored in a database table, so that bans apply to every service instance. The queries work with both SQLite and Postgres.
+type SQLBanList struct {
+	lookupStmt *sql.Stmt
+	banStmt    *sql.Stmt
+}
+
+// NewSQLBanList creates the table, if it does not exist, and prepares the queries.
+func NewSQLBanList(db *sql.DB, table string) (_ *SQLBanList, err error) {
+	if db == nil {
+		return nil, errors.New("cannot use a <nil> database")
+	}
+	if !regexp.MustCompile(`^\w+$`).MatchString(table) {
+		return nil, fmt.Errorf("table name %q is invalid", table)
+	}
+	_, err = db.Exec(fmt.Sprintf(`
+    CREATE TABLE IF NOT EXISTS %q (
+      tag varchar(128) PRIMARY KEY,
+      expires bigint NOT NULL,
+      offenses integer NOT NULL
+    )`, table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create database table %q: %w", table, err)
+	}
+
+	l := &SQLBanList{}
+	l.lookupStmt, err = db.Prepare(fmt.Sprintf(`SELECT expires, offenses FROM %q WHERE tag=$1`, table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare lookup statement: %w", err)
+	}
+	l.banStmt, err = db.Prepare(fmt.Sprintf(`
+    INSERT INTO %q(tag, expires, offenses) VALUES($1, $2, $3)
+    ON CONFLICT (tag) DO UPDATE SET expires=excluded.expires, offenses=excluded.offenses`, table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare ban statement: %w", err)
+	}
+	return l, nil
+}
+
+func (l *SQLBanList) Lookup(ctx context.Context, tag string) (b Ban, err error) {
+	var expires int64
+	err = l.lookupStmt.QueryRowContext(ctx, tag).Scan(&expires, &b.Offenses)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Ban{}, nil
+	}
+	if err != nil {
+		return Ban{}, err
+	}
+	b.Tag = tag
+	b.Expires = time.UnixMicro(expires)
+	return b, nil
+}
+
+func (l *SQLBanList) Ban(ctx context.Context, b Ban) error {
+	_, err := l.banStmt.ExecContext(ctx, b.Tag, b.Expires.UnixMicro(), b.Offenses)
+	return err
+}
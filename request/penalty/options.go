@@ -0,0 +1,148 @@
+package penalty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/rate"
+)
+
+type options struct {
+	BanList        BanList
+	Threshold      *rate.Rate
+	Rejections     rate.Limiter
+	Escalation     []time.Duration
+	Forgiveness    time.Duration
+	CleanupContext context.Context
+}
+
+// Option configures the penalty box.
+type Option func(*options) error
+
+// WithBanList sets the [BanList] that keeps track of banned tags and their offenses.
+func WithBanList(l BanList) Option {
+	return func(o *options) error {
+		if l == nil {
+			return errors.New("cannot use a <nil> ban list")
+		}
+		if o.BanList != nil {
+			return errors.New("ban list is already set")
+		}
+		o.BanList = l
+		return nil
+	}
+}
+
+// WithDefaultBanList keeps bans in memory using [NewMemoryBanList].
+func WithDefaultBanList() Option {
+	return func(o *options) error {
+		if o.BanList != nil {
+			return nil // already set
+		}
+		return WithBanList(NewMemoryBanList(o.Forgiveness))(o)
+	}
+}
+
+// WithThreshold bans a tag that is rejected more than the given number of times within the window.
+func WithThreshold(rejections float64, window time.Duration) Option {
+	return func(o *options) error {
+		if o.Threshold != nil {
+			return errors.New("rejection threshold is already set")
+		}
+		r, err := rate.New(rejections, window)
+		if err != nil {
+			return fmt.Errorf("invalid rejection threshold: %w", err)
+		}
+		o.Threshold = r
+		return nil
+	}
+}
+
+// WithDefaultThreshold bans a tag that is rejected more than ten times within a minute.
+func WithDefaultThreshold() Option {
+	return func(o *options) error {
+		if o.Threshold != nil {
+			return nil // already set
+		}
+		return WithThreshold(10, time.Minute)(o)
+	}
+}
+
+// WithCleanupContext provides the [context.Context] for garbage collection of the in-memory rejection counter. When the context is cancelled, garbage collection stops.
+func WithCleanupContext(ctx context.Context) Option {
+	return func(o *options) error {
+		if ctx == nil {
+			return errors.New("cannot use a <nil> clean up context")
+		}
+		if o.CleanupContext != nil {
+			return errors.New("clean up context is already set")
+		}
+		o.CleanupContext = ctx
+		return nil
+	}
+}
+
+// WithDefaultCleanupContext passes [context.Background] to [WithCleanupContext] option.
+func WithDefaultCleanupContext() Option {
+	return func(o *options) error {
+		if o.CleanupContext != nil {
+			return nil // already set
+		}
+		return WithCleanupContext(context.Background())(o)
+	}
+}
+
+// WithEscalation sets the ban duration for each consecutive offense. The last duration applies to every following offense.
+func WithEscalation(durations ...time.Duration) Option {
+	return func(o *options) error {
+		if len(durations) == 0 {
+			return errors.New("cannot use an empty list of ban durations")
+		}
+		for _, d := range durations {
+			if d < time.Second {
+				return errors.New("ban duration must be at least one second")
+			}
+		}
+		if o.Escalation != nil {
+			return errors.New("ban escalation is already set")
+		}
+		o.Escalation = durations
+		return nil
+	}
+}
+
+// WithDefaultEscalation bans a tag for one minute, then ten minutes, then an hour.
+func WithDefaultEscalation() Option {
+	return func(o *options) error {
+		if o.Escalation != nil {
+			return nil // already set
+		}
+		return WithEscalation(time.Minute, time.Minute*10, time.Hour)(o)
+	}
+}
+
+// WithForgiveness sets how long after the last ban expires the offenses of a tag are forgotten.
+func WithForgiveness(after time.Duration) Option {
+	return func(o *options) error {
+		if after <= 0 {
+			return errors.New("forgiveness period must be greater than zero")
+		}
+		if o.Forgiveness != 0 {
+			return errors.New("forgiveness period is already set")
+		}
+		o.Forgiveness = after
+		return nil
+	}
+}
+
+// WithDefaultForgiveness forgets offenses a day after the last ban expires.
+func WithDefaultForgiveness() Option {
+	return func(o *options) error {
+		if o.Forgiveness != 0 {
+			return nil // already set
+		}
+		return WithForgiveness(time.Hour * 24)(o)
+	}
+}
@@ -0,0 +1,201 @@
+/*
+Package penalty bans request tags that keep getting rejected, so that an attacker who hammers an endpoint does not get a trickle of requests through as tokens refill.
+
+A [Limiter] counts the rejections of the wrapped [request.Limiter] per tag. After too many rejections within a window, the tag is banned for an escalating duration. Requests from a banned tag are rejected without calling the wrapped limiter. Bans are kept in a [BanList], which can be shared between service instances using [NewSQLBanList].
+*/
+package penalty
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/driver/mutexrlm"
+	"github.com/dkotik/oakratelimiter/rate"
+	"github.com/dkotik/oakratelimiter/request"
+)
+
+var ( // enforce interface compliance
+	_ request.Limiter        = (*Limiter)(nil)
+	_ request.Delayer        = (*Limiter)(nil)
+	_ request.TaggingLimiter = (*Limiter)(nil)
+	_ request.Explainer      = (*Limiter)(nil)
+	_ request.CostingLimiter = (*Limiter)(nil)
+	_ request.Rater          = (*Limiter)(nil)
+)
+
+// Limiter bans the tags of a [request.Limiter] after repeated rejections.
+type Limiter struct {
+	limiter     request.Limiter
+	bans        BanList
+	rejections  rate.Limiter
+	escalation  []time.Duration
+	forgiveness time.Duration
+}
+
+// New wraps a [request.Limiter] that must be a [request.TaggingLimiter]. Without options, a tag that is rejected more than ten times within a minute is banned for one minute, then ten minutes, then an hour for every following offense. Offenses are forgotten a day after the last ban expires. Bans are kept in memory. The in-memory rejection counter is cleaned up in the background until the context given to [WithCleanupContext] is cancelled.
+func New(l request.Limiter, withOptions ...Option) (_ *Limiter, err error) {
+	if l == nil {
+		return nil, errors.New("cannot use a <nil> request limiter")
+	}
+	if _, ok := l.(request.TaggingLimiter); !ok {
+		return nil, errors.New("cannot ban tags of a request limiter that does not report them")
+	}
+	if _, ok := l.(request.Releaser); ok {
+		return nil, errors.New("cannot ban tags of a request limiter that releases its tokens")
+	}
+	o := &options{}
+	for _, option := range append(
+		withOptions,
+		WithDefaultThreshold(),
+		WithDefaultEscalation(),
+		WithDefaultForgiveness(),
+		WithDefaultBanList(),
+		WithDefaultCleanupContext(),
+		func(o *options) (err error) { // rejection counter
+			o.Rejections, err = mutexrlm.New(
+				mutexrlm.WithRate(o.Threshold),
+				mutexrlm.WithCleanupContext(o.CleanupContext),
+			)
+			if err != nil {
+				return fmt.Errorf("cannot create rejection counter: %w", err)
+			}
+			return nil
+		},
+	) {
+		if err = option(o); err != nil {
+			return nil, fmt.Errorf("cannot initialize penalty box: %w", err)
+		}
+	}
+	return &Limiter{
+		limiter:     l,
+		bans:        o.BanList,
+		rejections:  o.Rejections,
+		escalation:  o.Escalation,
+		forgiveness: o.Forgiveness,
+	}, nil
+}
+
+func (p *Limiter) Rate() *rate.Rate {
+	return p.limiter.Rate()
+}
+
+func (p *Limiter) Tag(r *http.Request) (string, error) {
+	return request.Tag(p.limiter, r)
+}
+
+func (p *Limiter) RequestRate(r *http.Request) (*rate.Rate, float64, error) {
+	return request.RequestRate(p.limiter, r)
+}
+
+func (p *Limiter) Cost(r *http.Request) (float64, error) {
+	return request.Cost(p.limiter, r)
+}
+
+// Take rejects requests from banned tags without calling the wrapped limiter. Otherwise, the rejections of the wrapped limiter are counted and the tag is banned once there are too many.
+func (p *Limiter) Take(r *http.Request) (
+	remaining float64,
+	ok bool,
+	err error,
+) {
+	tag, err := p.Tag(r)
+	if err != nil {
+		return 0, false, err
+	}
+	ctx := r.Context()
+	t := time.Now()
+	ban, err := p.bans.Lookup(ctx, tag)
+	if err != nil {
+		return 0, false, fmt.Errorf("cannot look up ban: %w", err)
+	}
+	request.Remember(ctx, p, ban)
+	if ban.Active(t) {
+		return 0, false, nil
+	}
+
+	remaining, ok, err = p.limiter.Take(r)
+	if err != nil || ok {
+		return remaining, ok, err
+	}
+	_, tolerated, err := p.rejections.Take(ctx, tag, 1)
+	if err != nil {
+		return remaining, false, fmt.Errorf("cannot count rejections: %w", err)
+	}
+	if tolerated {
+		return remaining, false, nil
+	}
+
+	offenses := ban.Offenses
+	if ban.Expires.Add(p.forgiveness).Before(t) {
+		offenses = 0 // forgiven
+	}
+	duration := p.escalation[min(offenses, len(p.escalation)-1)]
+	ban = Ban{
+		Tag:      tag,
+		Expires:  t.Add(duration),
+		Offenses: offenses + 1,
+	}
+	if err = p.bans.Ban(ctx, ban); err != nil {
+		return remaining, false, fmt.Errorf("cannot ban tag: %w", err)
+	}
+	request.Remember(ctx, p, ban)
+	slog.Log(
+		ctx,
+		slog.LevelWarn,
+		"rate limiter banned tag",
+		slog.String("tag", tag),
+		slog.Duration("duration", duration),
+		slog.Int("offenses", ban.Offenses),
+	)
+	return remaining, false, nil
+}
+
+// Delay returns the time until the ban expires for banned tags.
+func (p *Limiter) Delay(r *http.Request) (time.Duration, error) {
+	tag, err := p.Tag(r)
+	if err != nil {
+		return 0, err
+	}
+	t := time.Now()
+	ban, err := p.bans.Lookup(r.Context(), tag)
+	if err != nil {
+		return 0, fmt.Errorf("cannot look up ban: %w", err)
+	}
+	if ban.Active(t) {
+		return ban.Expires.Sub(t), nil
+	}
+	return request.Delay(p.limiter, r)
+}
+
+func (p *Limiter) Put(r *http.Request) error {
+	return p.limiter.Put(r)
+}
+
+// Explain reports an active ban of the request tag. The ban found or imposed by [Limiter.Take] is reused without looking it up again, if the request carries a memo created by [request.NewMemoContext].
+func (p *Limiter) Explain(r *http.Request) (reason string, retryAfter time.Duration) {
+	ban, ok := p.recall(r)
+	t := time.Now()
+	if !ok || !ban.Active(t) {
+		return request.Explain(p.limiter, r)
+	}
+	return fmt.Sprintf("temporarily banned, offense %d", ban.Offenses), ban.Expires.Sub(t)
+}
+
+// recall returns the ban remembered by [Limiter.Take] or looks it up.
+func (p *Limiter) recall(r *http.Request) (Ban, bool) {
+	if value, ok := request.Recall(r.Context(), p); ok {
+		ban, ok := value.(Ban)
+		return ban, ok
+	}
+	tag, err := p.Tag(r)
+	if err != nil {
+		return Ban{}, false
+	}
+	ban, err := p.bans.Lookup(r.Context(), tag)
+	if err != nil {
+		return Ban{}, false
+	}
+	return ban, true
+}
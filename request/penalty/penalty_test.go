@@ -0,0 +1,117 @@
+package penalty
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/driver/mutexrlm"
+	"github.com/dkotik/oakratelimiter/request"
+)
+
+func newTestLimiter(t *testing.T) request.Limiter {
+	t.Helper()
+	driver, err := mutexrlm.New(mutexrlm.WithNewRate(1, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := request.NewLimiter(func(r *http.Request) (string, error) {
+		return r.Header.Get("X-Client"), nil
+	}, driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestPenaltyBox(t *testing.T) {
+	bans := NewMemoryBanList(time.Hour)
+	l, err := New(
+		newTestLimiter(t),
+		WithBanList(bans),
+		WithThreshold(2, time.Hour),
+		WithEscalation(time.Minute, time.Minute*10),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Client", "attacker")
+
+	if _, ok, err := l.Take(r); !ok || err != nil {
+		t.Fatal("first request was rejected:", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, ok, err := l.Take(r); ok || err != nil {
+			t.Fatal("request was not rejected:", err)
+		}
+		if reason, _ := l.Explain(r); reason != "" && i < 2 {
+			t.Fatal("tag was banned too early:", reason)
+		}
+	}
+
+	reason, retryAfter := l.Explain(r)
+	if reason == "" || retryAfter < time.Second*59 || retryAfter > time.Minute {
+		t.Fatalf("tag was not banned for a minute: %q %s", reason, retryAfter)
+	}
+	if delay, err := l.Delay(r); err != nil || delay < time.Second*59 || delay > time.Minute {
+		t.Fatal("delay does not match the ban:", delay, err)
+	}
+
+	other := httptest.NewRequest(http.MethodGet, "/", nil)
+	other.Header.Set("X-Client", "customer")
+	if _, ok, err := l.Take(other); !ok || err != nil {
+		t.Fatal("ban applied to another tag:", err)
+	}
+
+	// expire the ban and offend again to escalate
+	ban, _ := bans.Lookup(context.Background(), "attacker")
+	ban.Expires = time.Now().Add(-time.Second)
+	if err = bans.Ban(context.Background(), ban); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := l.Take(r); ok || err != nil {
+		t.Fatal("request was not rejected:", err)
+	}
+	if _, retryAfter = l.Explain(r); retryAfter < time.Minute*9 {
+		t.Fatal("ban did not escalate:", retryAfter)
+	}
+	if ban, _ = bans.Lookup(context.Background(), "attacker"); ban.Offenses != 2 {
+		t.Fatal("offenses were not counted:", ban.Offenses)
+	}
+}
+
+// countingBanList counts lookups.
+type countingBanList struct {
+	BanList
+	lookups int
+}
+
+func (c *countingBanList) Lookup(ctx context.Context, tag string) (Ban, error) {
+	c.lookups++
+	return c.BanList.Lookup(ctx, tag)
+}
+
+func TestExplainRecallsBan(t *testing.T) {
+	bans := &countingBanList{BanList: NewMemoryBanList(time.Hour)}
+	l, err := New(newTestLimiter(t), WithBanList(bans), WithThreshold(1, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(request.NewMemoContext(r.Context()))
+	r.Header.Set("X-Client", "attacker")
+	for i := 0; i < 3; i++ {
+		_, _, _ = l.Take(r)
+	}
+
+	lookups := bans.lookups
+	if reason, _ := l.Explain(r); reason == "" {
+		t.Fatal("ban imposed by take was not explained")
+	}
+	if bans.lookups != lookups {
+		t.Fatal("explain looked up the ban again")
+	}
+}
@@ -6,10 +6,18 @@ package request
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/dkotik/oakratelimiter/rate"
 )
 
+// ErrUnknownDelay indicates that a [Limiter] cannot tell how long it takes until a request would be allowed.
+var ErrUnknownDelay = errors.New("request limiter delay is unknown")
+
+// ErrUnknownTag indicates that a [Limiter] cannot tell which tag a request is counted under.
+var ErrUnknownTag = errors.New("request limiter tag is unknown")
+
+// Limiter takes tokens for each [http.Request]. Tokens taken by a request can be returned using Put, which lets [oakratelimiter.RequestHandler] refund a request that was rejected by another [Limiter].
 type Limiter interface {
 	Rate() *rate.Rate
 	Take(
@@ -19,60 +27,205 @@ type Limiter interface {
 		ok bool,
 		err error,
 	)
+	Put(*http.Request) error
+}
+
+// Releaser is a [Limiter] that holds tokens only while the request is being served, like a concurrency limit. [oakratelimiter.RequestHandler] calls Release after the next handler returns or panics.
+type Releaser interface {
+	Release(*http.Request) error
+}
+
+// Delayer is a [Limiter] that can tell how long it takes until the tokens for a request become available.
+type Delayer interface {
+	Delay(*http.Request) (time.Duration, error)
+}
+
+// Delay returns the time it takes until the tokens for a request become available. If the [Limiter] is not a [Delayer], returns [ErrUnknownDelay].
+func Delay(l Limiter, r *http.Request) (time.Duration, error) {
+	if delayer, ok := l.(Delayer); ok {
+		return delayer.Delay(r)
+	}
+	return 0, ErrUnknownDelay
+}
+
+// TaggingLimiter is a [Limiter] that can tell which tag a request is counted under.
+type TaggingLimiter interface {
+	Tag(*http.Request) (string, error)
+}
+
+// Tag returns the tag a request is counted under. If the [Limiter] is not a [TaggingLimiter], returns [ErrUnknownTag].
+func Tag(l Limiter, r *http.Request) (string, error) {
+	if tagger, ok := l.(TaggingLimiter); ok {
+		return tagger.Tag(r)
+	}
+	return "", ErrUnknownTag
+}
+
+// Rater is a [Limiter] that can tell the [rate.Rate] and the burst limit applied to a request, when they differ from [Limiter.Rate] and its [rate.Rate.Burst]. See [rate.TagRater].
+type Rater interface {
+	RequestRate(*http.Request) (r *rate.Rate, burstLimit float64, err error)
+}
+
+// RequestRate returns the [rate.Rate] and the burst limit applied to a request. If the [Limiter] is not a [Rater], returns [Limiter.Rate] and its [rate.Rate.Burst]. Limiters without a rate, like concurrency limits, return <nil>.
+func RequestRate(l Limiter, r *http.Request) (*rate.Rate, float64, error) {
+	if rater, ok := l.(Rater); ok {
+		return rater.RequestRate(r)
+	}
+	limiterRate := l.Rate()
+	if limiterRate == nil {
+		return nil, 0, nil
+	}
+	return limiterRate, limiterRate.Burst(), nil
 }
 
+// CostingLimiter is a [Limiter] that can tell how many tokens a request takes, when it is weighted by a [Coster].
+type CostingLimiter interface {
+	Cost(*http.Request) (float64, error)
+}
+
+// Cost returns the number of tokens a request takes. If the [Limiter] is not a [CostingLimiter], the request takes one token.
+func Cost(l Limiter, r *http.Request) (float64, error) {
+	if coster, ok := l.(CostingLimiter); ok {
+		return coster.Cost(r)
+	}
+	return 1, nil
+}
+
+// Explainer is a [Limiter] that can tell why it rejected a request, when running out of tokens is not the whole story, like a temporary ban.
+type Explainer interface {
+	Explain(*http.Request) (reason string, retryAfter time.Duration)
+}
+
+// Explain returns the reason a request was rejected and how long until it may be retried. If the [Limiter] is not an [Explainer] or has nothing to add, the reason is empty.
+func Explain(l Limiter, r *http.Request) (reason string, retryAfter time.Duration) {
+	if explainer, ok := l.(Explainer); ok {
+		return explainer.Explain(r)
+	}
+	return "", 0
+}
+
+// NewStaticLimiter creates a [Limiter] that always takes one token per request from the same tag.
 func NewStaticLimiter(tag string, l rate.Limiter) (Limiter, error) {
+	return NewWeightedStaticLimiter(tag, l, UnitCost)
+}
+
+// NewWeightedStaticLimiter creates a [Limiter] that always takes tokens from the same tag. The number of tokens is determined by the [Coster].
+func NewWeightedStaticLimiter(tag string, l rate.Limiter, c Coster) (Limiter, error) {
 	if tag == "" {
 		return nil, errors.New("cannot use an empty tag")
 	}
 	if l == nil {
 		return nil, errors.New("cannot use a <nil> rate limiter")
 	}
+	if c == nil {
+		return nil, errors.New("cannot use a <nil> coster")
+	}
 	return &staticLimiter{
 		tag:     tag,
 		limiter: l,
+		coster:  c,
 	}, nil
 }
 
 type staticLimiter struct {
 	tag     string
 	limiter rate.Limiter
+	coster  Coster
 }
 
 func (s *staticLimiter) Rate() *rate.Rate {
 	return s.limiter.Rate()
 }
 
+func (s *staticLimiter) Tag(*http.Request) (string, error) {
+	return s.tag, nil
+}
+
+func (s *staticLimiter) RequestRate(r *http.Request) (*rate.Rate, float64, error) {
+	return rate.TagRate(r.Context(), s.limiter, s.tag)
+}
+
+func (s *staticLimiter) Cost(r *http.Request) (float64, error) {
+	return s.coster(r)
+}
+
 func (s *staticLimiter) Take(r *http.Request) (
 	remaining float64,
 	ok bool,
 	err error,
 ) {
-	return s.limiter.Take(r.Context(), s.tag, 1.0)
+	tokens, err := s.coster(r)
+	if err != nil {
+		return 0, false, err
+	}
+	return s.limiter.Take(r.Context(), s.tag, tokens)
+}
+
+func (s *staticLimiter) Delay(r *http.Request) (time.Duration, error) {
+	tokens, err := s.coster(r)
+	if err != nil {
+		return 0, err
+	}
+	return rate.Delay(r.Context(), s.limiter, s.tag, tokens)
+}
+
+func (s *staticLimiter) Put(r *http.Request) error {
+	tokens, err := s.coster(r)
+	if err != nil {
+		return err
+	}
+	return s.limiter.Put(r.Context(), s.tag, tokens)
 }
 
+// NewLimiter creates a [Limiter] that takes one token per request from the tag determined by the [Tagger].
 func NewLimiter(t Tagger, l rate.Limiter) (Limiter, error) {
+	return NewWeightedLimiter(t, l, UnitCost)
+}
+
+// NewWeightedLimiter creates a [Limiter] that takes tokens from the tag determined by the [Tagger]. The number of tokens is determined by the [Coster].
+func NewWeightedLimiter(t Tagger, l rate.Limiter, c Coster) (Limiter, error) {
 	if t == nil {
 		return nil, errors.New("cannot use a <nil> tagger")
 	}
 	if l == nil {
 		return nil, errors.New("cannot use a <nil> rate limiter")
 	}
+	if c == nil {
+		return nil, errors.New("cannot use a <nil> coster")
+	}
 	return &taggingRequestLimiter{
 		tagger:  t,
 		limiter: l,
+		coster:  c,
 	}, nil
 }
 
 type taggingRequestLimiter struct {
 	tagger  Tagger
 	limiter rate.Limiter
+	coster  Coster
 }
 
 func (t *taggingRequestLimiter) Rate() *rate.Rate {
 	return t.limiter.Rate()
 }
 
+func (t *taggingRequestLimiter) Tag(r *http.Request) (string, error) {
+	return t.tagger(r)
+}
+
+func (t *taggingRequestLimiter) RequestRate(r *http.Request) (*rate.Rate, float64, error) {
+	tag, err := t.tagger(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rate.TagRate(r.Context(), t.limiter, tag)
+}
+
+func (t *taggingRequestLimiter) Cost(r *http.Request) (float64, error) {
+	return t.coster(r)
+}
+
 func (t *taggingRequestLimiter) Take(r *http.Request) (
 	remaining float64,
 	ok bool,
@@ -82,5 +235,33 @@ func (t *taggingRequestLimiter) Take(r *http.Request) (
 	if err != nil {
 		return
 	}
-	return t.limiter.Take(r.Context(), tag, 1.0)
+	tokens, err := t.coster(r)
+	if err != nil {
+		return 0, false, err
+	}
+	return t.limiter.Take(r.Context(), tag, tokens)
+}
+
+func (t *taggingRequestLimiter) Delay(r *http.Request) (time.Duration, error) {
+	tag, err := t.tagger(r)
+	if err != nil {
+		return 0, err
+	}
+	tokens, err := t.coster(r)
+	if err != nil {
+		return 0, err
+	}
+	return rate.Delay(r.Context(), t.limiter, tag, tokens)
+}
+
+func (t *taggingRequestLimiter) Put(r *http.Request) error {
+	tag, err := t.tagger(r)
+	if err != nil {
+		return err
+	}
+	tokens, err := t.coster(r)
+	if err != nil {
+		return err
+	}
+	return t.limiter.Put(r.Context(), tag, tokens)
 }
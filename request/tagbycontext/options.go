@@ -14,6 +14,7 @@ type options struct {
 	Key     any
 	Rate    *rate.Rate
 	Limiter rate.Limiter
+	Coster  request.Coster
 	NoValue request.Limiter
 }
 
@@ -92,7 +93,11 @@ func WithNoValueLimiter(l request.Limiter) Option {
 
 func WithNoValueRateLimiter(tag string, l rate.Limiter) Option {
 	return func(o *options) error {
-		l, err := request.NewStaticLimiter(tag, l)
+		c := o.Coster
+		if c == nil {
+			c = request.UnitCost
+		}
+		l, err := request.NewWeightedStaticLimiter(tag, l, c)
 		if err != nil {
 			return fmt.Errorf("cannot initialize static request limiter: %w", err)
 		}
@@ -133,3 +138,25 @@ func WithDefaultNoValueLimiter() Option {
 		)(o)
 	}
 }
+
+func WithCoster(c request.Coster) Option {
+	return func(o *options) error {
+		if c == nil {
+			return errors.New("cannot use a <nil> coster")
+		}
+		if o.Coster != nil {
+			return errors.New("coster is already set")
+		}
+		o.Coster = c
+		return nil
+	}
+}
+
+func WithDefaultCoster() Option {
+	return func(o *options) error {
+		if o.Coster != nil {
+			return nil // already set
+		}
+		return WithCoster(request.UnitCost)(o)
+	}
+}
@@ -6,6 +6,7 @@ package tagbycontext
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/dkotik/oakratelimiter/rate"
 	"github.com/dkotik/oakratelimiter/request"
@@ -15,6 +16,7 @@ import (
 type ContextLimiter struct {
 	key     any
 	limiter rate.Limiter
+	coster  request.Coster
 	noValue request.Limiter
 }
 
@@ -22,6 +24,7 @@ func New(withOptions ...Option) (_ *ContextLimiter, err error) {
 	o := &options{}
 	for _, option := range append(
 		withOptions,
+		WithDefaultCoster(),
 		WithDefaultNoValueLimiter(),
 	) {
 		if err = option(o); err != nil {
@@ -31,6 +34,7 @@ func New(withOptions ...Option) (_ *ContextLimiter, err error) {
 	return &ContextLimiter{
 		key:     o.Key,
 		limiter: o.Limiter,
+		coster:  o.Coster,
 		noValue: o.NoValue,
 	}, nil
 }
@@ -48,6 +52,30 @@ func (c *ContextLimiter) Rate() *rate.Rate {
 	return noValueRate
 }
 
+func (c *ContextLimiter) Tag(r *http.Request) (string, error) {
+	value := r.Context().Value(c.key)
+	if value == nil {
+		return request.Tag(c.noValue, r)
+	}
+	return fmt.Sprintf("%v", value), nil
+}
+
+func (c *ContextLimiter) RequestRate(r *http.Request) (*rate.Rate, float64, error) {
+	ctx := r.Context()
+	value := ctx.Value(c.key)
+	if value == nil {
+		return request.RequestRate(c.noValue, r)
+	}
+	return rate.TagRate(ctx, c.limiter, fmt.Sprintf("%v", value))
+}
+
+func (c *ContextLimiter) Cost(r *http.Request) (float64, error) {
+	if r.Context().Value(c.key) == nil {
+		return request.Cost(c.noValue, r)
+	}
+	return c.coster(r)
+}
+
 func (c *ContextLimiter) Take(
 	r *http.Request,
 ) (
@@ -60,5 +88,35 @@ func (c *ContextLimiter) Take(
 	if value == nil {
 		return c.noValue.Take(r)
 	}
-	return c.limiter.Take(ctx, fmt.Sprintf("%v", value), 1.0)
+	tokens, err := c.coster(r)
+	if err != nil {
+		return 0, false, err
+	}
+	return c.limiter.Take(ctx, fmt.Sprintf("%v", value), tokens)
+}
+
+func (c *ContextLimiter) Delay(r *http.Request) (time.Duration, error) {
+	ctx := r.Context()
+	value := ctx.Value(c.key)
+	if value == nil {
+		return request.Delay(c.noValue, r)
+	}
+	tokens, err := c.coster(r)
+	if err != nil {
+		return 0, err
+	}
+	return rate.Delay(ctx, c.limiter, fmt.Sprintf("%v", value), tokens)
+}
+
+func (c *ContextLimiter) Put(r *http.Request) error {
+	ctx := r.Context()
+	value := ctx.Value(c.key)
+	if value == nil {
+		return c.noValue.Put(r)
+	}
+	tokens, err := c.coster(r)
+	if err != nil {
+		return err
+	}
+	return c.limiter.Put(ctx, fmt.Sprintf("%v", value), tokens)
 }
@@ -15,6 +15,7 @@ type options struct {
 	Name     string
 	Rate     *rate.Rate
 	Limiter  rate.Limiter
+	Coster   request.Coster
 	NoCookie request.Limiter
 }
 
@@ -96,7 +97,11 @@ func WithNoCookieLimiter(l request.Limiter) Option {
 
 func WithNoCookieRateLimiter(tag string, l rate.Limiter) Option {
 	return func(o *options) error {
-		l, err := request.NewStaticLimiter(tag, l)
+		c := o.Coster
+		if c == nil {
+			c = request.UnitCost
+		}
+		l, err := request.NewWeightedStaticLimiter(tag, l, c)
 		if err != nil {
 			return fmt.Errorf("cannot initialize static request limiter: %w", err)
 		}
@@ -137,3 +142,25 @@ func WithDefaultNoCookieLimiter() Option {
 		)(o)
 	}
 }
+
+func WithCoster(c request.Coster) Option {
+	return func(o *options) error {
+		if c == nil {
+			return errors.New("cannot use a <nil> coster")
+		}
+		if o.Coster != nil {
+			return errors.New("coster is already set")
+		}
+		o.Coster = c
+		return nil
+	}
+}
+
+func WithDefaultCoster() Option {
+	return func(o *options) error {
+		if o.Coster != nil {
+			return nil // already set
+		}
+		return WithCoster(request.UnitCost)(o)
+	}
+}
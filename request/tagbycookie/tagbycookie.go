@@ -6,6 +6,7 @@ package tagbycookie
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/dkotik/oakratelimiter/rate"
 	"github.com/dkotik/oakratelimiter/request"
@@ -14,6 +15,7 @@ import (
 type CookieLimiter struct {
 	name     string
 	limiter  rate.Limiter
+	coster   request.Coster
 	noCookie request.Limiter
 }
 
@@ -21,6 +23,7 @@ func New(withOptions ...Option) (_ *CookieLimiter, err error) {
 	o := &options{}
 	for _, option := range append(
 		withOptions,
+		WithDefaultCoster(),
 		WithDefaultNoCookieLimiter(),
 	) {
 		if err = option(o); err != nil {
@@ -30,6 +33,7 @@ func New(withOptions ...Option) (_ *CookieLimiter, err error) {
 	return &CookieLimiter{
 		name:     o.Name,
 		limiter:  o.Limiter,
+		coster:   o.Coster,
 		noCookie: o.NoCookie,
 	}, nil
 }
@@ -47,6 +51,39 @@ func (c *CookieLimiter) Rate() *rate.Rate {
 	return noCookieRate
 }
 
+func (c *CookieLimiter) Tag(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(c.name)
+	switch {
+	case cookie == nil || cookie.Value == "":
+		return request.Tag(c.noCookie, r)
+	case err != nil:
+		return "", err
+	}
+	return cookie.Value, nil
+}
+
+func (c *CookieLimiter) RequestRate(r *http.Request) (*rate.Rate, float64, error) {
+	cookie, err := r.Cookie(c.name)
+	switch {
+	case cookie == nil || cookie.Value == "":
+		return request.RequestRate(c.noCookie, r)
+	case err != nil:
+		return nil, 0, err
+	}
+	return rate.TagRate(r.Context(), c.limiter, cookie.Value)
+}
+
+func (c *CookieLimiter) Cost(r *http.Request) (float64, error) {
+	cookie, err := r.Cookie(c.name)
+	switch {
+	case cookie == nil || cookie.Value == "":
+		return request.Cost(c.noCookie, r)
+	case err != nil:
+		return 0, err
+	}
+	return c.coster(r)
+}
+
 func (c *CookieLimiter) Take(
 	r *http.Request,
 ) (
@@ -61,5 +98,39 @@ func (c *CookieLimiter) Take(
 	case err != nil:
 		return
 	}
-	return c.limiter.Take(r.Context(), cookie.Value, 1.0)
+	tokens, err := c.coster(r)
+	if err != nil {
+		return 0, false, err
+	}
+	return c.limiter.Take(r.Context(), cookie.Value, tokens)
+}
+
+func (c *CookieLimiter) Delay(r *http.Request) (time.Duration, error) {
+	cookie, err := r.Cookie(c.name)
+	switch {
+	case cookie == nil || cookie.Value == "":
+		return request.Delay(c.noCookie, r)
+	case err != nil:
+		return 0, err
+	}
+	tokens, err := c.coster(r)
+	if err != nil {
+		return 0, err
+	}
+	return rate.Delay(r.Context(), c.limiter, cookie.Value, tokens)
+}
+
+func (c *CookieLimiter) Put(r *http.Request) error {
+	cookie, err := r.Cookie(c.name)
+	switch {
+	case cookie == nil || cookie.Value == "":
+		return c.noCookie.Put(r)
+	case err != nil:
+		return err
+	}
+	tokens, err := c.coster(r)
+	if err != nil {
+		return err
+	}
+	return c.limiter.Put(r.Context(), cookie.Value, tokens)
 }
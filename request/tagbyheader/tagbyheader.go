@@ -6,6 +6,7 @@ package tagbyheader
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/dkotik/oakratelimiter/rate"
 	"github.com/dkotik/oakratelimiter/request"
@@ -14,6 +15,7 @@ import (
 type HeaderLimiter struct {
 	name     string
 	limiter  rate.Limiter
+	coster   request.Coster
 	noHeader request.Limiter
 }
 
@@ -21,6 +23,7 @@ func New(withOptions ...Option) (_ *HeaderLimiter, err error) {
 	o := &options{}
 	for _, option := range append(
 		withOptions,
+		WithDefaultCoster(),
 		WithDefaultNoHeaderLimiter(),
 	) {
 		if err = option(o); err != nil {
@@ -30,6 +33,7 @@ func New(withOptions ...Option) (_ *HeaderLimiter, err error) {
 	return &HeaderLimiter{
 		name:     o.Name,
 		limiter:  o.Limiter,
+		coster:   o.Coster,
 		noHeader: o.NoHeader,
 	}, nil
 }
@@ -47,6 +51,29 @@ func (h *HeaderLimiter) Rate() *rate.Rate {
 	return noHeaderRate
 }
 
+func (h *HeaderLimiter) Tag(r *http.Request) (string, error) {
+	value := r.Header.Get(h.name)
+	if value == "" {
+		return request.Tag(h.noHeader, r)
+	}
+	return value, nil
+}
+
+func (h *HeaderLimiter) RequestRate(r *http.Request) (*rate.Rate, float64, error) {
+	value := r.Header.Get(h.name)
+	if value == "" {
+		return request.RequestRate(h.noHeader, r)
+	}
+	return rate.TagRate(r.Context(), h.limiter, value)
+}
+
+func (h *HeaderLimiter) Cost(r *http.Request) (float64, error) {
+	if r.Header.Get(h.name) == "" {
+		return request.Cost(h.noHeader, r)
+	}
+	return h.coster(r)
+}
+
 func (h *HeaderLimiter) Take(
 	r *http.Request,
 ) (
@@ -58,5 +85,33 @@ func (h *HeaderLimiter) Take(
 	if value == "" {
 		return h.noHeader.Take(r)
 	}
-	return h.limiter.Take(r.Context(), value, 1.0)
+	tokens, err := h.coster(r)
+	if err != nil {
+		return 0, false, err
+	}
+	return h.limiter.Take(r.Context(), value, tokens)
+}
+
+func (h *HeaderLimiter) Delay(r *http.Request) (time.Duration, error) {
+	value := r.Header.Get(h.name)
+	if value == "" {
+		return request.Delay(h.noHeader, r)
+	}
+	tokens, err := h.coster(r)
+	if err != nil {
+		return 0, err
+	}
+	return rate.Delay(r.Context(), h.limiter, value, tokens)
+}
+
+func (h *HeaderLimiter) Put(r *http.Request) error {
+	value := r.Header.Get(h.name)
+	if value == "" {
+		return h.noHeader.Put(r)
+	}
+	tokens, err := h.coster(r)
+	if err != nil {
+		return err
+	}
+	return h.limiter.Put(r.Context(), value, tokens)
 }
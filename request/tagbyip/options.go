@@ -9,6 +9,7 @@ import (
 
 	"github.com/dkotik/oakratelimiter/driver/mutexrlm"
 	"github.com/dkotik/oakratelimiter/rate"
+	"github.com/dkotik/oakratelimiter/request"
 )
 
 type AddressExtractor func(*http.Request) (string, error)
@@ -16,8 +17,11 @@ type AddressExtractor func(*http.Request) (string, error)
 type options struct {
 	Extractor AddressExtractor
 	Limiter   rate.Limiter
+	Coster    request.Coster
 	Filter    rate.TagFilter
 	Skip      []string
+	Allow     *PrefixList
+	Deny      *PrefixList
 }
 
 type Option func(*options) error
@@ -122,3 +126,55 @@ func WithSkipList(addresses ...string) Option {
 		return nil
 	}
 }
+
+// WithAllowList lets requests from addresses covered by a [PrefixList] bypass the rate limit, like office networks and health checkers.
+func WithAllowList(l *PrefixList) Option {
+	return func(o *options) error {
+		if l == nil {
+			return errors.New("cannot use a <nil> allow list")
+		}
+		if o.Allow != nil {
+			return errors.New("allow list is already set")
+		}
+		o.Allow = l
+		return nil
+	}
+}
+
+// WithDenyList rejects requests from addresses covered by a [PrefixList] without taking tokens, like known abusive networks. The deny list takes precedence over the allow list.
+func WithDenyList(l *PrefixList) Option {
+	return func(o *options) error {
+		if l == nil {
+			return errors.New("cannot use a <nil> deny list")
+		}
+		if o.Deny != nil {
+			return errors.New("deny list is already set")
+		}
+		o.Deny = l
+		return nil
+	}
+}
+
+// WithCoster determines how many tokens each request takes from the rate limiter.
+func WithCoster(c request.Coster) Option {
+	return func(o *options) error {
+		if c == nil {
+			return errors.New("cannot use a <nil> coster")
+		}
+		if o.Coster != nil {
+			return errors.New("coster is already set")
+		}
+		o.Coster = c
+		return nil
+	}
+}
+
+// WithDefaultCoster charges one token per request, if no coster was provided by another option.
+func WithDefaultCoster() Option {
+	return func(o *options) error {
+		if o.Coster != nil {
+			return nil // already set
+		}
+		return WithCoster(request.UnitCost)(o)
+	}
+}
@@ -0,0 +1,180 @@
+package tagbyip
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// PrefixList matches IP addresses against IPv4 and IPv6 CIDR prefixes using a binary trie, so that a lookup takes at most 128 steps regardless of the number of prefixes. It is safe for concurrent use. The prefixes can be replaced while the list is in use, for example by calling [PrefixList.Reload] on SIGHUP.
+type PrefixList struct {
+	path string
+	trie atomic.Pointer[prefixTrie]
+}
+
+// NewPrefixList creates a [PrefixList] from CIDR prefixes, like "10.0.0.0/8" or "2001:db8::/32". Single addresses are treated as prefixes of full length.
+func NewPrefixList(prefixes ...string) (*PrefixList, error) {
+	trie, err := newPrefixTrie(prefixes)
+	if err != nil {
+		return nil, err
+	}
+	l := &PrefixList{}
+	l.trie.Store(trie)
+	return l, nil
+}
+
+// LoadPrefixList creates a [PrefixList] from a file with one prefix per line. Empty lines and everything after "#" are ignored.
+func LoadPrefixList(path string) (*PrefixList, error) {
+	l := &PrefixList{path: path}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload reads the file given to [LoadPrefixList] again and atomically replaces the prefixes. If the file cannot be read or contains an invalid prefix, the previous prefixes are kept.
+func (l *PrefixList) Reload() error {
+	if l.path == "" {
+		return errors.New("prefix list was not loaded from a file")
+	}
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return fmt.Errorf("cannot load prefix list: %w", err)
+	}
+	var prefixes []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			prefixes = append(prefixes, line)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("cannot load prefix list %q: %w", l.path, err)
+	}
+	trie, err := newPrefixTrie(prefixes)
+	if err != nil {
+		return fmt.Errorf("cannot load prefix list %q: %w", l.path, err)
+	}
+	l.trie.Store(trie)
+	return nil
+}
+
+// Replace atomically replaces the prefixes. If any prefix is invalid, the previous prefixes are kept.
+func (l *PrefixList) Replace(prefixes ...string) error {
+	trie, err := newPrefixTrie(prefixes)
+	if err != nil {
+		return err
+	}
+	l.trie.Store(trie)
+	return nil
+}
+
+// Contains returns true, if any prefix covers the address. IPv4-mapped IPv6 addresses match IPv4 prefixes.
+func (l *PrefixList) Contains(address netip.Addr) bool {
+	return l.trie.Load().contains(address)
+}
+
+// ContainsTag parses a tag produced by an [AddressExtractor], with or without a port, and checks it using [PrefixList.Contains]. Tags that are not IP addresses are never contained.
+func (l *PrefixList) ContainsTag(tag string) bool {
+	address, err := netip.ParseAddr(tag)
+	if err != nil {
+		addressPort, err := netip.ParseAddrPort(tag)
+		if err != nil {
+			return false
+		}
+		address = addressPort.Addr()
+	}
+	return l.Contains(address)
+}
+
+type prefixNode struct {
+	children [2]*prefixNode
+	terminal bool
+}
+
+// prefixTrie keeps IPv4 and IPv6 prefixes in separate binary tries.
+type prefixTrie struct {
+	v4 prefixNode
+	v6 prefixNode
+}
+
+func newPrefixTrie(prefixes []string) (*prefixTrie, error) {
+	t := &prefixTrie{}
+	for _, text := range prefixes {
+		prefix, err := parsePrefix(text)
+		if err != nil {
+			return nil, err
+		}
+		t.insert(prefix)
+	}
+	return t, nil
+}
+
+func parsePrefix(text string) (netip.Prefix, error) {
+	if !strings.Contains(text, "/") {
+		address, err := netip.ParseAddr(text)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid IP address %q: %w", text, err)
+		}
+		address = address.Unmap().WithZone("")
+		return netip.PrefixFrom(address, address.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(text)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid CIDR prefix %q: %w", text, err)
+	}
+	if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+		return netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96).Masked(), nil
+	}
+	return prefix.Masked(), nil
+}
+
+func (t *prefixTrie) root(address netip.Addr) *prefixNode {
+	if address.Is4() {
+		return &t.v4
+	}
+	return &t.v6
+}
+
+func (t *prefixTrie) insert(prefix netip.Prefix) {
+	address := prefix.Addr()
+	octets := address.AsSlice()
+	node := t.root(address)
+	for i := 0; i < prefix.Bits(); i++ {
+		if node.terminal {
+			return // a shorter prefix already covers this one
+		}
+		bit := octets[i/8] >> (7 - i%8) & 1
+		if node.children[bit] == nil {
+			node.children[bit] = &prefixNode{}
+		}
+		node = node.children[bit]
+	}
+	node.terminal = true
+	node.children = [2]*prefixNode{} // covered by this prefix
+}
+
+func (t *prefixTrie) contains(address netip.Addr) bool {
+	if !address.IsValid() {
+		return false
+	}
+	address = address.Unmap().WithZone("")
+	octets := address.AsSlice()
+	node := t.root(address)
+	for i := 0; i < address.BitLen(); i++ {
+		if node.terminal {
+			return true
+		}
+		node = node.children[octets[i/8]>>(7-i%8)&1]
+		if node == nil {
+			return false
+		}
+	}
+	return node.terminal
+}
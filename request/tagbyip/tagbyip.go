@@ -6,6 +6,7 @@ package tagbyip
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/dkotik/oakratelimiter/rate"
 	"github.com/dkotik/oakratelimiter/request"
@@ -14,7 +15,9 @@ import (
 type IPAddressLimiter struct {
 	extractor AddressExtractor
 	limiter   rate.Limiter
+	coster    request.Coster
 	filter    rate.TagFilter
+	deny      *PrefixList
 }
 
 func New(withOptions ...Option) (_ request.Limiter, err error) {
@@ -22,6 +25,7 @@ func New(withOptions ...Option) (_ request.Limiter, err error) {
 	for _, option := range append(
 		withOptions,
 		WithDefaultAddressExtractor(),
+		WithDefaultCoster(),
 		func(o *options) error {
 			if len(o.Skip) == 0 {
 				return nil
@@ -45,6 +49,19 @@ func New(withOptions ...Option) (_ request.Limiter, err error) {
 			}
 			return nil
 		},
+		func(o *options) error {
+			if o.Allow == nil {
+				return nil
+			}
+			additionalFilter := o.Filter
+			o.Filter = func(tag string) bool {
+				if o.Allow.ContainsTag(tag) {
+					return false
+				}
+				return additionalFilter == nil || additionalFilter(tag)
+			}
+			return nil
+		},
 	) {
 		if err = option(o); err != nil {
 			return nil, fmt.Errorf("cannot initialize cookie limiter: %w", err)
@@ -59,7 +76,9 @@ func New(withOptions ...Option) (_ request.Limiter, err error) {
 	return &IPAddressLimiter{
 		extractor: o.Extractor,
 		limiter:   o.Limiter,
+		coster:    o.Coster,
 		filter:    o.Filter,
+		deny:      o.Deny,
 	}, nil
 }
 
@@ -67,6 +86,35 @@ func (a *IPAddressLimiter) Rate() *rate.Rate {
 	return a.limiter.Rate()
 }
 
+func (a *IPAddressLimiter) Tag(r *http.Request) (string, error) {
+	return a.extractor(r)
+}
+
+// Explain reports addresses on the deny list.
+func (a *IPAddressLimiter) Explain(r *http.Request) (reason string, retryAfter time.Duration) {
+	address, err := a.extractor(r)
+	if err != nil || !a.denied(address) {
+		return "", 0
+	}
+	return "address is on the deny list", 0
+}
+
+func (a *IPAddressLimiter) denied(address string) bool {
+	return a.deny != nil && a.deny.ContainsTag(address)
+}
+
+func (a *IPAddressLimiter) RequestRate(r *http.Request) (*rate.Rate, float64, error) {
+	address, err := a.extractor(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rate.TagRate(r.Context(), a.limiter, address)
+}
+
+func (a *IPAddressLimiter) Cost(r *http.Request) (float64, error) {
+	return a.coster(r)
+}
+
 func (a *IPAddressLimiter) Take(
 	r *http.Request,
 ) (
@@ -78,8 +126,48 @@ func (a *IPAddressLimiter) Take(
 	if err != nil {
 		return 0, false, err
 	}
+	if a.denied(address) {
+		return 0, false, nil
+	}
 	if !a.filter(address) {
 		return a.limiter.Rate().Burst(), true, nil
 	}
-	return a.limiter.Take(r.Context(), address, 1.0)
+	tokens, err := a.coster(r)
+	if err != nil {
+		return 0, false, err
+	}
+	return a.limiter.Take(r.Context(), address, tokens)
+}
+
+func (a *IPAddressLimiter) Delay(r *http.Request) (time.Duration, error) {
+	address, err := a.extractor(r)
+	if err != nil {
+		return 0, err
+	}
+	if a.denied(address) {
+		return 0, request.ErrUnknownDelay
+	}
+	if !a.filter(address) {
+		return 0, nil
+	}
+	tokens, err := a.coster(r)
+	if err != nil {
+		return 0, err
+	}
+	return rate.Delay(r.Context(), a.limiter, address, tokens)
+}
+
+func (a *IPAddressLimiter) Put(r *http.Request) error {
+	address, err := a.extractor(r)
+	if err != nil {
+		return err
+	}
+	if a.denied(address) || !a.filter(address) {
+		return nil
+	}
+	tokens, err := a.coster(r)
+	if err != nil {
+		return err
+	}
+	return a.limiter.Put(r.Context(), address, tokens)
 }
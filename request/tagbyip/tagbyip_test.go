@@ -3,9 +3,13 @@ package tagbyip
 import (
 	"context"
 	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/dkotik/oakratelimiter/request"
 	"github.com/dkotik/oakratelimiter/test"
 )
 
@@ -39,6 +43,27 @@ func TestRateLimitingByIPAddress(t *testing.T) {
 		}
 	})
 
+	t.Run("try with an expensive request", func(t *testing.T) {
+		coster, err := request.NewFixedCoster(4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, err := New(
+			WithNewRate(5, time.Minute),
+			WithCoster(coster),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rf := requestFactory("127.0.0.1:8181")
+		if _, ok, err := l.Take(rf(ctx)); err != nil || !ok {
+			t.Fatal("request limiter blocked the first request unexpectedly:", err)
+		}
+		if _, ok, err := l.Take(rf(ctx)); err != nil || ok {
+			t.Fatal("request limiter did not block the second request:", err)
+		}
+	})
+
 	t.Run("try with second address", func(t *testing.T) {
 		rf := requestFactory("254.1.127.67:6775")
 		for i := 0; i < 5; i++ {
@@ -52,3 +77,104 @@ func TestRateLimitingByIPAddress(t *testing.T) {
 		}
 	})
 }
+
+func TestPrefixList(t *testing.T) {
+	l, err := NewPrefixList("10.0.0.0/8", "192.168.1.7", "2001:db8::/32", "::ffff:172.16.0.0/108")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"10.1.2.3":           true,
+		"11.0.0.1":           false,
+		"192.168.1.7":        true,
+		"192.168.1.8":        false,
+		"2001:db8::1":        true,
+		"2001:db9::1":        false,
+		"::ffff:10.0.0.1":    true,
+		"172.16.5.5":         true,
+		"172.32.0.1":         false,
+		"[2001:db8::1]:8080": true,
+		"10.0.0.1:443":       true,
+		"not an address":     false,
+	}
+	for tag, expected := range cases {
+		if l.ContainsTag(tag) != expected {
+			t.Errorf("prefix list contains %q: %t, expected %t", tag, !expected, expected)
+		}
+	}
+	if l.Contains(netip.Addr{}) {
+		t.Error("invalid address was contained")
+	}
+	if _, err = NewPrefixList("10.0.0.0/33"); err == nil {
+		t.Error("invalid prefix was accepted")
+	}
+
+	p := filepath.Join(t.TempDir(), "allow.txt")
+	if err = os.WriteFile(p, []byte("# office\n10.0.0.0/8 # VPN\n\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if l, err = LoadPrefixList(p); err != nil {
+		t.Fatal("cannot load prefix list:", err)
+	}
+	if !l.ContainsTag("10.0.0.1") {
+		t.Fatal("loaded prefix was not matched")
+	}
+	if err = os.WriteFile(p, []byte("192.168.0.0/16\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Reload(); err != nil {
+		t.Fatal("cannot reload prefix list:", err)
+	}
+	if l.ContainsTag("10.0.0.1") || !l.ContainsTag("192.168.3.4") {
+		t.Fatal("prefix list was not reloaded")
+	}
+	if err = os.WriteFile(p, []byte("garbage\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Reload(); err == nil || !l.ContainsTag("192.168.3.4") {
+		t.Fatal("invalid file replaced the prefixes:", err)
+	}
+}
+
+func TestAllowAndDenyLists(t *testing.T) {
+	ctx := context.Background()
+	allow, err := NewPrefixList("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deny, err := NewPrefixList("10.6.6.0/24", "2001:db8:bad::/48")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := New(
+		WithNewRate(1, time.Minute),
+		WithAllowList(allow),
+		WithDenyList(deny),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	office := requestFactory("10.1.1.1:8181")
+	for i := 0; i < 3; i++ {
+		if _, ok, err := l.Take(office(ctx)); err != nil || !ok {
+			t.Fatal("allowed address was limited:", err)
+		}
+	}
+	for _, address := range []string{"10.6.6.6:8181", "[2001:db8:bad::1]:8181"} {
+		attacker := requestFactory(address)(ctx)
+		if _, ok, err := l.Take(attacker); err != nil || ok {
+			t.Fatal("denied address was let through:", address, err)
+		}
+		if reason, _ := request.Explain(l, attacker); reason == "" {
+			t.Fatal("denied address was not explained:", address)
+		}
+	}
+	customer := requestFactory("192.0.2.1:8181")
+	if _, ok, err := l.Take(customer(ctx)); err != nil || !ok {
+		t.Fatal("first request was limited:", err)
+	}
+	if _, ok, err := l.Take(customer(ctx)); err != nil || ok {
+		t.Fatal("second request was not limited:", err)
+	}
+}
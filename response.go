@@ -0,0 +1,100 @@
+package oakratelimiter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// ResponsePolicy decides whether tokens taken by a request should be returned after the next [Handler] responded with the given HTTP status code. It lets a [request.Limiter] count only certain outcomes, like failed login attempts. Apply it with [WithResponsePolicy] to refund tokens taken before the response or with [WithResponseCharge] to take tokens only after the response.
+type ResponsePolicy func(status int) (refund bool)
+
+// RefundServerErrors returns tokens when the response status code indicates a server error. Callers are not penalized for failures that were not their fault.
+func RefundServerErrors(status int) bool {
+	return status >= http.StatusInternalServerError
+}
+
+// ChargeOnly returns tokens unless the response status code is one of the given codes. Use it with [http.StatusUnauthorized] and [http.StatusForbidden] to count only failed authentication attempts.
+func ChargeOnly(statusCodes ...int) (ResponsePolicy, error) {
+	if len(statusCodes) == 0 {
+		return nil, errors.New("cannot use an empty list of charged status codes")
+	}
+	for _, code := range statusCodes {
+		if code < 100 || code > 999 {
+			return nil, fmt.Errorf("invalid charged status code %d", code)
+		}
+	}
+	return func(status int) bool {
+		for _, charged := range statusCodes {
+			if status == charged {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// responseStatus returns the status code that is or will be written to the client. Errors are resolved the same way as [RequestHandler.ServeHTTP] resolves them.
+func responseStatus(w *statusRecorder, err error) int {
+	if err != nil {
+		var httpError Error
+		if errors.As(err, &httpError) {
+			return httpError.HyperTextStatusCode()
+		}
+		return http.StatusInternalServerError
+	}
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// statusRecorder captures the first final status code written by a [Handler].
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 && code >= http.StatusOK {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the original [http.ResponseWriter] to [http.ResponseController].
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Flush sends buffered data to the client, if the original [http.ResponseWriter] supports it.
+func (s *statusRecorder) Flush() {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets the next [Handler] take over the connection, if the original [http.ResponseWriter] supports it. A hijacked connection is recorded as [http.StatusSwitchingProtocols].
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("cannot hijack connection: %w", http.ErrNotSupported)
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil && s.status == 0 {
+		s.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
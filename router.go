@@ -0,0 +1,239 @@
+package oakratelimiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// wildcardSample stands in for path wildcards when deciding whether one route pattern is more general than another.
+const wildcardSample = "~oakratelimiter~"
+
+// anyMethodSample stands in for the method of route patterns that match every method.
+const anyMethodSample = "OAKRATELIMITER"
+
+type routeContextKey struct{}
+
+// RouteFromContext returns the [Router] pattern that matched the request.
+func RouteFromContext(ctx context.Context) (pattern string, ok bool) {
+	pattern, ok = ctx.Value(routeContextKey{}).(string)
+	return pattern, ok
+}
+
+// Router applies rate limiting policies according to [http.ServeMux] patterns, so that one [Handler] can wrap an entire multiplexer. Each route inherits the request limiters of every more general route: "/api/" inherits from "/", "POST /api/login" inherits from both. Inherited request limiters are shared, so that a request to "POST /api/login" also takes from the buckets of "/api/". A route overrides an inherited request limiter by adding another one with the same name. Requests that match no route are passed to the next [Handler] without limits.
+type Router struct {
+	next          Handler
+	errorRenderer ErrorRenderer
+	mux           *http.ServeMux
+	patterns      []string
+}
+
+type routerOptions struct {
+	patterns []string
+	routes   map[string][]Option
+}
+
+// RouterOption initializes a [Router].
+type RouterOption func(*routerOptions) error
+
+// WithRoute adds a rate limiting policy for requests matching an [http.ServeMux] pattern. A route without options only inherits the policies of more general routes.
+func WithRoute(pattern string, withOptions ...Option) RouterOption {
+	return func(o *routerOptions) error {
+		if pattern == "" {
+			return errors.New("cannot use an empty route pattern")
+		}
+		if o.routes == nil {
+			o.routes = make(map[string][]Option)
+		}
+		if _, ok := o.routes[pattern]; ok {
+			return fmt.Errorf("route %q is already set", pattern)
+		}
+		o.patterns = append(o.patterns, pattern)
+		o.routes[pattern] = withOptions
+		return nil
+	}
+}
+
+// NewRouter initializes a [Router] using a list of [RouterOption]s.
+func NewRouter(next Handler, withRoutes ...RouterOption) (_ *Router, err error) {
+	if next == nil {
+		return nil, fmt.Errorf("cannot use a %q handler", next)
+	}
+	ro := &routerOptions{}
+	for _, option := range withRoutes {
+		if err = option(ro); err != nil {
+			return nil, fmt.Errorf("cannot initialize Oak rate limiting router: %w", err)
+		}
+	}
+	if len(ro.patterns) == 0 {
+		return nil, errors.New("cannot initialize Oak rate limiting router: at least one route is required")
+	}
+
+	validation := http.NewServeMux()
+	own := make(map[string]*options, len(ro.patterns))
+	for _, pattern := range ro.patterns {
+		if err = handle(validation, pattern, &routeProbe{}); err != nil {
+			return nil, fmt.Errorf("cannot initialize route %q: %w", pattern, err)
+		}
+		o := &options{}
+		if err = o.apply(ro.routes[pattern]); err != nil {
+			return nil, fmt.Errorf("cannot initialize route %q: %w", pattern, err)
+		}
+		own[pattern] = o
+	}
+
+	ancestors := make(map[string][]string, len(ro.patterns))
+	for _, pattern := range ro.patterns {
+		if ancestors[pattern], err = findAncestors(pattern, ro.patterns); err != nil {
+			return nil, fmt.Errorf("cannot initialize route %q: %w", pattern, err)
+		}
+	}
+
+	rt := &Router{
+		next:          next,
+		errorRenderer: NewDefaultErrorRenderer(),
+		mux:           http.NewServeMux(),
+		patterns:      ro.patterns,
+	}
+	shared := newWrappers(nil) // routes share the wrappers of inherited request limiters
+	for _, pattern := range ro.patterns {
+		o := &options{wrappers: shared}
+		for _, ancestor := range orderByGenerality(ancestors[pattern], ancestors) {
+			o.merge(own[ancestor])
+		}
+		o.merge(own[pattern])
+		if err = o.finalize(); err != nil {
+			return nil, fmt.Errorf("cannot initialize route %q: %w", pattern, err)
+		}
+		if err = handle(rt.mux, pattern, o.newRequestHandler(next)); err != nil {
+			return nil, fmt.Errorf("cannot initialize route %q: %w", pattern, err)
+		}
+	}
+	return rt, nil
+}
+
+// Patterns returns the route patterns in the order they were added.
+func (rt *Router) Patterns() []string {
+	return append([]string(nil), rt.patterns...)
+}
+
+// Match returns the pattern of the route that applies to the request.
+func (rt *Router) Match(r *http.Request) (pattern string, ok bool) {
+	_, pattern, ok = rt.match(r)
+	return pattern, ok
+}
+
+func (rt *Router) match(r *http.Request) (*RequestHandler, string, bool) {
+	h, pattern := rt.mux.Handler(r)
+	policy, ok := h.(*RequestHandler)
+	if !ok {
+		return nil, "", false // not found or redirected
+	}
+	return policy, pattern, true
+}
+
+// ServeHyperText satisfies an improved [http.Handler] interface. The matched route pattern is available to the next [Handler] through [RouteFromContext].
+func (rt *Router) ServeHyperText(w http.ResponseWriter, r *http.Request) error {
+	policy, pattern, ok := rt.match(r)
+	if !ok {
+		return rt.next.ServeHyperText(w, r)
+	}
+	return policy.ServeHyperText(w, r.WithContext(
+		context.WithValue(r.Context(), routeContextKey{}, pattern)))
+}
+
+// ServeHTTP satisfies [http.Handler] for compatibility with the standard library.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	policy, pattern, ok := rt.match(r)
+	if !ok {
+		if err := rt.next.ServeHyperText(w, r); err != nil {
+			writeError(w, r, err, rt.errorRenderer)
+		}
+		return
+	}
+	policy.ServeHTTP(w, r.WithContext(
+		context.WithValue(r.Context(), routeContextKey{}, pattern)))
+}
+
+// routeProbe marks registered patterns while routes are being compared.
+type routeProbe struct{}
+
+func (p *routeProbe) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+func isProbe(h http.Handler) bool {
+	_, ok := h.(*routeProbe)
+	return ok
+}
+
+// handle registers a pattern, reporting invalid and conflicting patterns as errors instead of panics.
+func handle(mux *http.ServeMux, pattern string, h http.Handler) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%v", recovered)
+		}
+	}()
+	mux.Handle(pattern, h)
+	return nil
+}
+
+// findAncestors returns the patterns that match every request the given pattern matches. It builds a sample request from the pattern, replacing wildcards with a placeholder, and checks which other patterns match it.
+func findAncestors(pattern string, patterns []string) (ancestors []string, err error) {
+	sample := sampleRequest(pattern)
+	for _, candidate := range patterns {
+		if candidate == pattern {
+			continue
+		}
+		mux := http.NewServeMux()
+		if err = handle(mux, candidate, &routeProbe{}); err != nil {
+			return nil, err
+		}
+		if h, _ := mux.Handler(sample); isProbe(h) {
+			ancestors = append(ancestors, candidate)
+		}
+	}
+	return ancestors, nil
+}
+
+// orderByGenerality sorts ancestors from the most general to the most specific. A pattern is more general, if it has fewer ancestors of its own.
+func orderByGenerality(patterns []string, ancestors map[string][]string) []string {
+	ordered := append([]string(nil), patterns...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return len(ancestors[ordered[i]]) < len(ancestors[ordered[j]])
+	})
+	return ordered
+}
+
+// sampleRequest creates a request that matches the given [http.ServeMux] pattern.
+func sampleRequest(pattern string) *http.Request {
+	method := anyMethodSample
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 {
+		method = pattern[:i]
+		pattern = strings.TrimLeft(pattern[i:], " \t")
+	}
+	host, path := "", pattern
+	if i := strings.IndexByte(pattern, '/'); i > 0 {
+		host, path = pattern[:i], pattern[i:]
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		switch {
+		case segment == "{$}":
+			segments[i] = ""
+		case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"):
+			segments[i] = wildcardSample
+		}
+	}
+	path = strings.Join(segments, "/")
+
+	return &http.Request{
+		Method: method,
+		Host:   host,
+		URL:    &url.URL{Path: path},
+		Header: make(http.Header),
+	}
+}
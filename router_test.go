@@ -0,0 +1,102 @@
+package oakratelimiter
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dkotik/oakratelimiter/driver/mutexrlm"
+)
+
+func TestRouter(t *testing.T) {
+	var matched string
+	next := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		matched, _ = RouteFromContext(r.Context())
+		return nil
+	})
+	api, err := mutexrlm.NewRequestLimiter(mutexrlm.WithNewRate(3, time.Minute))
+	if err != nil {
+		t.Fatal("cannot initialize request limiter:", err)
+	}
+	login, err := mutexrlm.NewRequestLimiter(mutexrlm.WithNewRate(1, time.Minute))
+	if err != nil {
+		t.Fatal("cannot initialize request limiter:", err)
+	}
+
+	rt, err := NewRouter(
+		next,
+		WithRoute("/api/", WithRequestLimiter("api", api)),
+		WithRoute("POST /api/login", WithRequestLimiter("login", login)),
+		WithRoute("GET /api/users/{id}"),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize router:", err)
+	}
+
+	serve := func(method, path string) error {
+		matched = ""
+		return rt.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(method, path, nil))
+	}
+
+	if err = serve(http.MethodPost, "/api/login"); err != nil {
+		t.Fatal("first login was rejected:", err)
+	}
+	if matched != "POST /api/login" {
+		t.Fatalf("matched route %q instead of login", matched)
+	}
+	var tooMany *TooManyRequestsError
+	if err = serve(http.MethodPost, "/api/login"); !errors.As(err, &tooMany) {
+		t.Fatal("second login was not rejected:", err)
+	}
+	if err = serve(http.MethodGet, "/api/users/42"); err != nil {
+		t.Fatal("inheriting route was rejected:", err)
+	}
+	if matched != "GET /api/users/{id}" {
+		t.Fatalf("matched route %q instead of users", matched)
+	}
+	if err = serve(http.MethodGet, "/api/other"); err != nil {
+		t.Fatal("parent route was rejected:", err)
+	}
+	if err = serve(http.MethodGet, "/api/other"); !errors.As(err, &tooMany) {
+		t.Fatal("shared API limiter was not drained by inheriting routes:", err)
+	}
+	if err = serve(http.MethodGet, "/public"); err != nil {
+		t.Fatal("unmatched request was limited:", err)
+	}
+	if pattern, ok := rt.Match(httptest.NewRequest(http.MethodGet, "/public", nil)); ok {
+		t.Fatalf("unmatched request matched route %q", pattern)
+	}
+
+	if _, err = NewRouter(next, WithRoute("GET /orphan")); err == nil {
+		t.Fatal("route without request limiters was accepted")
+	}
+	if _, err = NewRouter(next, WithRoute("INVALID PATTERN /{"), WithRoute("/", WithRequestLimiter("api", api))); err == nil {
+		t.Fatal("invalid pattern was accepted")
+	}
+}
+
+func TestRouterOverride(t *testing.T) {
+	strict, err := mutexrlm.NewRequestLimiter(mutexrlm.WithNewRate(1, time.Minute))
+	if err != nil {
+		t.Fatal("cannot initialize request limiter:", err)
+	}
+	relaxed, err := mutexrlm.NewRequestLimiter(mutexrlm.WithNewRate(5, time.Minute))
+	if err != nil {
+		t.Fatal("cannot initialize request limiter:", err)
+	}
+	rt, err := NewRouter(
+		noContent,
+		WithRoute("/", WithRequestLimiter("global", strict)),
+		WithRoute("/health", WithRequestLimiter("global", relaxed)),
+	)
+	if err != nil {
+		t.Fatal("cannot initialize router:", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err = rt.ServeHyperText(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil)); err != nil {
+			t.Fatal("overriding limiter was not used:", err)
+		}
+	}
+}
@@ -119,3 +119,54 @@ func RateLimiterTest(
 		wg.Wait()
 	}
 }
+
+// RateLimiterRefundTest drains all the tokens of a tag and then ensures that [rate.Limiter.Put] makes them available again.
+func RateLimiterRefundTest(
+	ctx context.Context,
+	r rate.Limiter,
+	tag string,
+) func(*testing.T) {
+	return func(t *testing.T) {
+		if r == nil {
+			t.Fatal("cannot use a <nil> rate limiter")
+		}
+		taken := 0
+		for ; ; taken++ {
+			_, ok, err := r.Take(ctx, tag, 1.0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok {
+				break
+			}
+			if float64(taken) > r.Rate().Burst()*2 {
+				t.Fatal("rate limiter never rejected a request")
+			}
+		}
+		if taken == 0 {
+			t.Fatal("rate limiter rejected the first request")
+		}
+
+		if err := r.Put(ctx, tag, 1.0); err != nil {
+			t.Fatal("cannot put tokens back:", err)
+		}
+		remaining, ok, err := r.Take(ctx, tag, 1.0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Fatal("rate limiter rejected a refunded token:", remaining, "remaining")
+		}
+
+		// a refund larger than a single take
+		if err = r.Put(ctx, tag, 2.0); err != nil {
+			t.Fatal("cannot put tokens back:", err)
+		}
+		if remaining, ok, err = r.Take(ctx, tag, 2.0); err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Fatal("rate limiter rejected tokens refunded from several takes:", remaining, "remaining")
+		}
+	}
+}